@@ -9,10 +9,60 @@ node
     ;
 
 expression
-    : binary
+    : lambda
+    | conditional
     ;
 
+// 匿名函数，一般作为高阶函数的参数使用，比如 filter(items, x -> x.price > 10)、reduce(items, (acc, x) -> acc + x, 0)
+lambda
+    : (Variable | LParen (Variable (Comma Variable)*)? RParen) Arrow expression
+    ;
+
+// 三元表达式是右结合的: a ? b : c ? d : e 等价于 a ? b : (c ? d : e)
+conditional
+    : binary ('?' conditional ':' conditional)?
+    ;
+
+// 优先级从低到高: || -> && -> 相等 -> 比较 -> 区间 -> | -> ^ -> & -> 移位 -> 加减 -> 乘除 -> 幂
 binary
+    : and_binary (Or_op and_binary)*
+    ;
+
+and_binary
+    : equality_binary (And_op equality_binary)*
+    ;
+
+equality_binary
+    : relational_binary (Equality_op relational_binary)*
+    ;
+
+// a between low and high 等价于 a between low..high
+relational_binary
+    : range_binary (Relational_op range_binary | Between range_binary And_op range_binary)*
+    ;
+
+range_binary
+    : bit_or_binary (Range_op bit_or_binary)*
+    ;
+
+// 位运算和移位的操作数只能是整数
+bit_or_binary
+    : bit_xor_binary (Bit_or_op bit_xor_binary)*
+    ;
+
+bit_xor_binary
+    : bit_and_binary (Bit_xor_op bit_and_binary)*
+    ;
+
+bit_and_binary
+    : shift_binary (Bit_and_op shift_binary)*
+    ;
+
+shift_binary
+    : level1_binary (Shift_op level1_binary)*
+    ;
+
+level1_binary
     : level2_binary (First_level_op level2_binary)*
     ;
 
@@ -23,7 +73,13 @@ binary
 //
 //在代码中使用 signedAtomBinaryxx 方法进行解析，因为该二元表达式的 参数是 signedAtom
 level2_binary
-    : signedAtom (Second_level_op signedAtom)*
+    : power_binary (Second_level_op power_binary)*
+    ;
+
+// 幂运算是右结合的: 2 ** 3 ** 2 等价于 2 ** (3 ** 2)
+// 一元运算符的优先级比幂运算高: -2 ** 2 等价于 (-2) ** 2
+power_binary
+    : signedAtom (Power_op signedAtom)*
     ;
 
 // note 如果加入其他优先级运算符，则将当前最高优先级、比如 level2_binary 的 signedAtom 替换为 level_n_binary
@@ -37,18 +93,46 @@ signedAtom
     | atom
     ;
 
+// 一元运算符可以连续出现，比如 !!a、not not a
 unary
-    :UnaryOp atom
+    :UnaryOp signedAtom
     ;
 
+// 下标、切片和成员访问可以连续出现，比如 a[0][1:2]、order.items[0].price
 atom
+    : primary (index | slice | member)*
+    ;
+
+primary
     : Variable
     | String
     | Number
+    | Duration
+    | Bool
+    | Null
     | func
+    | array
     | sub_node
     ;
 
+array
+    : LBracket (expression (Comma expression)*)? RBracket
+    ;
+
+index
+    : LBracket expression RBracket
+    ;
+
+slice
+    : LBracket expression? ':' expression? RBracket
+    ;
+
+// 方法调用，比如 user.FullName()
+member
+    : '.' Variable
+    | '.' func
+    ;
+
 func
     : Func_name LParen node (Comma node)*  RParen
     ;
@@ -60,6 +144,44 @@ sub_node
 UnaryOp
     : '+'
     | '-'
+    | '!'
+    | 'not'
+    ;
+
+Or_op
+    : '||'
+    | 'or'
+    ;
+
+And_op
+    : '&&'
+    | 'and'
+    ;
+
+// =~ 和 matches 是正则匹配，右边是 pattern
+Equality_op
+    : '=='
+    | '!='
+    | '=~'
+    | 'matches'
+    ;
+
+Relational_op
+    : '<'
+    | '<='
+    | '>'
+    | '>='
+    | 'in'
+    | 'not' [ \t]+ 'in'
+    ;
+
+Between
+    : 'between'
+    ;
+
+// 闭区间，1..10 包括 1 和 10
+Range_op
+    : '..'
     ;
 
 First_level_op
@@ -73,6 +195,27 @@ Second_level_op
     | '%'
     ;
 
+Bit_or_op
+    : '|'
+    ;
+
+Bit_xor_op
+    : '^'
+    ;
+
+Bit_and_op
+    : '&'
+    ;
+
+Shift_op
+    : '<<'
+    | '>>'
+    ;
+
+Power_op
+    : '**'
+    ;
+
 Func_name
     : (Letter | '_')+ Letter*
     ;
@@ -93,13 +236,57 @@ Comma
     : ','
     ;
 
+Arrow
+    : '->'
+    ;
+
+LBracket
+    : '['
+    ;
+
+RBracket
+    : ']'
+    ;
+
+Bool
+    : 'true'
+    | 'false'
+    ;
+
+Null
+    : 'nil'
+    | 'null'
+    ;
+
 Variable
     : Letter
     ;
 
-// todo 貌似应该以 "" 起始
+// 单引号、双引号字符串支持转义，反引号字符串是原始字符串
 String
-    : Letter
+    : '\'' (EscapeSequence | ~['\\])* '\''
+    | '"' (EscapeSequence | ~["\\])* '"'
+    | '`' ~'`'* '`'
+    ;
+
+fragment EscapeSequence
+    : '\\' [ntrabfv0\\'"]
+    | '\\x' HexDigit HexDigit
+    | '\\u' HexDigit HexDigit HexDigit HexDigit
+    | '\\U' HexDigit HexDigit HexDigit HexDigit HexDigit HexDigit HexDigit HexDigit
+    ;
+
+fragment HexDigit
+    : [0-9a-fA-F]
+    ;
+
+// 时间间隔，单位和 time.ParseDuration 相同，比如 5m、1.5h、1h30m
+Duration
+    : (Number ('.' Number)? DurationUnit)+
+    ;
+
+fragment DurationUnit
+    : 'ns' | 'us' | 'µs' | 'ms' | 's' | 'm' | 'h'
     ;
 
 // 范围选择符使用两个点（..）来表示范围的开始和结束
@@ -1,16 +1,187 @@
 package ast
 
-// Equal 判断两个表达式是否相等
+import (
+	"reflect"
+	"regexp"
+)
+
+// Equal 判断两个表达式的结构是否相同，note 函数不是纯函数时，结构相同的表达式计算结果也可能不同
+//
+//	note 括号以及优化阶段生成的 CommonScope、CommonExpression 不影响计算结果，所以比较时会被忽略，
+//		 比如 (a + b) 和 a + b 相等
 func Equal(e1, e2 Expression) bool {
-	panic("没有实现")
+	e1, e2 = unwrap(e1), unwrap(e2)
+	if e1 == nil || e2 == nil {
+		return e1 == nil && e2 == nil
+	}
+
+	switch x := e1.(type) {
+	case *EmptyExpression:
+		_, ok := e2.(*EmptyExpression)
+		return ok
+	case *NullNode:
+		_, ok := e2.(*NullNode)
+		return ok
+	case *NumberNode:
+		y, ok := e2.(*NumberNode)
+		return ok && x.isFloat == y.isFloat && x.GetValue() == y.GetValue()
+	case *DurationNode:
+		y, ok := e2.(*DurationNode)
+		return ok && x.value == y.value
+	case *StringNode:
+		y, ok := e2.(*StringNode)
+		return ok && x.value == y.value
+	case *BoolNode:
+		y, ok := e2.(*BoolNode)
+		return ok && x.value == y.value
+	case *ConstantNode:
+		y, ok := e2.(*ConstantNode)
+		return ok && constantEqual(x.value, y.value)
+	case *VariableNode:
+		y, ok := e2.(*VariableNode)
+		return ok && x.name == y.name
+	case *OperatorNode:
+		y, ok := e2.(*OperatorNode)
+		return ok && x.op == y.op
+	case *funcNameNode:
+		y, ok := e2.(*funcNameNode)
+		return ok && x.name == y.name
+
+	case *ConditionalExpression:
+		y, ok := e2.(*ConditionalExpression)
+		return ok && Equal(x.condition, y.condition) && Equal(x.then, y.then) && Equal(x.otherwise, y.otherwise)
+	case *LambdaExpression:
+		y, ok := e2.(*LambdaExpression)
+		return ok && reflect.DeepEqual(x.params, y.params) && Equal(x.body, y.body)
+	case *UnaryExpression:
+		y, ok := e2.(*UnaryExpression)
+		return ok && x.op.op == y.op.op && Equal(x.exp, y.exp)
+	case *BinaryExpression:
+		y, ok := e2.(*BinaryExpression)
+		if !ok || len(x.arguments) != len(y.arguments) || !Equal(x.left, y.left) {
+			return false
+		}
+		for i := range x.arguments {
+			if x.arguments[i].op.op != y.arguments[i].op.op || !Equal(x.arguments[i].arg, y.arguments[i].arg) {
+				return false
+			}
+		}
+		return true
+	case *FuncExpression:
+		y, ok := e2.(*FuncExpression)
+		return ok && x.funcName.name == y.funcName.name && equalAll(x.arguments, y.arguments)
+	case *ArrayExpression:
+		y, ok := e2.(*ArrayExpression)
+		return ok && equalAll(x.elements, y.elements)
+	case *IndexExpression:
+		y, ok := e2.(*IndexExpression)
+		return ok && Equal(x.object, y.object) && Equal(x.index, y.index)
+	case *SliceExpression:
+		y, ok := e2.(*SliceExpression)
+		return ok && Equal(x.object, y.object) && Equal(x.low, y.low) && Equal(x.high, y.high)
+	case *MemberExpression:
+		y, ok := e2.(*MemberExpression)
+		return ok && x.name == y.name && Equal(x.object, y.object)
+	case *MethodCallExpression:
+		y, ok := e2.(*MethodCallExpression)
+		return ok && x.name == y.name && Equal(x.object, y.object) && equalAll(x.arguments, y.arguments)
+	case *IdentityExpression:
+		y, ok := e2.(*IdentityExpression)
+		return ok && x.op.op == y.op.op && x.operandFirst == y.operandFirst &&
+			Equal(x.exp, y.exp) && Equal(x.operand, y.operand)
+	default:
+		return false
+	}
+}
+
+// unwrap 去掉不影响计算结果的括号等节点
+func unwrap(exp Expression) Expression {
+	for {
+		switch e := exp.(type) {
+		case *SubNode:
+			exp = e.subNode
+		case *CommonScope:
+			exp = e.exp
+		case *CommonExpression:
+			exp = e.exp
+		default:
+			return exp
+		}
+	}
+}
+
+func equalAll(exps1, exps2 []Expression) bool {
+	if len(exps1) != len(exps2) {
+		return false
+	}
+	for i := range exps1 {
+		if !Equal(exps1[i], exps2[i]) {
+			return false
+		}
+	}
+	return true
 }
 
-// GetVariable 获取表达式使用的变量名称列表
+// constantEqual 编译之后的正则比较 pattern，note NaN 和自己不相等
+func constantEqual(v1, v2 interface{}) bool {
+	if r1, ok := v1.(*regexp.Regexp); ok {
+		r2, ok := v2.(*regexp.Regexp)
+		return ok && r1.String() == r2.String()
+	}
+	return reflect.DeepEqual(v1, v2)
+}
+
+// GetVariable 获取表达式使用的变量名称列表，按照第一次出现的顺序，不包括 lambda 的参数
+//
+//	比如 map(items, x -> x.price * rate) 使用的变量是 items 和 rate，成员访问只包括最外层的变量
 func GetVariable(exp Expression) []string {
-	panic("没有实现")
+	names := make([]string, 0)
+	collectVariables(exp, map[string]bool{}, map[string]bool{}, &names)
+	return names
+}
+
+// collectVariables bound 是当前作用域中 lambda 的参数
+func collectVariables(exp Expression, bound map[string]bool, seen map[string]bool, names *[]string) {
+	if exp == nil {
+		return
+	}
+
+	WalkDeepFirst(exp, func(deep int, exp Expression) WalkControl {
+		switch e := exp.(type) {
+		case *VariableNode:
+			if !bound[e.name] && !seen[e.name] {
+				seen[e.name] = true
+				*names = append(*names, e.name)
+			}
+		case *LambdaExpression:
+			scope := make(map[string]bool, len(bound)+len(e.params))
+			for name := range bound {
+				scope[name] = true
+			}
+			for _, param := range e.params {
+				scope[param] = true
+			}
+			collectVariables(e.body, scope, seen, names)
+			return Abort
+		}
+		return Continue
+	})
 }
 
-// GetFuncNames 获取表达式的使用的函数名称列表
+// GetFuncNames 获取表达式的使用的函数名称列表，按照第一次出现的顺序，不包括方法调用
 func GetFuncNames(exp Expression) []string {
-	panic("没有实现")
+	names := make([]string, 0)
+	if exp == nil {
+		return names
+	}
+
+	seen := make(map[string]bool)
+	WalkDeepFirst(exp, func(deep int, exp Expression) WalkControl {
+		if e, ok := exp.(*FuncExpression); ok && !seen[e.funcName.name] {
+			seen[e.funcName.name] = true
+			names = append(names, e.funcName.name)
+		}
+		return Continue
+	})
+	return names
 }
@@ -1 +1,90 @@
 package ast
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	equalExps := [][2]string{
+		{"a + b * c", "a+b*c"},
+		{"(a + b) * c", "((a + b)) * c"},
+		{"f(a, 1) > 2 && b", "f(a,1)>2&&b"},
+		{"a ? [1, 'x'] : b.c[0]", "a ? [1, \"x\"] : b.c[0]"},
+		{"items[1:]", "items[1:]"},
+		{"map(items, x -> x * 2)", "map(items, x -> x*2)"},
+		{"-a ** 2", "-a ** 2"},
+		{"1.0 + 5m", "1.0 + 5m"},
+	}
+	for _, pair := range equalExps {
+		e1, err := Parse(pair[0])
+		assert.Nil(t, err, pair[0])
+		e2, err := Parse(pair[1])
+		assert.Nil(t, err, pair[1])
+		assert.True(t, Equal(e1, e2), pair[0]+" == "+pair[1])
+	}
+
+	notEqualExps := [][2]string{
+		{"a + b", "b + a"},
+		{"a + b", "a - b"},
+		{"a + b + c", "a + b"},
+		{"1", "1.0"},
+		{"'1'", "1"},
+		{"f(a)", "g(a)"},
+		{"f(a)", "f(a, b)"},
+		{"a.b", "a.c"},
+		{"items[1:]", "items[:1]"},
+		{"map(items, x -> x)", "map(items, y -> y)"},
+		{"-a", "!a"},
+		{"a", "nil"},
+	}
+	for _, pair := range notEqualExps {
+		e1, err := Parse(pair[0])
+		assert.Nil(t, err, pair[0])
+		e2, err := Parse(pair[1])
+		assert.Nil(t, err, pair[1])
+		assert.False(t, Equal(e1, e2), pair[0]+" != "+pair[1])
+	}
+
+	assert.True(t, Equal(nil, nil))
+	assert.False(t, Equal(&VariableNode{name: "a"}, nil))
+	assert.True(t, Equal(&SubNode{subNode: &VariableNode{name: "a"}}, &VariableNode{name: "a"}))
+	assert.True(t, Equal(&ConstantNode{value: []interface{}{int64(1)}}, &ConstantNode{value: []interface{}{int64(1)}}))
+}
+
+func TestGetVariable(t *testing.T) {
+	variablesByExp := map[string][]string{
+		"1 + 2":                                   {},
+		"a + b * a":                               {"a", "b"},
+		"order.customer.level > min(level, 3)":    {"order", "level"},
+		"map(items, x -> x.price * rate)":         {"items", "rate"},
+		"map(items, x -> x) + [x]":                {"items", "x"},
+		"reduce(items, (acc, x) -> acc + x, acc)": {"items", "acc"},
+		"filter(a, x -> any(x.tags, t -> t == x.name || t == tag))": {"a", "tag"},
+		"user.FullName(prefix)": {"user", "prefix"},
+		"arr[low:]":             {"arr", "low"},
+		"a ? b : c":             {"a", "b", "c"},
+	}
+	for exp, expected := range variablesByExp {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		assert.Equal(t, expected, GetVariable(expression), exp)
+	}
+	assert.Equal(t, []string{}, GetVariable(nil))
+}
+
+func TestGetFuncNames(t *testing.T) {
+	funcsByExp := map[string][]string{
+		"a + b":                              {},
+		"max(a, min(b, 1)) + max(c, 2)":      {"max", "min"},
+		"map(items, x -> upper(x.name))":     {"map", "upper"},
+		"user.FullName() + lower(user.name)": {"lower"},
+		"a > 1 ? abs(a) : len(name)":         {"abs", "len"},
+	}
+	for exp, expected := range funcsByExp {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		assert.Equal(t, expected, GetFuncNames(expression), exp)
+	}
+	assert.Equal(t, []string{}, GetFuncNames(nil))
+}
@@ -18,6 +18,8 @@
 // 构造出得ast在vm中进行执行是，应该只是可读取、但是不可修改的，所以这里设置了一些getter方法，对于集合的导出也是保护性拷贝
 package ast
 
+import "time"
+
 // Node 语法的开始字符
 type Node interface {
 	node()
@@ -28,6 +30,53 @@ type Expression interface {
 	expression()
 }
 
+// ConditionalExpression 三元表达式 condition ? then : else
+//
+//	note 三元表达式是右结合的，a ? b : c ? d : e 等价于 a ? b : (c ? d : e)，
+//		 在计算的时候只会计算 condition 选中的分支
+type ConditionalExpression struct {
+	condition Expression
+	then      Expression
+	otherwise Expression
+}
+
+func (*ConditionalExpression) node()       {}
+func (*ConditionalExpression) expression() {}
+
+func (cond *ConditionalExpression) Condition() Expression {
+	return cond.condition
+}
+
+func (cond *ConditionalExpression) Then() Expression {
+	return cond.then
+}
+
+func (cond *ConditionalExpression) Else() Expression {
+	return cond.otherwise
+}
+
+// LambdaExpression 匿名函数，x -> x.price > 10、(acc, x) -> acc + x
+//
+//	note 参数只在 body 中可见，并且会覆盖 env 中的同名变量
+type LambdaExpression struct {
+	params []string
+	body   Expression
+}
+
+func (*LambdaExpression) node()       {}
+func (*LambdaExpression) expression() {}
+
+// GetParams 返回保护性拷贝
+func (lambda *LambdaExpression) GetParams() []string {
+	forCopy := make([]string, len(lambda.params))
+	copy(forCopy, lambda.params)
+	return forCopy
+}
+
+func (lambda *LambdaExpression) Body() Expression {
+	return lambda.body
+}
+
 type UnaryExpression struct {
 	op  OperatorNode
 	exp Expression
@@ -51,7 +100,7 @@ func (*UnaryExpression) expression() {}
 type BinaryExpression struct {
 	left      Expression
 	priority OperatorPriority
-	// todo 结合性
+	// 结合性由优先级决定，见 Associativity
 	arguments []binaryExpArgument
 }
 
@@ -62,6 +111,11 @@ func (funcExp *BinaryExpression) Left() Expression {
 	return funcExp.left
 }
 
+// Associativity 同一个二元表达式中的运算符优先级相同，所以结合性也相同
+func (funcExp *BinaryExpression) Associativity() OperatorAssociativity {
+	return funcExp.priority.associativity()
+}
+
 // GetArguments 返回保护性拷贝
 func (funcExp *BinaryExpression) GetArguments() []binaryExpArgument {
 	forCopy := make([]binaryExpArgument, len(funcExp.arguments))
@@ -127,8 +181,15 @@ func (*VariableNode) atomic()                  {}
 func (*VariableNode) expression()              {}
 func (variable *VariableNode) GetName() string { return variable.name }
 
+// StringNode 字符串常量
+//
+//	value 是去掉引号、处理过转义字符之后的值，literal 是表达式中的原始文本
 type StringNode struct {
-	value string
+	literal string
+	value   string
+	// line 和 column 是字符串在表达式中的位置，用于报告正则表达式等常量的错误
+	line   int
+	column int
 }
 
 func (*StringNode) node()       {}
@@ -139,17 +200,196 @@ func (node *StringNode) GetStringValue() string {
 	if node == nil {
 		return ""
 	}
-	return node.value[1 : len(node.value)-1]
+	return node.value
 }
 
+// GetLiteral 字符串在表达式中的原始文本，包括引号
+func (node *StringNode) GetLiteral() string {
+	return node.literal
+}
+
+// GetPosition 字符串在表达式中的行和列，从 1 开始
+func (node *StringNode) GetPosition() (line, column int) {
+	return node.line, node.column
+}
+
+// NumberNode 数字常量
+//
+//	整数使用 int64 表示，小数和科学计数法使用 float64 表示
 type NumberNode struct {
-	Value int64
+	literal    string
+	isFloat    bool
+	intValue   int64
+	floatValue float64
 }
 
 func (*NumberNode) node()       {}
 func (*NumberNode) atomic()     {}
 func (*NumberNode) expression() {}
 
+// IsFloat 是否是小数或者科学计数法表示的数字
+func (node *NumberNode) IsFloat() bool { return node.isFloat }
+
+// GetLiteral 数字在表达式中的原始文本
+func (node *NumberNode) GetLiteral() string { return node.literal }
+
+// GetValue 返回 int64 或者 float64
+func (node *NumberNode) GetValue() interface{} {
+	if node.isFloat {
+		return node.floatValue
+	}
+	return node.intValue
+}
+
+// DurationNode 时间间隔常量，5m、1h30m，单位和 time.ParseDuration 相同
+type DurationNode struct {
+	literal string
+	value   time.Duration
+}
+
+func (*DurationNode) node()       {}
+func (*DurationNode) atomic()     {}
+func (*DurationNode) expression() {}
+
+// GetLiteral 时间间隔在表达式中的原始文本
+func (node *DurationNode) GetLiteral() string { return node.literal }
+
+func (node *DurationNode) GetValue() time.Duration { return node.value }
+
+// ConstantNode 优化阶段计算出来的常量，比如 a in ['US', 'CA'] 中的 ['US', 'CA']
+//
+//	note value 会被多次计算共享，所以不能被修改
+type ConstantNode struct {
+	value interface{}
+}
+
+func (*ConstantNode) node()       {}
+func (*ConstantNode) atomic()     {}
+func (*ConstantNode) expression() {}
+
+func (node *ConstantNode) GetValue() interface{} { return node.value }
+
+// ArrayExpression 数组，[1, 2, a]
+type ArrayExpression struct {
+	elements []Expression
+}
+
+func (*ArrayExpression) node()       {}
+func (*ArrayExpression) atomic()     {}
+func (*ArrayExpression) expression() {}
+
+// GetElements 返回保护性拷贝
+func (array *ArrayExpression) GetElements() []Expression {
+	forCopy := make([]Expression, len(array.elements))
+	copy(forCopy, array.elements)
+	return forCopy
+}
+
+// IndexExpression 下标访问，arr[0]、m['key']
+type IndexExpression struct {
+	object Expression
+	index  Expression
+}
+
+func (*IndexExpression) node()       {}
+func (*IndexExpression) atomic()     {}
+func (*IndexExpression) expression() {}
+
+func (index *IndexExpression) Object() Expression {
+	return index.object
+}
+
+func (index *IndexExpression) Index() Expression {
+	return index.index
+}
+
+// SliceExpression 切片，arr[1:3]
+//
+//	note low 和 high 都可以省略，比如 arr[:3]、arr[1:]，省略时对应的值为 nil
+type SliceExpression struct {
+	object Expression
+	low    Expression
+	high   Expression
+}
+
+func (*SliceExpression) node()       {}
+func (*SliceExpression) atomic()     {}
+func (*SliceExpression) expression() {}
+
+func (slice *SliceExpression) Object() Expression {
+	return slice.object
+}
+
+func (slice *SliceExpression) Low() Expression {
+	return slice.low
+}
+
+func (slice *SliceExpression) High() Expression {
+	return slice.high
+}
+
+// MemberExpression 成员访问，order.customer 中 order 是 object、customer 是 name
+type MemberExpression struct {
+	object Expression
+	name   string
+}
+
+func (*MemberExpression) node()       {}
+func (*MemberExpression) atomic()     {}
+func (*MemberExpression) expression() {}
+
+func (member *MemberExpression) Object() Expression {
+	return member.object
+}
+
+func (member *MemberExpression) GetName() string {
+	return member.name
+}
+
+// MethodCallExpression 方法调用，user.FullName() 中 user 是 object、FullName 是 name
+type MethodCallExpression struct {
+	object    Expression
+	name      string
+	arguments []Expression
+}
+
+func (*MethodCallExpression) node()       {}
+func (*MethodCallExpression) atomic()     {}
+func (*MethodCallExpression) expression() {}
+
+func (call *MethodCallExpression) Object() Expression {
+	return call.object
+}
+
+func (call *MethodCallExpression) GetName() string {
+	return call.name
+}
+
+// GetArguments 返回保护性拷贝
+func (call *MethodCallExpression) GetArguments() []Expression {
+	forCopy := make([]Expression, len(call.arguments))
+	copy(forCopy, call.arguments)
+	return forCopy
+}
+
+// BoolNode 布尔常量，true 或者 false
+type BoolNode struct {
+	value bool
+}
+
+func (*BoolNode) node()               {}
+func (*BoolNode) atomic()             {}
+func (*BoolNode) expression()         {}
+func (node *BoolNode) GetValue() bool { return node.value }
+
+// NullNode 空值常量，nil 或者 null，计算结果为 go 中的 nil
+type NullNode struct {
+}
+
+func (*NullNode) node()       {}
+func (*NullNode) atomic()     {}
+func (*NullNode) expression() {}
+
 // OperatorNode
 // 运算符
 type OperatorNode struct {
@@ -181,6 +421,9 @@ func (*ControlNode) atomic() {}
 //
 //	| string
 //	| number
+//	| bool
+//	| null
+//	| array
 //	| Func
 //	| '(' Expression ')' note
 type SubNode struct {
@@ -194,4 +437,80 @@ func (*SubNode) atomic()     {}
 func (*SubNode) expression() {}
 func (n *SubNode) SubNode() Expression {
 	return n.subNode
-}
\ No newline at end of file
+}
+
+// IdentityExpression 优化阶段生成的恒等运算，比如 x * 1、0 + x，见 simplifyIdentities
+//
+//	x 的值满足 IsIdentity 时运算结果就是 x 的值，否则使用 x 的值按照原来的运算计算，
+//	比如 x 是字符串时 x + 0 是字符串拼接
+type IdentityExpression struct {
+	exp     Expression
+	op      OperatorNode
+	operand Expression
+	// operandFirst operand 是否在运算符的左边，比如 1 * x
+	operandFirst bool
+	identity     func(val interface{}) bool
+}
+
+func (*IdentityExpression) node()       {}
+func (*IdentityExpression) expression() {}
+
+func (identity *IdentityExpression) Exp() Expression {
+	return identity.exp
+}
+
+func (identity *IdentityExpression) Op() OperatorNode {
+	return identity.op
+}
+
+// Operand 恒等运算中的常量，比如 x * 1 中的 1
+func (identity *IdentityExpression) Operand() Expression {
+	return identity.operand
+}
+
+func (identity *IdentityExpression) OperandFirst() bool {
+	return identity.operandFirst
+}
+
+// IsIdentity x 的值是 val 时运算结果是否就是 val
+func (identity *IdentityExpression) IsIdentity(val interface{}) bool {
+	return identity.identity != nil && identity.identity(val)
+}
+
+// CommonScope 优化阶段生成的公共子表达式的作用域，一次计算中 id 相同的 CommonExpression 只计算一次，
+// 见 eliminateCommonSubexpressions
+type CommonScope struct {
+	exp Expression
+	// count CommonExpression 的 id 的个数，id 从 0 开始
+	count int
+}
+
+func (*CommonScope) node()       {}
+func (*CommonScope) expression() {}
+
+func (scope *CommonScope) Exp() Expression {
+	return scope.exp
+}
+
+func (scope *CommonScope) Count() int {
+	return scope.count
+}
+
+// CommonExpression 公共子表达式，比如 (a + b) * (a + b) 中的 a + b
+//
+//	note 第一次计算的时候保存结果，没有被计算的分支中的公共子表达式不会被提前计算
+type CommonExpression struct {
+	id  int
+	exp Expression
+}
+
+func (*CommonExpression) node()       {}
+func (*CommonExpression) expression() {}
+
+func (common *CommonExpression) GetId() int {
+	return common.id
+}
+
+func (common *CommonExpression) Exp() Expression {
+	return common.exp
+}
@@ -16,13 +16,26 @@ const (
 type OperatorPriority int
 
 const (
-	firstLevelOp  OperatorPriority = iota + 1 // +, -
-	secondLevelOp                             // *, /, %
+	logicalOrLevelOp  OperatorPriority = iota + 1 // ||, or
+	logicalAndLevelOp                             // &&, and
+	equalityLevelOp                               // ==, !=, =~, matches
+	relationalLevelOp                             // <, <=, >, >=, in, not in, between
+	rangeLevelOp                                  // ..
+	bitOrLevelOp                                  // |
+	bitXorLevelOp                                 // ^
+	bitAndLevelOp                                 // &
+	shiftLevelOp                                  // <<, >>
+	firstLevelOp                                  // +, -
+	secondLevelOp                                 // *, /, %
+	powerLevelOp                                  // **，右结合
 
 	// 最大优先级运算符+1
 	highestLevelOpPlusOne
 )
 
+// lowestLevelOp 最低优先级的运算符，表达式从最低优先级开始解析
+const lowestLevelOp = logicalOrLevelOp
+
 func (p OperatorPriority) getIncrement() OperatorPriority {
 	if p.isHighestLevelOp() {
 		// should not happen
@@ -31,13 +44,44 @@ func (p OperatorPriority) getIncrement() OperatorPriority {
 	return p + 1
 }
 
+// associativity 只有 ** 是右结合的，2 ** 3 ** 2 等价于 2 ** (3 ** 2)
+func (p OperatorPriority) associativity() OperatorAssociativity {
+	if p == powerLevelOp {
+		return RightAssociativity
+	}
+	return LeftAssociativity
+}
+
 // isHighestLevelOp 是否是最高优先级的运算符
 func (p OperatorPriority) isHighestLevelOp() bool {
 	return p == (highestLevelOpPlusOne - 1)
 }
 
 var unaryOperator = map[string]bool{
-	"+": true, "-": true,
+	"+": true, "-": true, "!": true, "not": true,
+}
+
+var logicalOrOperator = map[string]bool{
+	"||": true, "or": true,
+}
+
+var logicalAndOperator = map[string]bool{
+	"&&": true, "and": true,
+}
+
+// equalityOperator note =~ 和 matches 是正则匹配，右边是字符串常量时在优化阶段编译，见 compilePatterns
+var equalityOperator = map[string]bool{
+	"==": true, "!=": true, "=~": true, "matches": true,
+}
+
+// relationalOperator note a between 1 and 10 中的 1 and 10 在语法分析时被转换为范围 1..10
+var relationalOperator = map[string]bool{
+	"<": true, "<=": true, ">": true, ">=": true, "in": true, "not in": true, "between": true,
+}
+
+// rangeOperator 闭区间，1..10 包括 1 和 10
+var rangeOperator = map[string]bool{
+	"..": true,
 }
 
 var firstOperator = map[string]bool{
@@ -48,6 +92,56 @@ var secondOperator = map[string]bool{
 	"*": true, "/": true, "%": true,
 }
 
+var bitOrOperator = map[string]bool{
+	"|": true,
+}
+
+var bitXorOperator = map[string]bool{
+	"^": true,
+}
+
+var bitAndOperator = map[string]bool{
+	"&": true,
+}
+
+var shiftOperator = map[string]bool{
+	"<<": true, ">>": true,
+}
+
+// powerOperator note 一元运算符的优先级比 ** 高，-2 ** 2 等价于 (-2) ** 2
+var powerOperator = map[string]bool{
+	"**": true,
+}
+
 var operatorByPriority = map[OperatorPriority]map[string]bool{
-	firstLevelOp: firstOperator, secondLevelOp: secondOperator,
+	logicalOrLevelOp:  logicalOrOperator,
+	logicalAndLevelOp: logicalAndOperator,
+	equalityLevelOp:   equalityOperator,
+	relationalLevelOp: relationalOperator,
+	rangeLevelOp:      rangeOperator,
+	bitOrLevelOp:      bitOrOperator,
+	bitXorLevelOp:     bitXorOperator,
+	bitAndLevelOp:     bitAndOperator,
+	shiftLevelOp:      shiftOperator,
+	firstLevelOp:      firstOperator,
+	secondLevelOp:     secondOperator,
+	powerLevelOp:      powerOperator,
+}
+
+// symbolOperators 符号形式的运算符，词法分析时按照最长匹配的方式识别，所以长的运算符需要放在前边
+var symbolOperators = []string{
+	"==", "!=", "=~", "<=", ">=", "&&", "||", "->", "..", "**", "<<", ">>",
+	"+", "-", "*", "/", "%", "<", ">", "!", "?", ":", ".", "&", "|", "^",
+}
+
+// keywordOperators 关键字形式的运算符，note 关键字不能再作为变量名使用
+//
+//	not in 由 not 和 in 两个关键字组成，词法分析时合并为一个运算符
+var keywordOperators = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "between": true, "matches": true,
+}
+
+// keywordLiterals 关键字形式的常量，note 同关键字运算符一样，不能再作为变量名使用
+var keywordLiterals = map[string]tokenKind{
+	"true": Bool, "false": Bool, "nil": Null, "null": Null,
 }
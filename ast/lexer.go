@@ -3,7 +3,11 @@ package ast
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 func getAllTokens(exp string) ([]Token, error) {
@@ -44,9 +48,10 @@ func (lexer *lexer) getTokens() ([]Token, error) {
 	return tokens, nil
 }
 
-// Operator + - * / % 函数
-// Number 数字
+// Operator + - * / % == != < <= > >= && || ! and or not 函数
+// Number 数字，包括整数、小数和科学计数法
 // Variable 变量
+// Bool、Null true、false、nil、null
 func (lexer *lexer) getNextToken() (*Token, error) {
 
 	chPtr := lexer.getNextRune()
@@ -59,82 +64,77 @@ func (lexer *lexer) getNextToken() (*Token, error) {
 	switch {
 	case isBasicOperator(ch):
 		pos := lexer.offset
+		operator, ok := lexer.scanOperator()
+		if !ok {
+			errorMsg := fmt.Sprintf("invalid operator '%s':%d:%d:\n %s...",
+				string(ch), len(lexer.lines), pos-lexer.lines[len(lexer.lines)-1], string(lexer.source[0:lexer.offset]))
+			return nil, errors.New(errorMsg)
+		}
 		return &Token{
 			kind:   Operator,
-			value:  string(ch),
+			value:  operator,
 			line:   len(lexer.lines),
 			column: pos - int(lexer.lines[len(lexer.lines)-1]),
 		}, nil
 	case unicode.IsDigit(ch):
-		// todo 小数、科学计数法
 		pos := lexer.offset
+		start := lexer.offset - 1
+		lexer.scanNumber()
 
-		start, end := lexer.offset-1, lexer.offset
-		next := lexer.getNextRune()
-		for next != nil && unicode.IsDigit(*next) {
-			next = lexer.getNextRune()
-			end++
-		}
+		// 数字后边紧跟着单位的是时间间隔，比如 5m、1.5h、1h30m
+		if next := lexer.peekRune(0); next != nil && unicode.IsLetter(*next) {
+			lexer.scanDuration()
+			literal := string(lexer.source[start:lexer.offset])
+			if _, err := time.ParseDuration(literal); err != nil {
+				errorMsg := fmt.Sprintf("invalid duration '%s':%d:%d",
+					literal, len(lexer.lines), pos-lexer.lines[len(lexer.lines)-1])
+				return nil, errors.New(errorMsg)
+			}
 
-		if next != nil {
-			lexer.rollbackRune()
+			return &Token{
+				kind:   Duration,
+				value:  literal,
+				line:   len(lexer.lines),
+				column: pos - lexer.lines[len(lexer.lines)-1],
+			}, nil
 		}
 
 		return &Token{
 			kind:  Number,
-			value: string(lexer.source[start:end]),
+			value: string(lexer.source[start:lexer.offset]),
 			line:  len(lexer.lines),
 			// note column 应该从开始字符开始算
 			column: pos - lexer.lines[len(lexer.lines)-1],
 		}, nil
 	case isQuote(ch):
-		isSingleQuote := ch == '\''
+		quote := ch
 		pos := lexer.offset
-		start, end := lexer.offset-1, lexer.offset
-		if isSingleQuote {
-			var escape bool = false
-			next := lexer.getNextRune()
-			for next != nil && (*next != '\'' || escape) {
-				// note 用于判断转义
-				if *next == '\\' && !escape {
-					escape = true
-				} else if *next == '\'' {
-					escape = false
-				}
-				next = lexer.getNextRune()
-				end++
-			}
-
-			if *next == '\'' {
-				end++
-			}
-		} else {
-			var escape bool = false
-			next := lexer.getNextRune()
-			for next != nil && (*next != '"' || escape) {
-				if *next == '\\' && !escape {
-					escape = true
-				} else if *next == '"' {
-					escape = false
-				}
-				next = lexer.getNextRune()
-				end++
-			}
-
-			if *next == '"' {
-				end++
+		line, lineOffset := len(lexer.lines), lexer.lines[len(lexer.lines)-1]
+		start := lexer.offset - 1
+		var escape bool = false
+		next := lexer.getNextRune()
+		// note 用于判断转义，反引号中的字符串不处理转义
+		for next != nil && (*next != quote || escape) {
+			escape = quote != '`' && *next == '\\' && !escape
+			// 反引号中的字符串可以换行
+			if *next == '\n' {
+				lexer.lines = append(lexer.lines, lexer.offset)
 			}
+			next = lexer.getNextRune()
 		}
-		// todo 结束的时候应该判断是否是正常结束，比如是否有 " 或者 '
-		// 最后一个不属于字符串，所以回滚
-		if !lexer.scanToEnd() {
-			lexer.rollbackRune()
+
+		// 结束的时候判断是否是正常结束，即是否有闭合的 " 或者 '
+		if next == nil {
+			errorMsg := fmt.Sprintf("unterminated string %s:%d:%d",
+				string(lexer.source[start:]), line, pos-lineOffset)
+			return nil, errors.New(errorMsg)
 		}
+
 		return &Token{
 			kind:   String,
-			value:  string(lexer.source[start:end]),
-			line:   len(lexer.lines),
-			column: pos - int(lexer.lines[len(lexer.lines)-1]),
+			value:  string(lexer.source[start:lexer.offset]),
+			line:   line,
+			column: pos - lineOffset,
 		}, nil
 
 	case unicode.IsLetter(ch) || ch == '_':
@@ -153,6 +153,31 @@ func (lexer *lexer) getNextToken() (*Token, error) {
 			lexer.rollbackRune()
 		}
 
+		// 关键字形式的常量，比如 true、false、nil
+		if kind, ok := keywordLiterals[string(lexer.source[start:end])]; ok {
+			return &Token{
+				kind:   kind,
+				value:  string(lexer.source[start:end]),
+				line:   len(lexer.lines),
+				column: pos - lexer.lines[len(lexer.lines)-1],
+			}, nil
+		}
+
+		// 关键字形式的运算符，比如 and、or、not
+		if keywordOperators[string(lexer.source[start:end])] {
+			value := string(lexer.source[start:end])
+			if value == "not" && lexer.scanNotIn() {
+				value = "not in"
+			}
+
+			return &Token{
+				kind:   Operator,
+				value:  value,
+				line:   len(lexer.lines),
+				column: pos - lexer.lines[len(lexer.lines)-1],
+			}, nil
+		}
+
 		// 如果是以字母结尾，或者 即使不是结尾、但字母token后边跟着的不是 (，则该字母所在的字符串是变量
 		if next == nil || *next != '(' {
 			return &Token{
@@ -178,6 +203,13 @@ func (lexer *lexer) getNextToken() (*Token, error) {
 			line:   len(lexer.lines),
 			column: pos - int(lexer.lines[len(lexer.lines)-1]),
 		}, nil
+	case isBracket(ch):
+		pos := lexer.offset
+		return &Token{
+			kind: Bracket, value: string(ch),
+			line:   len(lexer.lines),
+			column: pos - int(lexer.lines[len(lexer.lines)-1]),
+		}, nil
 	case ch == '\n':
 		pos := lexer.offset
 		tokenPtr := &Token{
@@ -219,6 +251,10 @@ func isParen(ch rune) bool {
 	return ch == '(' || ch == ')'
 }
 
+func isBracket(ch rune) bool {
+	return ch == '[' || ch == ']'
+}
+
 func (lexer *lexer) getNextRune() *rune {
 	// 边界条件，已经遍历完了所有数据
 	if lexer.offset == len(lexer.source) {
@@ -230,6 +266,97 @@ func (lexer *lexer) getNextRune() *rune {
 	return &ch
 }
 
+// peekRune 查看当前位置之后第 n 个字符(从0开始)，不移动 offset
+func (lexer *lexer) peekRune(n int) *rune {
+	if lexer.offset+n >= len(lexer.source) {
+		return nil
+	}
+
+	ch := lexer.source[lexer.offset+n]
+	return &ch
+}
+
+// scanNumber 扫描数字的剩余部分，第一个数字已经被读取
+//
+//	整数：123
+//	小数：1.5，note '.' 后边必须是数字，否则 '.' 不属于该数字
+//	科学计数法：1e3、1.5E-3，note 'e' 后边必须是数字或者 '+/-' 加数字，否则 'e' 不属于该数字
+func (lexer *lexer) scanNumber() {
+	lexer.scanDigits()
+
+	if next, afterNext := lexer.peekRune(0), lexer.peekRune(1); next != nil && *next == '.' &&
+		afterNext != nil && unicode.IsDigit(*afterNext) {
+		lexer.getNextRune() // swallow '.'
+		lexer.scanDigits()
+	}
+
+	next := lexer.peekRune(0)
+	if next == nil || (*next != 'e' && *next != 'E') {
+		return
+	}
+
+	digitOffset := 1
+	if sign := lexer.peekRune(1); sign != nil && (*sign == '+' || *sign == '-') {
+		digitOffset = 2
+	}
+	if digit := lexer.peekRune(digitOffset); digit == nil || !unicode.IsDigit(*digit) {
+		return
+	}
+
+	for i := 0; i < digitOffset; i++ {
+		lexer.getNextRune() // swallow 'e' 以及符号
+	}
+	lexer.scanDigits()
+}
+
+// scanNotIn not 后边是否是关键字 in，是的话吞掉 in，note not 和 in 之间只能是空格或者 tab
+func (lexer *lexer) scanNotIn() bool {
+	i := 0
+	for next := lexer.peekRune(i); next != nil && (*next == ' ' || *next == '\t'); next = lexer.peekRune(i) {
+		i++
+	}
+
+	if i == 0 {
+		return false
+	}
+	if ch := lexer.peekRune(i); ch == nil || *ch != 'i' {
+		return false
+	}
+	if ch := lexer.peekRune(i + 1); ch == nil || *ch != 'n' {
+		return false
+	}
+	if ch := lexer.peekRune(i + 2); ch != nil && (unicode.IsLetter(*ch) || unicode.IsNumber(*ch) || *ch == '_') {
+		return false
+	}
+
+	for j := 0; j < i+2; j++ {
+		lexer.getNextRune()
+	}
+	return true
+}
+
+// scanDuration 扫描时间间隔的单位以及后续的数字，比如 5m 中的 m、1h30m 中的 h30m
+//
+//	note 只负责切分，是否合法由 time.ParseDuration 判断
+func (lexer *lexer) scanDuration() {
+	for next := lexer.peekRune(0); next != nil; next = lexer.peekRune(0) {
+		if *next == '.' {
+			if afterNext := lexer.peekRune(1); afterNext == nil || !unicode.IsDigit(*afterNext) {
+				return
+			}
+		} else if !unicode.IsLetter(*next) && !unicode.IsDigit(*next) {
+			return
+		}
+		lexer.getNextRune()
+	}
+}
+
+func (lexer *lexer) scanDigits() {
+	for next := lexer.peekRune(0); next != nil && unicode.IsDigit(*next); next = lexer.peekRune(0) {
+		lexer.getNextRune()
+	}
+}
+
 func (lexer *lexer) rollbackRune() {
 	lexer.offset = lexer.offset - 1
 }
@@ -239,9 +366,99 @@ func (lexer *lexer) scanToEnd() bool {
 }
 
 func isQuote(ch rune) bool {
-	return ch == '\'' || ch == '"'
+	return ch == '\'' || ch == '"' || ch == '`'
 }
 
+// unquote 去掉字符串的引号并处理转义字符
+//
+//	单引号和双引号中的字符串支持 \n \t \r \\ \' \" \a \b \f \v \0 \xHH \uHHHH \UHHHHHHHH
+//	反引号中的字符串是原始字符串，不处理转义
+func unquote(literal string) (string, error) {
+	runes := []rune(literal)
+	if len(runes) < 2 || runes[0] != runes[len(runes)-1] || !isQuote(runes[0]) {
+		return "", errors.New("string should be quoted")
+	}
+
+	quote, content := runes[0], runes[1:len(runes)-1]
+	if quote == '`' {
+		return string(content), nil
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(content); i++ {
+		if content[i] != '\\' {
+			builder.WriteRune(content[i])
+			continue
+		}
+
+		i++
+		if i == len(content) {
+			return "", errors.New("unterminated escape sequence")
+		}
+
+		switch content[i] {
+		case 'n':
+			builder.WriteRune('\n')
+		case 't':
+			builder.WriteRune('\t')
+		case 'r':
+			builder.WriteRune('\r')
+		case 'a':
+			builder.WriteRune('\a')
+		case 'b':
+			builder.WriteRune('\b')
+		case 'f':
+			builder.WriteRune('\f')
+		case 'v':
+			builder.WriteRune('\v')
+		case '0':
+			builder.WriteRune(0)
+		case '\\', '\'', '"':
+			builder.WriteRune(content[i])
+		case 'x', 'u', 'U':
+			// note \xHH 按照码点处理，保证结果是合法的 utf8
+			size := map[rune]int{'x': 2, 'u': 4, 'U': 8}[content[i]]
+			if i+size >= len(content) {
+				return "", fmt.Errorf("invalid escape sequence \\%c, expected %d hex digits", content[i], size)
+			}
+			code, err := strconv.ParseUint(string(content[i+1:i+1+size]), 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("invalid escape sequence \\%s", string(content[i:i+1+size]))
+			}
+			builder.WriteRune(rune(code))
+			i += size
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c", content[i])
+		}
+	}
+
+	return builder.String(), nil
+}
+
+// isBasicOperator 是否是符号运算符的第一个字符
 func isBasicOperator(ch rune) bool {
-	return ch == '+' || ch == '-' || ch == '*' || ch == '/' || ch == '%'
+	for _, operator := range symbolOperators {
+		if []rune(operator)[0] == ch {
+			return true
+		}
+	}
+	return false
+}
+
+// scanOperator 按照最长匹配的方式扫描符号运算符，第一个字符已经被读取
+//
+//	比如 '<=' 不会被识别为 '<' 和 '='
+func (lexer *lexer) scanOperator() (string, bool) {
+	start := lexer.offset - 1
+	for _, operator := range symbolOperators {
+		runes := []rune(operator)
+		if start+len(runes) > len(lexer.source) || string(lexer.source[start:start+len(runes)]) != operator {
+			continue
+		}
+
+		lexer.offset = start + len(runes)
+		return operator, true
+	}
+
+	return "", false
 }
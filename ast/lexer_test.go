@@ -14,3 +14,28 @@ func TestGetAllTokens(t *testing.T) {
 	}
 }
 
+func TestGetOperatorTokens(t *testing.T) {
+	tokens, err := getAllTokens("a<=b>=c==d!=e<f>g&&h||!i and j or not k")
+	assert.Nil(t, err)
+
+	operators := make([]string, 0)
+	for _, token := range tokens {
+		if token.kind == Operator {
+			operators = append(operators, token.value)
+		}
+	}
+	assert.Equal(t, []string{"<=", ">=", "==", "!=", "<", ">", "&&", "||", "!", "and", "or", "not"}, operators)
+}
+
+func TestGetBitwiseOperatorTokens(t *testing.T) {
+	tokens, err := getAllTokens("a**b*c<<d<e>>f>=g&h&&i|j||k^l")
+	assert.Nil(t, err)
+
+	operators := make([]string, 0)
+	for _, token := range tokens {
+		if token.kind == Operator {
+			operators = append(operators, token.value)
+		}
+	}
+	assert.Equal(t, []string{"**", "*", "<<", "<", ">>", ">=", "&", "&&", "|", "||", "^"}, operators)
+}
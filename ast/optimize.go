@@ -2,12 +2,232 @@ package ast
 
 import "goscript/function"
 
-type OptimizeConfig struct{
+// OptimizeConfig 优化的配置，零值表示执行所有的优化
+//
+//	note decimal 模式下数字字面量转换为 function.Decimal 不是优化，不能关闭
+type OptimizeConfig struct {
+	// Arithmetic 常量折叠使用的运算规则，需要和执行时的规则相同，nil 表示 function.DefaultArithmetic
+	Arithmetic *function.Arithmetic
 
+	// WithoutConstantFold 不折叠常量子表达式，比如 -1、1 + 2 * 3、'a' + 'b'、true ? a : b，见 foldConstants
+	WithoutConstantFold bool
+	// WithoutFuncFold 不折叠参数都是常量的函数调用，只有 function.Function.AllowFold 为 true 的函数会被折叠
+	WithoutFuncFold bool
+	// WithoutSubNodeRemoval 保留括号对应的 SubNode，见 removeSubNodes
+	WithoutSubNodeRemoval bool
+	// WithoutMembershipFold 见 foldMembership
+	WithoutMembershipFold bool
+	// WithoutPatternCompile 见 compilePatterns，note 关闭之后不合法的 pattern 在执行阶段才返回错误
+	WithoutPatternCompile bool
+	// WithoutAlgebraicSimplify 不简化恒等运算，比如 x * 1、x + 0，见 simplifyIdentities
+	WithoutAlgebraicSimplify bool
+	// WithoutCommonSubexpression 结构相同的子表达式每次出现都重新计算，见 eliminateCommonSubexpressions
+	WithoutCommonSubexpression bool
 }
 
 func Optimize(exp Expression, udf map[string]function.Function) (Expression, error) {
+	return OptimizeWithConfig(exp, udf, OptimizeConfig{})
+}
+
+// OptimizeWithConfig 优化之后的表达式和原来的表达式计算结果相同，note 原来的表达式不会被修改
+func OptimizeWithConfig(exp Expression, udf map[string]function.Function, config OptimizeConfig) (Expression, error) {
+	arithmetic := config.Arithmetic
+	if arithmetic == nil {
+		arithmetic = function.DefaultArithmetic()
+	}
+
+	passes := []func(exp Expression) (Expression, error){
+		decimalLiterals(arithmetic),
+	}
+	if !config.WithoutSubNodeRemoval {
+		passes = append(passes, removeSubNodes)
+	}
+	// note 先编译 pattern 再折叠常量，这样 pattern 不合法时的错误信息中有 pattern 的位置
+	if !config.WithoutPatternCompile {
+		passes = append(passes, compilePatterns(udf))
+	}
+	if !config.WithoutConstantFold || !config.WithoutFuncFold {
+		passes = append(passes, foldConstants(arithmetic, udf, config))
+	}
+	if !config.WithoutMembershipFold {
+		passes = append(passes, foldMembership)
+	}
+	if !config.WithoutAlgebraicSimplify {
+		passes = append(passes, simplifyIdentities(arithmetic))
+	}
+
+	var err error
+	for _, pass := range passes {
+		if exp, err = transform(exp, pass); err != nil {
+			return nil, err
+		}
+	}
 
+	// note 公共子表达式需要统计整个表达式，所以在其他优化之后单独处理
+	if !config.WithoutCommonSubexpression {
+		return eliminateCommonSubexpressions(exp, udf)
+	}
 	return exp, nil
 }
 
+// transform 自底向上重建表达式，子表达式处理完之后再使用 f 处理当前表达式
+//
+//	note ast 是只读的，所以有子表达式发生变化的节点都会被拷贝，不会修改原来的节点
+func transform(exp Expression, f func(exp Expression) (Expression, error)) (Expression, error) {
+	return transformWith(exp, f, true)
+}
+
+// transformOutsideLambdas 和 transform 相同，但是不处理 lambda 的 body，
+// 因为 body 中的变量可能是 lambda 的参数，比如 map(items, a -> a * 2) 中的 a * 2 和外边的 a * 2 不同
+func transformOutsideLambdas(exp Expression, f func(exp Expression) (Expression, error)) (Expression, error) {
+	return transformWith(exp, f, false)
+}
+
+func transformWith(exp Expression, f func(exp Expression) (Expression, error), lambdaBody bool) (Expression, error) {
+	if exp == nil {
+		return nil, nil
+	}
+
+	var err error
+	switch e := exp.(type) {
+	case *ConditionalExpression:
+		copied := *e
+		if copied.condition, err = transformWith(e.condition, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		if copied.then, err = transformWith(e.then, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		if copied.otherwise, err = transformWith(e.otherwise, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *LambdaExpression:
+		if !lambdaBody {
+			break
+		}
+		copied := *e
+		if copied.body, err = transformWith(e.body, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *UnaryExpression:
+		copied := *e
+		if copied.exp, err = transformWith(e.exp, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *BinaryExpression:
+		copied := *e
+		if copied.left, err = transformWith(e.left, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		copied.arguments = make([]binaryExpArgument, len(e.arguments))
+		for i, argument := range e.arguments {
+			copied.arguments[i] = argument
+			if copied.arguments[i].arg, err = transformWith(argument.arg, f, lambdaBody); err != nil {
+				return nil, err
+			}
+		}
+		exp = &copied
+
+	case *FuncExpression:
+		copied := *e
+		if copied.arguments, err = transformAll(e.arguments, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *ArrayExpression:
+		copied := *e
+		if copied.elements, err = transformAll(e.elements, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *IndexExpression:
+		copied := *e
+		if copied.object, err = transformWith(e.object, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		if copied.index, err = transformWith(e.index, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *SliceExpression:
+		copied := *e
+		if copied.object, err = transformWith(e.object, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		if copied.low, err = transformWith(e.low, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		if copied.high, err = transformWith(e.high, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *MemberExpression:
+		copied := *e
+		if copied.object, err = transformWith(e.object, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *MethodCallExpression:
+		copied := *e
+		if copied.object, err = transformWith(e.object, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		if copied.arguments, err = transformAll(e.arguments, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *SubNode:
+		copied := *e
+		if copied.subNode, err = transformWith(e.subNode, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *IdentityExpression:
+		copied := *e
+		if copied.exp, err = transformWith(e.exp, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *CommonScope:
+		copied := *e
+		if copied.exp, err = transformWith(e.exp, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+
+	case *CommonExpression:
+		copied := *e
+		if copied.exp, err = transformWith(e.exp, f, lambdaBody); err != nil {
+			return nil, err
+		}
+		exp = &copied
+	}
+
+	return f(exp)
+}
+
+func transformAll(exps []Expression, f func(exp Expression) (Expression, error), lambdaBody bool) ([]Expression, error) {
+	result := make([]Expression, 0, len(exps))
+	for _, exp := range exps {
+		transformed, err := transformWith(exp, f, lambdaBody)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, transformed)
+	}
+	return result, nil
+}
@@ -1,3 +1,361 @@
 package ast
 
+import (
+	"goscript/function"
+	"math/big"
+	"time"
+)
 
+// foldMembership in、not in、between 右边是常量时预先计算右边的值，左边也是常量时直接计算结果
+//
+//	a in ['US', 'CA'] -> a in <ConstantNode>，数组不需要在每次计算时重新创建
+//	'US' in ['US', 'CA'] -> true
+func foldMembership(exp Expression) (Expression, error) {
+	binary, ok := exp.(*BinaryExpression)
+	if !ok || binary.priority != relationalLevelOp {
+		return exp, nil
+	}
+
+	allFolded := true
+	arguments := make([]binaryExpArgument, len(binary.arguments))
+	for i, argument := range binary.arguments {
+		arguments[i] = argument
+		if !isMembershipOperator(argument.op.op) {
+			allFolded = false
+			continue
+		}
+
+		value, ok := constantValue(argument.arg)
+		if !ok {
+			allFolded = false
+			continue
+		}
+		arguments[i].arg = &ConstantNode{value: value}
+	}
+
+	left, leftOk := constantValue(binary.left)
+	if !allFolded || !leftOk {
+		copied := *binary
+		copied.arguments = arguments
+		return &copied, nil
+	}
+
+	result := left
+	for _, argument := range arguments {
+		var err error
+		collection := argument.arg.(*ConstantNode).value
+		if argument.op.op == "not in" {
+			result, err = function.NotIn(result, collection)
+		} else {
+			result, err = function.In(result, collection)
+		}
+		// note 计算失败时保留原来的表达式，错误在执行阶段返回
+		if err != nil {
+			return exp, nil
+		}
+	}
+	return &BoolNode{value: result.(bool)}, nil
+}
+
+// decimalLiterals decimal 模式下数字字面量转换为 function.Decimal，0.1 -> <ConstantNode 0.1>
+func decimalLiterals(arithmetic *function.Arithmetic) func(exp Expression) (Expression, error) {
+	return func(exp Expression) (Expression, error) {
+		number, ok := exp.(*NumberNode)
+		if !ok || arithmetic.Decimal() == nil {
+			return exp, nil
+		}
+
+		value, err := arithmetic.Literal(number.GetLiteral(), number.GetValue())
+		if err != nil {
+			return nil, err
+		}
+		return &ConstantNode{value: value}, nil
+	}
+}
+
+// removeSubNodes 去掉括号对应的 SubNode，(a + b) * c -> a + b 作为 * 的左边
+//
+//	note ast 的结构已经可以表示运算的优先级，括号只在解析的时候有用，
+//		 parser 解析时已经去掉了括号，这里处理的是其他方式构造的 ast
+func removeSubNodes(exp Expression) (Expression, error) {
+	if sub, ok := exp.(*SubNode); ok {
+		return sub.subNode, nil
+	}
+	return exp, nil
+}
+
+// foldConstants 自底向上折叠常量子表达式，子表达式折叠之后当前表达式可能也是常量，比如 -(1 + 2) -> -3
+//
+//  1. 一元运算的参数是常量，-1、!true
+//  2. 左结合的二元运算从左边开始连续的常量，1 + 2 + a -> 3 + a，逻辑运算左边的值可以确定结果时，false && a -> false
+//  3. 右结合的二元运算从右边开始连续的常量，a ** 2 ** 3 -> a ** 8
+//  4. 三元表达式的条件是常量，true ? a : b -> a
+//  5. 参数都是常量并且允许折叠的函数，a + add(1, 2) -> a + 3
+//     note 使用和执行时相同的运算规则，计算失败时(比如除零)保留原来的表达式，错误在执行阶段返回，
+//     结果不是不可变的值时也保留原来的表达式，见 isImmutable
+func foldConstants(arithmetic *function.Arithmetic, udf map[string]function.Function,
+	config OptimizeConfig) func(exp Expression) (Expression, error) {
+	return func(exp Expression) (Expression, error) {
+		switch e := exp.(type) {
+		case *FuncExpression:
+			if config.WithoutFuncFold {
+				return exp, nil
+			}
+			return foldFunc(udf, e), nil
+		case *UnaryExpression:
+			if config.WithoutConstantFold {
+				return exp, nil
+			}
+			return foldUnary(arithmetic, e), nil
+		case *BinaryExpression:
+			if config.WithoutConstantFold {
+				return exp, nil
+			}
+			if e.Associativity() == RightAssociativity {
+				return foldRightAssociative(arithmetic, e), nil
+			}
+			return foldLeftAssociative(arithmetic, e), nil
+		case *ConditionalExpression:
+			if config.WithoutConstantFold {
+				return exp, nil
+			}
+			return foldConditional(e), nil
+		default:
+			return exp, nil
+		}
+	}
+}
+
+func foldUnary(arithmetic *function.Arithmetic, unary *UnaryExpression) Expression {
+	value, ok := constantValue(unary.exp)
+	if !ok {
+		return unary
+	}
+
+	var result interface{}
+	var err error
+	switch unary.op.op {
+	case "-":
+		result, err = arithmetic.Negative(value)
+	case "+":
+		result, err = function.Positive(value)
+	case "!", "not":
+		result, err = function.Not(value)
+	default:
+		return unary
+	}
+
+	if err != nil || !isImmutable(result) {
+		return unary
+	}
+	return constantNode(result)
+}
+
+func foldLeftAssociative(arithmetic *function.Arithmetic, binary *BinaryExpression) Expression {
+	result, ok := constantValue(binary.left)
+	if !ok {
+		return binary
+	}
+
+	for i, argument := range binary.arguments {
+		op := argument.op.op
+		if function.IsLogicalOperator(op) {
+			b, err := function.Bool(result)
+			if err != nil {
+				return foldedPrefix(binary, result, i)
+			}
+			if function.IsShortCircuit(op, b) {
+				return constantNode(b)
+			}
+		}
+
+		value, ok := constantValue(argument.arg)
+		if !ok {
+			return foldedPrefix(binary, result, i)
+		}
+
+		f, ok := arithmetic.BinaryOperator(op)
+		if !ok {
+			return foldedPrefix(binary, result, i)
+		}
+		next, err := f(result, value)
+		if err != nil || !isImmutable(next) {
+			return foldedPrefix(binary, result, i)
+		}
+		result = next
+	}
+	return constantNode(result)
+}
+
+// foldedPrefix 前 index 个运算的结果 result 替换为左边的参数，index 为 0 时没有可以折叠的运算
+func foldedPrefix(binary *BinaryExpression, result interface{}, index int) Expression {
+	if index == 0 {
+		return binary
+	}
+
+	copied := *binary
+	copied.left = constantNode(result)
+	copied.arguments = binary.GetArguments()[index:]
+	return &copied
+}
+
+func foldRightAssociative(arithmetic *function.Arithmetic, binary *BinaryExpression) Expression {
+	last := len(binary.arguments) - 1
+	result, ok := constantValue(binary.arguments[last].arg)
+	if !ok {
+		return binary
+	}
+
+	// note 第 i 个运算的左边是第 i-1 个参数，第 0 个运算的左边是 left
+	i := last
+	for ; i >= 0; i-- {
+		left := binary.left
+		if i > 0 {
+			left = binary.arguments[i-1].arg
+		}
+
+		value, ok := constantValue(left)
+		if !ok {
+			break
+		}
+		f, ok := arithmetic.BinaryOperator(binary.arguments[i].op.op)
+		if !ok {
+			break
+		}
+		next, err := f(value, result)
+		if err != nil || !isImmutable(next) {
+			break
+		}
+		result = next
+	}
+
+	if i < 0 {
+		return constantNode(result)
+	}
+	if i == last {
+		return binary
+	}
+
+	copied := *binary
+	copied.arguments = binary.GetArguments()[:i+1]
+	copied.arguments[i].arg = constantNode(result)
+	return &copied
+}
+
+func foldConditional(conditional *ConditionalExpression) Expression {
+	value, ok := constantValue(conditional.condition)
+	if !ok {
+		return conditional
+	}
+
+	condition, err := function.Bool(value)
+	if err != nil {
+		return conditional
+	}
+	if condition {
+		return conditional.then
+	}
+	return conditional.otherwise
+}
+
+// foldFunc 函数不存在或者参数个数不对时保留原来的表达式，错误在执行阶段返回
+func foldFunc(udf map[string]function.Function, funcExp *FuncExpression) Expression {
+	f, ok := udf[funcExp.GetFuncName()]
+	if !ok || !f.AllowFold() {
+		return funcExp
+	}
+	if f.ArgumentsNum() != -1 && f.ArgumentsNum() != len(funcExp.arguments) {
+		return funcExp
+	}
+
+	args := make([]interface{}, 0, len(funcExp.arguments))
+	for _, argument := range funcExp.arguments {
+		value, ok := constantValue(argument)
+		if !ok {
+			return funcExp
+		}
+		args = append(args, value)
+	}
+
+	result, err := f.Call(args)
+	if err != nil || !isImmutable(result) {
+		return funcExp
+	}
+	return constantNode(result)
+}
+
+// isImmutable 折叠之后的常量被缓存的表达式的每次计算共享，所以只折叠不可变的值，
+// 比如 split('a,b', ',') 的结果可能被调用方修改，修改之后会影响下一次计算的结果
+//
+//	note *big.Int 和 *big.Rat 按照数字处理，运算时总是创建新的值，不会修改参数
+func isImmutable(value interface{}) bool {
+	switch value.(type) {
+	case nil, bool, string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64,
+		*big.Int, *big.Rat, time.Time, time.Duration, function.Decimal, *function.Range:
+		return true
+	default:
+		return false
+	}
+}
+
+// constantNode bool 和 nil 使用对应的常量节点，其他值使用 ConstantNode
+func constantNode(value interface{}) Expression {
+	switch v := value.(type) {
+	case nil:
+		return &NullNode{}
+	case bool:
+		return &BoolNode{value: v}
+	default:
+		return &ConstantNode{value: value}
+	}
+}
+
+func isMembershipOperator(op string) bool {
+	return op == "in" || op == "not in" || op == "between"
+}
+
+// constantValue 常量表达式的值，包括字面量、元素都是常量的数组以及边界都是常量的区间
+func constantValue(exp Expression) (interface{}, bool) {
+	switch e := exp.(type) {
+	case *NumberNode:
+		return e.GetValue(), true
+	case *StringNode:
+		return e.GetStringValue(), true
+	case *BoolNode:
+		return e.GetValue(), true
+	case *NullNode:
+		return nil, true
+	case *DurationNode:
+		return e.GetValue(), true
+	case *ConstantNode:
+		return e.GetValue(), true
+	case *SubNode:
+		return constantValue(e.subNode)
+	case *ArrayExpression:
+		elements := make([]interface{}, 0, len(e.elements))
+		for _, element := range e.elements {
+			value, ok := constantValue(element)
+			if !ok {
+				return nil, false
+			}
+			elements = append(elements, value)
+		}
+		return elements, true
+	case *BinaryExpression:
+		if e.priority != rangeLevelOp || len(e.arguments) != 1 {
+			return nil, false
+		}
+		low, lowOk := constantValue(e.left)
+		high, highOk := constantValue(e.arguments[0].arg)
+		if !lowOk || !highOk {
+			return nil, false
+		}
+		r, err := function.NewRange(low, high)
+		if err != nil {
+			return nil, false
+		}
+		return r, true
+	default:
+		return nil, false
+	}
+}
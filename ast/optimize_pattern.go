@@ -0,0 +1,71 @@
+package ast
+
+import (
+	"fmt"
+	"goscript/function"
+)
+
+// compilePatterns 正则的 pattern 是字符串常量时预先编译，编译结果使用 ConstantNode 保存在 ast 中
+//
+//	a =~ '^\d+$'、regexMatch(a, '^\d+$')，这样缓存的表达式在每次计算时不需要重新编译
+//	note pattern 不合法时返回错误，错误信息中包含 pattern 在表达式中的位置
+func compilePatterns(udf map[string]function.Function) func(exp Expression) (Expression, error) {
+	return func(exp Expression) (Expression, error) {
+		switch e := exp.(type) {
+		case *BinaryExpression:
+			if e.priority != equalityLevelOp {
+				return exp, nil
+			}
+
+			copied := *e
+			copied.arguments = make([]binaryExpArgument, len(e.arguments))
+			for i, argument := range e.arguments {
+				copied.arguments[i] = argument
+				if argument.op.op != "=~" && argument.op.op != "matches" {
+					continue
+				}
+
+				compiled, err := compilePattern(argument.arg)
+				if err != nil {
+					return nil, err
+				}
+				copied.arguments[i].arg = compiled
+			}
+			return &copied, nil
+
+		case *FuncExpression:
+			index, ok := function.PatternArgIndex(udf[e.GetFuncName()])
+			if !ok || index >= len(e.arguments) {
+				return exp, nil
+			}
+
+			compiled, err := compilePattern(e.arguments[index])
+			if err != nil {
+				return nil, err
+			}
+
+			copied := *e
+			copied.arguments = e.GetArguments()
+			copied.arguments[index] = compiled
+			return &copied, nil
+
+		default:
+			return exp, nil
+		}
+	}
+}
+
+// compilePattern 不是字符串常量时原样返回，在计算的时候编译
+func compilePattern(exp Expression) (Expression, error) {
+	str, ok := exp.(*StringNode)
+	if !ok {
+		return exp, nil
+	}
+
+	re, err := function.CompilePattern(str.GetStringValue())
+	if err != nil {
+		line, column := str.GetPosition()
+		return nil, fmt.Errorf("invalid regex pattern %s: %v. line:%d, column:%d", str.GetLiteral(), err, line, column)
+	}
+	return &ConstantNode{value: re}, nil
+}
@@ -0,0 +1,189 @@
+package ast
+
+import "goscript/function"
+
+// simplifyIdentities 恒等运算替换为 IdentityExpression，x * 1 -> x、0 + x -> x、a * b * 1 -> a * b
+//
+//	note 表达式中的变量没有类型，比如 x 是字符串时 x + 0 是字符串拼接，
+//		 所以 IdentityExpression 在执行阶段判断 x 的值，见 function.Arithmetic.Identity
+func simplifyIdentities(arithmetic *function.Arithmetic) func(exp Expression) (Expression, error) {
+	return func(exp Expression) (Expression, error) {
+		binary, ok := exp.(*BinaryExpression)
+		if !ok {
+			return exp, nil
+		}
+		if binary.Associativity() == RightAssociativity {
+			return simplifyRightAssociative(arithmetic, binary), nil
+		}
+		return simplifyLeftAssociative(arithmetic, binary), nil
+	}
+}
+
+// simplifyLeftAssociative 从左到右计算，x op c 中 x 是前边所有运算的结果，c op x 只有 c 是最左边的参数时才可以替换
+func simplifyLeftAssociative(arithmetic *function.Arithmetic, binary *BinaryExpression) Expression {
+	left := binary.left
+	var arguments []binaryExpArgument
+	changed := false
+	for _, argument := range binary.arguments {
+		if identity, ok := identityOf(arithmetic, argument.op, argument.arg, false); ok {
+			identity.exp = binaryOf(binary, left, arguments)
+			left, arguments, changed = identity, nil, true
+			continue
+		}
+		if len(arguments) == 0 {
+			if identity, ok := identityOf(arithmetic, argument.op, left, true); ok {
+				identity.exp = argument.arg
+				left, changed = identity, true
+				continue
+			}
+		}
+		arguments = append(arguments, argument)
+	}
+
+	if !changed {
+		return binary
+	}
+	return binaryOf(binary, left, arguments)
+}
+
+// simplifyRightAssociative 从右到左计算，只有最右边的 x ** 1 可以替换，a ** b ** 1 -> a ** b
+func simplifyRightAssociative(arithmetic *function.Arithmetic, binary *BinaryExpression) Expression {
+	last := len(binary.arguments) - 1
+	identity, ok := identityOf(arithmetic, binary.arguments[last].op, binary.arguments[last].arg, false)
+	if !ok {
+		return binary
+	}
+
+	if last == 0 {
+		identity.exp = binary.left
+		return identity
+	}
+	arguments := binary.GetArguments()[:last]
+	identity.exp = arguments[last-1].arg
+	arguments[last-1].arg = identity
+	return binaryOf(binary, binary.left, arguments)
+}
+
+// identityOf operand 是常量并且 op 是恒等运算时返回没有设置 exp 的 IdentityExpression
+func identityOf(arithmetic *function.Arithmetic, op OperatorNode, operand Expression,
+	operandFirst bool) (*IdentityExpression, bool) {
+	value, ok := constantValue(operand)
+	if !ok {
+		return nil, false
+	}
+
+	identity, ok := arithmetic.Identity(op.op, value, operandFirst)
+	if !ok {
+		return nil, false
+	}
+	return &IdentityExpression{op: op, operand: operand, operandFirst: operandFirst, identity: identity}, true
+}
+
+// binaryOf 和 binary 运算符优先级相同的二元表达式，arguments 为空时就是 left
+func binaryOf(binary *BinaryExpression, left Expression, arguments []binaryExpArgument) Expression {
+	if len(arguments) == 0 {
+		return left
+	}
+
+	copied := *binary
+	copied.left = left
+	copied.arguments = arguments
+	return &copied
+}
+
+// commonClass 结构相同的子表达式，id 为 -1 表示只出现了一次
+type commonClass struct {
+	exp   Expression
+	count int
+	id    int
+}
+
+// eliminateCommonSubexpressions 结构相同的子表达式在一次计算中只计算一次，(a + b) * (a + b)、score(a) > 1 && score(a) < 10
+//
+//	只有纯函数(function.Function.AllowFold 为 true)的调用可以作为公共子表达式，方法调用也不可以，
+//	lambda 的 body 中的子表达式依赖 lambda 的参数，所以不处理，但是整个 lambda 可以是公共子表达式的一部分
+//	note 公共子表达式在第一次被计算时保存结果，所以三元表达式和逻辑运算中没有被选中的分支不会被提前计算
+func eliminateCommonSubexpressions(exp Expression, udf map[string]function.Function) (Expression, error) {
+	if _, ok := exp.(*CommonScope); ok {
+		return exp, nil
+	}
+
+	var classes []*commonClass
+	classOf := func(exp Expression) *commonClass {
+		for _, class := range classes {
+			if Equal(class.exp, exp) {
+				return class
+			}
+		}
+		return nil
+	}
+
+	// note 再次出现的子表达式的子节点已经在第一次出现时统计过，不需要重复统计，
+	//		比如 f(a) + 1 出现两次时 f(a) 只统计一次，这样不会生成多余的 CommonExpression
+	WalkDeepFirst(exp, func(deep int, exp Expression) WalkControl {
+		if _, ok := exp.(*LambdaExpression); ok {
+			return Abort
+		}
+		if !isCommonCandidate(exp, udf) {
+			return Continue
+		}
+		if class := classOf(exp); class != nil {
+			class.count++
+			return Abort
+		}
+		classes = append(classes, &commonClass{exp: exp, count: 1, id: -1})
+		return Continue
+	})
+
+	count := 0
+	for _, class := range classes {
+		if class.count > 1 {
+			class.id = count
+			count++
+		}
+	}
+	if count == 0 {
+		return exp, nil
+	}
+
+	exp, err := transformOutsideLambdas(exp, func(exp Expression) (Expression, error) {
+		if !isCommonCandidate(exp, udf) {
+			return exp, nil
+		}
+		if class := classOf(exp); class != nil && class.id >= 0 {
+			return &CommonExpression{id: class.id, exp: exp}, nil
+		}
+		return exp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CommonScope{exp: exp, count: count}, nil
+}
+
+// isCommonCandidate 是否可以作为公共子表达式，常量、变量以及数组等直接计算的表达式不需要
+func isCommonCandidate(exp Expression, udf map[string]function.Function) bool {
+	switch exp.(type) {
+	case *BinaryExpression, *UnaryExpression, *ConditionalExpression, *FuncExpression,
+		*IndexExpression, *SliceExpression, *MemberExpression, *IdentityExpression:
+	default:
+		return false
+	}
+
+	pure := true
+	WalkDeepFirst(exp, func(deep int, exp Expression) WalkControl {
+		switch e := exp.(type) {
+		case *MethodCallExpression:
+			pure = false
+		case *FuncExpression:
+			if f, ok := udf[e.GetFuncName()]; !ok || !f.AllowFold() {
+				pure = false
+			}
+		}
+		if !pure {
+			return Abort
+		}
+		return Continue
+	})
+	return pure
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func Parse(exp string) (Expression, error) {
@@ -29,11 +31,12 @@ func newParser(tokens []Token) *parser {
 	}
 }
 
+// filterWhiteToken note 换行和空格一样只用于分隔 token，表达式可以写成多行
 func filterWhiteToken(tokens []Token) []Token {
 	tokensWithoutWhiteToken := make([]Token, 0)
 
 	for _, token := range tokens {
-		if token.kind != WhiteSpace {
+		if token.kind != WhiteSpace && token.kind != NewLine {
 			tokensWithoutWhiteToken = append(tokensWithoutWhiteToken, token)
 		}
 	}
@@ -66,18 +69,157 @@ func (p *parser) parseInternal() (Expression, error) {
 //
 // expression
 //
-//	: binary
+//	: conditional
 //	;
 //
 // todo 常量折叠： 1+2 -> 3； -3 -> (-3)；折叠的时候也需要计算，比如数字想加或者字符串拼接，所以不适合在 parser 中进行
 func (p *parser) parseExpression() (Expression, error) {
-	return p.parseBinaryExpression(firstLevelOp)
+	if p.isLambdaStart() {
+		return p.parseLambda()
+	}
+	return p.parseConditionalExpression()
+}
+
+// isLambdaStart 前看 x -> 或者 (x, y) ->，note 需要和 (a) 这种子表达式区分开
+func (p *parser) isLambdaStart() bool {
+	first := p.scanner.peek()
+	if first == nil {
+		return false
+	}
+
+	if first.kind == Variable {
+		arrow := p.scanner.peekN(1)
+		return arrow != nil && arrow.kind == Operator && arrow.value == "->"
+	}
+
+	if first.kind != Control || first.value != "(" {
+		return false
+	}
+
+	i := 1
+	if param := p.scanner.peekN(i); param != nil && param.kind == Variable {
+		i++
+		for comma, param := p.scanner.peekN(i), p.scanner.peekN(i+1); comma != nil && comma.kind == Comma &&
+			param != nil && param.kind == Variable; comma, param = p.scanner.peekN(i), p.scanner.peekN(i+1) {
+			i += 2
+		}
+	}
+
+	rParen, arrow := p.scanner.peekN(i), p.scanner.peekN(i+1)
+	return rParen != nil && rParen.kind == Control && rParen.value == ")" &&
+		arrow != nil && arrow.kind == Operator && arrow.value == "->"
+}
+
+// parseLambda
+//
+// ```
+// lambda
+//
+//	: (Variable | LParen (Variable (Comma Variable)*)? RParen) '->' expression
+//	;
+//
+// ```
+func (p *parser) parseLambda() (*LambdaExpression, error) {
+	params := make([]string, 0)
+	if first := p.scanner.pop(); first.kind == Variable {
+		params = append(params, first.value)
+	} else {
+		for token := p.scanner.pop(); token.kind != Control; token = p.scanner.pop() {
+			if token.kind == Comma {
+				continue
+			}
+			for _, param := range params {
+				if param == token.value {
+					errorMsg := fmt.Sprintf("duplicate lambda parameter '%s'. line:%d, column:%d",
+						token.value, token.line, token.column)
+					return nil, errors.New(errorMsg)
+				}
+			}
+			params = append(params, token.value)
+		}
+	}
+	p.scanner.pop() // swallow '->'
+
+	body, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	return &LambdaExpression{
+		params: params,
+		body:   body,
+	}, nil
+}
+
+// parseConditionalExpression
+//
+// ```
+// conditional
+//
+//	: binary ('?' conditional ':' conditional)?
+//	;
+//
+// ```
+//
+// note 通过递归解析 else 分支实现右结合
+func (p *parser) parseConditionalExpression() (Expression, error) {
+	condition, err := p.parseBinaryExpression(lowestLevelOp)
+	if err != nil {
+		return nil, err
+	}
+
+	next := p.scanner.peek()
+	if next == nil || next.kind != Operator || next.value != "?" {
+		return condition, nil
+	}
+	p.scanner.pop() // swallow '?'
+
+	then, err := p.parseConditionalExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	colonToken := p.scanner.pop()
+	if colonToken == nil {
+		return nil, p.unexpectedEOF("':' of conditional expression")
+	}
+	if colonToken.kind != Operator || colonToken.value != ":" {
+		errorMsg := fmt.Sprintf(
+			"expected ':' of conditional expression instead of '%s'. line:%d, column:%d",
+			colonToken.value, colonToken.line, colonToken.column,
+		)
+		return nil, errors.New(errorMsg)
+	}
+
+	otherwise, err := p.parseConditionalExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConditionalExpression{
+		condition: condition,
+		then:      then,
+		otherwise: otherwise,
+	}, nil
 }
 
 // parseLevel1BinaryExpression
 //
 // ```
 // binary
+//
+//	: and_binary (Or_op and_binary)*
+//	;
+//
+// and_binary
+//
+//	: equality_binary (And_op equality_binary)*
+//	;
+//
+// ...
+//
+// level1_binary
+//
 //	: level2_binary (First_level_op level2_binary)*
 //	;
 //
@@ -168,8 +310,11 @@ func (p *parser) parseSignedAtomBinaryExpression(priority OperatorPriority) (Exp
 // ```
 func (p *parser) parseSignedAtom() (Expression, error) {
 	lookAhead := p.scanner.peek()
+	if lookAhead == nil {
+		return nil, p.unexpectedEOF("expression")
+	}
 
-	if unaryOperator[lookAhead.value] {
+	if lookAhead.kind == Operator && unaryOperator[lookAhead.value] {
 		return p.parseUnaryExpression()
 	}
 
@@ -181,10 +326,12 @@ func (p *parser) parseSignedAtom() (Expression, error) {
 // ```
 // unary
 //
-//	:UnaryOp atom
+//	:UnaryOp signedAtom
 //	;
 //
 // ```
+//
+// note 一元运算符可以连续出现，比如 !!a、- -1
 func (p *parser) parseUnaryExpression() (Expression, error) {
 
 	operator, err := p.parseUnaryOpe()
@@ -195,7 +342,7 @@ func (p *parser) parseUnaryExpression() (Expression, error) {
 	expression := UnaryExpression{}
 	expression.op = *operator
 
-	atom, err := p.parseAtom()
+	atom, err := p.parseSignedAtom()
 	if err != nil {
 		return nil, err
 	}
@@ -211,6 +358,8 @@ func (p *parser) parseUnaryExpression() (Expression, error) {
 //
 //	: '+'
 //	| '-'
+//	| '!'
+//	| 'not'
 //	;
 //
 // ```
@@ -230,20 +379,188 @@ func (p *parser) parseUnaryOpe() (*OperatorNode, error) {
 	}, nil
 }
 
-// parseAtom note 走到这里的时候预期不为空、即不反悔emptyNode，因为上层已经校验过了
+// parseAtom
 //
 // ```
 // atom
+//
+//	: primary (index | slice | member)*
+//	;
+//
+// member
+//
+//	: '.' Variable
+//	| '.' function
+//	;
+//
+// index
+//
+//	: '[' expression ']'
+//	;
+//
+// slice
+//
+//	: '[' expression? ':' expression? ']'
+//	;
+//
+// ```
+func (p *parser) parseAtom() (Expression, error) {
+	atom, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if p.nextIs(Bracket, "[") {
+			atom, err = p.parseIndexOrSlice(atom)
+		} else if p.nextIs(Operator, ".") {
+			atom, err = p.parseMember(atom)
+		} else {
+			return atom, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseMember 解析 object 之后的 .name 或者 .name(args)
+func (p *parser) parseMember(object Expression) (Expression, error) {
+	p.scanner.pop() // swallow '.'
+
+	next := p.scanner.peek()
+	if next != nil && next.kind == Func {
+		funcExp, err := p.parseFuncExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		call := funcExp.(*FuncExpression)
+		return &MethodCallExpression{
+			object:    object,
+			name:      call.GetFuncName(),
+			arguments: call.arguments,
+		}, nil
+	}
+
+	nameToken := p.scanner.pop()
+	if nameToken == nil {
+		return nil, p.unexpectedEOF("member name")
+	}
+	if nameToken.kind != Variable {
+		errorMsg := fmt.Sprintf(
+			"expected member name instead of '%s'. line:%d, column:%d",
+			nameToken.value, nameToken.line, nameToken.column,
+		)
+		return nil, errors.New(errorMsg)
+	}
+
+	return &MemberExpression{object: object, name: nameToken.value}, nil
+}
+
+// parseIndexOrSlice 解析 object 之后的 [index] 或者 [low:high]
+func (p *parser) parseIndexOrSlice(object Expression) (Expression, error) {
+	if _, err := p.parseBracket("["); err != nil {
+		return nil, err
+	}
+
+	var low Expression
+	if !p.nextIs(Operator, ":") {
+		index, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.nextIs(Operator, ":") {
+			if _, err = p.parseBracket("]"); err != nil {
+				return nil, err
+			}
+			return &IndexExpression{object: object, index: index}, nil
+		}
+		low = index
+	}
+	p.scanner.pop() // swallow ':'
+
+	var high Expression
+	if !p.nextIs(Bracket, "]") {
+		var err error
+		high, err = p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.parseBracket("]"); err != nil {
+		return nil, err
+	}
+	return &SliceExpression{object: object, low: low, high: high}, nil
+}
+
+// parseArray
+//
+// ```
+// array
+//
+//	: '[' (expression (Comma expression)*)? ']'
+//	;
+//
+// ```
+func (p *parser) parseArray() (Expression, error) {
+	if _, err := p.parseBracket("["); err != nil {
+		return nil, err
+	}
+
+	array := ArrayExpression{elements: make([]Expression, 0)}
+	if p.nextIs(Bracket, "]") {
+		p.scanner.pop()
+		return &array, nil
+	}
+
+	element, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	array.elements = append(array.elements, element)
+
+	next := p.scanner.peek()
+	for next != nil && next.kind == Comma {
+		p.scanner.pop() // swallow comma
+		element, err = p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		array.elements = append(array.elements, element)
+		next = p.scanner.peek()
+	}
+
+	if _, err = p.parseBracket("]"); err != nil {
+		return nil, err
+	}
+	return &array, nil
+}
+
+// parsePrimary note 走到这里的时候预期不为空、即不反悔emptyNode，因为上层已经校验过了
+//
+// ```
+// primary
+//
 //	: Variable
 //	| String
 //	| Number
+//	| Bool
+//	| Null
 //	| func
+//	| array
 //	| sub_node
 //	;
 //
 // ```
-func (p *parser) parseAtom() (Expression, error) {
+func (p *parser) parsePrimary() (Expression, error) {
 	lookAHead := p.scanner.peek()
+	if lookAHead == nil {
+		return nil, p.unexpectedEOF("Atomic token")
+	}
 
 	if lookAHead.kind == Variable {
 		return p.parseVariable()
@@ -257,10 +574,27 @@ func (p *parser) parseAtom() (Expression, error) {
 		return p.parseNumber()
 	}
 
+	if lookAHead.kind == Duration {
+		return p.parseDuration()
+	}
+
+	if lookAHead.kind == Bool {
+		return p.parseBool()
+	}
+
+	if lookAHead.kind == Null {
+		p.scanner.pop()
+		return &NullNode{}, nil
+	}
+
 	if lookAHead.kind == Func {
 		return p.parseFuncExpression()
 	}
 
+	if lookAHead.kind == Bracket && lookAHead.value == "[" {
+		return p.parseArray()
+	}
+
 	// sub_node LParen expression RParen 的前看符号
 	if lookAHead.kind == Control && lookAHead.value == "("{
 		return p.parseSubNode()
@@ -300,7 +634,7 @@ func (p *parser) parseFuncExpression() (Expression, error) {
 
 	//  没有参数的函数
 	next := p.scanner.peek()
-	if next.kind == Control && next.value == ")" {
+	if next != nil && next.kind == Control && next.value == ")" {
 		rParentNode, err := p.parseRParen()
 		if err != nil {
 			return nil, err
@@ -316,7 +650,7 @@ func (p *parser) parseFuncExpression() (Expression, error) {
 		expression.arguments = append(expression.arguments, firstArg)
 
 		next := p.scanner.peek()
-		for next.kind == Comma {
+		for next != nil && next.kind == Comma {
 			p.scanner.pop() // swallow comma
 			node, err := p.parseExpression()
 			if err != nil {
@@ -344,32 +678,78 @@ func (p *parser) parseVariable() (*VariableNode, error) {
 	}, nil
 }
 
-// parseString
-//
-//	应该在计算的时候考虑？
+// parseString 在解析的时候处理转义字符，计算的时候直接使用处理后的值
 func (p *parser) parseString() (*StringNode, error) {
 	token := p.scanner.pop()
-	// assert token type is variable
+
+	value, err := unquote(token.value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid string %s: %v. line:%d, column:%d", token.value, err, token.line, token.column)
+	}
 
 	return &StringNode{
-		value: token.value,
+		literal: token.value,
+		value:   value,
+		line:    token.line,
+		column:  token.column,
 	}, nil
 }
 
+// parseNumber
+//
+//	包含 '.' 或者 'e/E' 的数字解析为 float64，其他解析为 int64
 func (p *parser) parseNumber() (*NumberNode, error) {
 	token := p.scanner.pop()
+
+	if strings.ContainsAny(token.value, ".eE") {
+		num, err := strconv.ParseFloat(token.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number token '%s'. line:%d, column:%d", token.value, token.line, token.column)
+		}
+		return &NumberNode{
+			literal:    token.value,
+			isFloat:    true,
+			floatValue: num,
+		}, nil
+	}
+
 	num, err := strconv.ParseInt(token.value, 10, 64)
 	if err != nil {
-		return nil, errors.New("invalid number token")
+		return nil, fmt.Errorf("invalid number token '%s'. line:%d, column:%d", token.value, token.line, token.column)
 	}
 	return &NumberNode{
-		Value: num,
+		literal:  token.value,
+		intValue: num,
+	}, nil
+}
+
+func (p *parser) parseDuration() (*DurationNode, error) {
+	token := p.scanner.pop()
+
+	value, err := time.ParseDuration(token.value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration token '%s'. line:%d, column:%d", token.value, token.line, token.column)
+	}
+	return &DurationNode{
+		literal: token.value,
+		value:   value,
+	}, nil
+}
+
+func (p *parser) parseBool() (*BoolNode, error) {
+	token := p.scanner.pop()
+
+	return &BoolNode{
+		value: token.value == "true",
 	}, nil
 }
 
 func (p *parser) parseFuncName() (*funcNameNode, error) {
 	funcNameToken := p.scanner.pop()
-	if funcNameToken == nil || funcNameToken.kind != Func {
+	if funcNameToken == nil {
+		return nil, p.unexpectedEOF("function name")
+	}
+	if funcNameToken.kind != Func {
 		errorMsg := fmt.Sprintf(
 			"expected function name instead of '%s'. line:%d, column:%d",
 			funcNameToken.value, funcNameToken.line, funcNameToken.column,
@@ -384,7 +764,10 @@ func (p *parser) parseFuncName() (*funcNameNode, error) {
 
 func (p *parser) parseLParen() (*ControlNode, error) {
 	controlToken := p.scanner.pop()
-	if controlToken == nil || controlToken.kind != Control || controlToken.value != "(" {
+	if controlToken == nil {
+		return nil, p.unexpectedEOF("left paren")
+	}
+	if controlToken.kind != Control || controlToken.value != "(" {
 		errorMsg := fmt.Sprintf(
 			"expected left paren instead of '%s'. line:%d, column:%d",
 			controlToken.value, controlToken.line, controlToken.column,
@@ -399,7 +782,10 @@ func (p *parser) parseLParen() (*ControlNode, error) {
 
 func (p *parser) parseRParen() (*ControlNode, error) {
 	controlToken := p.scanner.pop()
-	if controlToken == nil || controlToken.kind != Control || controlToken.value != ")" {
+	if controlToken == nil {
+		return nil, p.unexpectedEOF("right paren")
+	}
+	if controlToken.kind != Control || controlToken.value != ")" {
 		errorMsg := fmt.Sprintf(
 			"expected right paren instead of '%s'. line:%d, column:%d",
 			controlToken.value, controlToken.line, controlToken.column,
@@ -412,6 +798,29 @@ func (p *parser) parseRParen() (*ControlNode, error) {
 	}, nil
 }
 
+// parseBracket 解析 '[' 或者 ']'
+func (p *parser) parseBracket(bracket string) (string, error) {
+	bracketToken := p.scanner.pop()
+	if bracketToken == nil {
+		return "", p.unexpectedEOF("'" + bracket + "'")
+	}
+	if bracketToken.kind != Bracket || bracketToken.value != bracket {
+		errorMsg := fmt.Sprintf(
+			"expected '%s' instead of '%s'. line:%d, column:%d",
+			bracket, bracketToken.value, bracketToken.line, bracketToken.column,
+		)
+		return "", errors.New(errorMsg)
+	}
+
+	return bracketToken.value, nil
+}
+
+// nextIs 下一个 token 是否是指定类型和值，不移动 offset
+func (p *parser) nextIs(kind tokenKind, value string) bool {
+	next := p.scanner.peek()
+	return next != nil && next.kind == kind && next.value == value
+}
+
 // parseBinaryExpArgument
 // ```
 // binary
@@ -436,6 +845,15 @@ func (p *parser) parseBinaryExpArgument(priority OperatorPriority) (*binaryExpAr
 	argument := binaryExpArgument{}
 	argument.op = *op
 
+	if op.op == "between" {
+		arg, err := p.parseBetweenRange(priority)
+		if err != nil {
+			return nil, err
+		}
+		argument.arg = arg
+		return &argument, nil
+	}
+
 	var arg Expression
 	if priority.isHighestLevelOp() {
 		arg, err = p.parseSignedAtom()
@@ -451,6 +869,41 @@ func (p *parser) parseBinaryExpArgument(priority OperatorPriority) (*binaryExpAr
 	return &argument, nil
 }
 
+// parseBetweenRange a between low and high 中的 low and high，转换为范围 low..high
+//
+//	note low 和 high 按照比 between 高一级的优先级解析，所以其中的 and 不会被当作逻辑运算符
+func (p *parser) parseBetweenRange(priority OperatorPriority) (Expression, error) {
+	low, err := p.parseBinaryExpression(priority.getIncrement())
+	if err != nil {
+		return nil, err
+	}
+
+	andToken := p.scanner.pop()
+	if andToken == nil {
+		return nil, p.unexpectedEOF("'and' of between")
+	}
+	if andToken.kind != Operator || andToken.value != "and" {
+		errorMsg := fmt.Sprintf(
+			"expected 'and' of between instead of '%s'. line:%d, column:%d",
+			andToken.value, andToken.line, andToken.column,
+		)
+		return nil, errors.New(errorMsg)
+	}
+
+	high, err := p.parseBinaryExpression(priority.getIncrement())
+	if err != nil {
+		return nil, err
+	}
+
+	return &BinaryExpression{
+		left:     low,
+		priority: rangeLevelOp,
+		arguments: []binaryExpArgument{
+			{op: OperatorNode{op: "..", priority: rangeLevelOp}, arg: high},
+		},
+	}, nil
+}
+
 // parseSubNode
 //
 // ```
@@ -482,6 +935,9 @@ func (p *parser) parseSubNode() (Expression, error) {
 // parseOperator 获取指定优先级的运算符
 func (p *parser) parseOperator(priority OperatorPriority) (*OperatorNode, error) {
 	opeToken := p.scanner.pop()
+	if opeToken == nil {
+		return nil, p.unexpectedEOF("operator")
+	}
 	if _, ok := operatorByPriority[priority][opeToken.value]; !ok {
 		errorMsg := fmt.Sprintf(
 			"expected %d level op token instead of '%s'. line:%d, column:%d",
@@ -495,3 +951,8 @@ func (p *parser) parseOperator(priority OperatorPriority) (*OperatorNode, error)
 		priority: priority,
 	}, nil
 }
+
+// unexpectedEOF 表达式提前结束，比如 1+
+func (p *parser) unexpectedEOF(expected string) error {
+	return fmt.Errorf("expected %s instead of end of expression", expected)
+}
@@ -1,8 +1,14 @@
 package ast
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"goscript/function"
+	"math"
+	"math/big"
+	"regexp"
 	"testing"
+	"time"
 )
 
 
@@ -35,14 +41,106 @@ var validExpressions = []string{
 	"test(a)+1",
 	"a+test(a)",
 	"1+same(100)",
+	"price*0.85",
+	"1.5e3+2E-2-3e+1",
+	"-1.25*a",
+	"a>=1 && b<2 || !c",
+	"a == 1 and not b",
+	"a<=b==c!=d",
+	"!!a or -b > 1",
+	"true && !false || a == nil",
+	"null",
+	"a > 10 ? 'big' : 'small'",
+	"a ? b : c ? d : e",
+	"a ? b ? c : d : e",
+	"'a' + \"b\" + 'c\\'d'",
+	"[1, 2, a]",
+	"[]",
+	"arr[0] + m['key']",
+	"arr[1:3]",
+	"arr[:a][b:][:]",
+	"[[1], [2, 3]][1][0]",
+	"arr[a > 1 ? 1 : 0]",
+	"order.customer.level",
+	"order.items[0].price * 2",
+	"m['a'].b[1:2]",
+	"'a\\n\\t' + `raw \\n`",
+	"'\\u4e2d\\x41' + \"\\U0001F600\"",
+	"`multi\nline`",
+	"now() - created > 2h",
+	"1h30m + 1.5h - 500ms",
+	"-5m",
+	"filter(items, x -> x.price > 10)",
+	"reduce(items, (acc, x) -> acc + x, 0)",
+	"map(items, x -> map(x.tags, t -> upper(t)))",
+	"(x) -> (x)",
+	"() -> 1",
+	"a..b",
+	"country in ['US', 'CA'] and level not in [1, 2]",
+	"age between 18 and 60 and score >= 1..10",
+	"x in 1..n+1",
+	"'ab' in name or k not  in m",
+	"notInList in list",
+	"code =~ '^[A-Z]{2}\\\\d+$'",
+	"name matches `^a.*` and not (name matches 'b')",
+	"a & b",
+	"flags & 1 << 2 | mask ^ 255",
+	"2 ** 3 ** 2",
+	"-2 ** 2 * 3",
+	"a >> 1 << 2",
 }
 
 var invalidExpressions = []string{
 	`123ab+cd`,
 	`123ab+[cd`,
+	`123ab+[cd]`,
 	"123ab",
 	"1+",
 	"test(a,)",
+	"1.5e",
+	"1.",
+	"a = 1",
+	"a ==",
+	"!",
+	"a ? b",
+	"a ? b :",
+	"a : b",
+	"'abc",
+	"[1, 2",
+	"[1,]",
+	"arr[]",
+	"arr[1",
+	"arr[1:2:3]",
+	"]",
+	"a.",
+	"a.1",
+	"'\\q'",
+	"'\\u12'",
+	"'\\xZZ'",
+	"'\\UFFFFFFFF'",
+	"`abc",
+	"5x",
+	"1.5q + 1",
+	"2h30",
+	"x ->",
+	"(x y) -> x",
+	"(x,) -> x",
+	"(x, x) -> x",
+	"1 -> x",
+	"a + x -> x",
+	"a in",
+	"a not in",
+	"a between 1",
+	"a between 1 or 2",
+	"in",
+	"a not b",
+	"a =~",
+	"a matches",
+	"a = ~b",
+	"a ** ",
+	"a &",
+	"a <<< 1",
+	"a | | b",
 }
 
 
@@ -70,3 +168,621 @@ func TestParseBadCase(t *testing.T) {
 		assert.Nil(t, expression)
 	}
 }
+
+func TestParseNumber(t *testing.T) {
+	numberByExp := map[string]interface{}{
+		"123":     int64(123),
+		"0.85":    0.85,
+		"1.5e3":   1500.0,
+		"1.5E3":   1500.0,
+		"25e-2":   0.25,
+		"2.5e+1":  25.0,
+		"0012":    int64(12),
+		"3.00000": 3.0,
+	}
+
+	for exp, expected := range numberByExp {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		numberNode, ok := expression.(*NumberNode)
+		assert.True(t, ok, exp)
+		assert.Equal(t, expected, numberNode.GetValue(), exp)
+		assert.Equal(t, exp, numberNode.GetLiteral(), exp)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	// a || (b && (c == (d < (e + (f * g)))))
+	expression, err := Parse("a || b && c == d < e + f * g")
+	assert.Nil(t, err)
+
+	priorities := make([]OperatorPriority, 0)
+	WalkDeepFirst(expression, func(deep int, exp Expression) WalkControl {
+		if binary, ok := exp.(*BinaryExpression); ok {
+			priorities = append(priorities, binary.priority)
+		}
+		return Continue
+	})
+
+	assert.Equal(t, []OperatorPriority{
+		logicalOrLevelOp, logicalAndLevelOp, equalityLevelOp, relationalLevelOp, firstLevelOp, secondLevelOp,
+	}, priorities)
+}
+
+func TestParseConditionalRightAssociativity(t *testing.T) {
+	expression, err := Parse("a ? b : c ? d : e")
+	assert.Nil(t, err)
+
+	conditional, ok := expression.(*ConditionalExpression)
+	assert.True(t, ok)
+	assert.Equal(t, "a", conditional.Condition().(*VariableNode).GetName())
+	assert.Equal(t, "b", conditional.Then().(*VariableNode).GetName())
+
+	otherwise, ok := conditional.Else().(*ConditionalExpression)
+	assert.True(t, ok)
+	assert.Equal(t, "c", otherwise.Condition().(*VariableNode).GetName())
+	assert.Equal(t, "e", otherwise.Else().(*VariableNode).GetName())
+}
+
+func TestParseString(t *testing.T) {
+	valueByExp := map[string]string{
+		`'abc'`:                  "abc",
+		`"a\"b"`:                 `a"b`,
+		`'it\'s'`:                "it's",
+		`'a\nb\tc\\'`:            "a\nb\tc\\",
+		`'\x41\u4e2d\U0001F600'`: "A中😀",
+		"`raw \\n 'q'`":          `raw \n 'q'`,
+		"`line1\nline2`":         "line1\nline2",
+	}
+
+	for exp, expected := range valueByExp {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		stringNode, ok := expression.(*StringNode)
+		assert.True(t, ok, exp)
+		assert.Equal(t, expected, stringNode.GetStringValue(), exp)
+		assert.Equal(t, exp, stringNode.GetLiteral(), exp)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	durationByExp := map[string]time.Duration{
+		"5m":    5 * time.Minute,
+		"2h":    2 * time.Hour,
+		"1h30m": 90 * time.Minute,
+		"1.5h":  90 * time.Minute,
+		"500ms": 500 * time.Millisecond,
+		"10s":   10 * time.Second,
+	}
+
+	for exp, expected := range durationByExp {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		durationNode, ok := expression.(*DurationNode)
+		assert.True(t, ok, exp)
+		assert.Equal(t, expected, durationNode.GetValue(), exp)
+		assert.Equal(t, exp, durationNode.GetLiteral(), exp)
+	}
+}
+
+func TestParseLambda(t *testing.T) {
+	expression, err := Parse("reduce(items, (acc, x) -> acc + x * 2, 0)")
+	assert.Nil(t, err)
+
+	funcExp, ok := expression.(*FuncExpression)
+	assert.True(t, ok)
+	lambda, ok := funcExp.GetArguments()[1].(*LambdaExpression)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"acc", "x"}, lambda.GetParams())
+	_, ok = lambda.Body().(*BinaryExpression)
+	assert.True(t, ok)
+
+	// (x) 后边没有 -> 时是子表达式
+	expression, err = Parse("(x) + 1")
+	assert.Nil(t, err)
+	_, ok = expression.(*BinaryExpression)
+	assert.True(t, ok)
+}
+
+func TestParseMembership(t *testing.T) {
+	expression, err := Parse("age between 18 and 60 and ok")
+	assert.Nil(t, err)
+
+	// (age between (18..60)) and ok
+	and, ok := expression.(*BinaryExpression)
+	assert.True(t, ok)
+	assert.Equal(t, logicalAndLevelOp, and.priority)
+	between, ok := and.left.(*BinaryExpression)
+	assert.True(t, ok)
+	assert.Equal(t, "between", between.arguments[0].op.op)
+	rangeExp, ok := between.arguments[0].arg.(*BinaryExpression)
+	assert.True(t, ok)
+	assert.Equal(t, rangeLevelOp, rangeExp.priority)
+
+	tokens, err := getAllTokens("a not in b and not inStock")
+	assert.Nil(t, err)
+	values := make([]string, 0)
+	for _, token := range tokens {
+		if token.kind != WhiteSpace {
+			values = append(values, token.value)
+		}
+	}
+	assert.Equal(t, []string{"a", "not in", "b", "and", "not", "inStock"}, values)
+}
+
+func TestOptimizeMembership(t *testing.T) {
+	foldedByExp := map[string]interface{}{
+		"'US' in ['US', 'CA']": true,
+		"'MX' in ['US', 'CA']": false,
+		"3 not in [1, 2]":      true,
+		"5 between 1 and 10":   true,
+		"0 in 1..10":           false,
+		"'ell' in 'hello'":     true,
+	}
+	for exp, expected := range foldedByExp {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		optimized, err := Optimize(expression, nil)
+		assert.Nil(t, err, exp)
+		boolNode, ok := optimized.(*BoolNode)
+		if assert.True(t, ok, exp) {
+			assert.Equal(t, expected, boolNode.GetValue(), exp)
+		}
+	}
+
+	expression, err := Parse("country in ['US', 'CA'] && age in 18..60")
+	assert.Nil(t, err)
+	optimized, err := Optimize(expression, nil)
+	assert.Nil(t, err)
+
+	constants := 0
+	WalkDeepFirst(optimized, func(deep int, exp Expression) WalkControl {
+		if _, ok := exp.(*ConstantNode); ok {
+			constants++
+		}
+		return Continue
+	})
+	assert.Equal(t, 2, constants)
+
+	// 原来的表达式不会被修改
+	WalkDeepFirst(expression, func(deep int, exp Expression) WalkControl {
+		_, ok := exp.(*ConstantNode)
+		assert.False(t, ok)
+		return Continue
+	})
+
+	// 右边不是常量时不折叠
+	expression, err = Parse("a in [b, 1]")
+	assert.Nil(t, err)
+	optimized, err = Optimize(expression, nil)
+	assert.Nil(t, err)
+	binary := optimized.(*BinaryExpression)
+	_, ok := binary.arguments[0].arg.(*ArrayExpression)
+	assert.True(t, ok)
+}
+
+func TestOptimizePattern(t *testing.T) {
+	udf := map[string]function.Function{}
+	for _, f := range function.Builtins() {
+		udf[f.Name()] = f
+	}
+
+	expression, err := Parse("code =~ '^[A-Z]+$' && regexMatch(name, `\\d`) && regexReplace(name, p, '')")
+	assert.Nil(t, err)
+	optimized, err := Optimize(expression, udf)
+	assert.Nil(t, err)
+
+	patterns := make([]string, 0)
+	WalkDeepFirst(optimized, func(deep int, exp Expression) WalkControl {
+		if constant, ok := exp.(*ConstantNode); ok {
+			if re, ok := constant.GetValue().(*regexp.Regexp); ok {
+				patterns = append(patterns, re.String())
+			}
+		}
+		return Continue
+	})
+	assert.Equal(t, []string{"^[A-Z]+$", "\\d"}, patterns)
+
+	errByExp := map[string]string{
+		"code =~ '('":           "invalid regex pattern '(': error parsing regexp: missing closing ): `(`. line:1, column:9",
+		"a &&\n b matches '[a'": "invalid regex pattern '[a': error parsing regexp: missing closing ]: `[a`. line:2, column:12",
+		"regexFind(a, '*')":     "invalid regex pattern '*': error parsing regexp: missing argument to repetition operator: `*`. line:1, column:14",
+	}
+	for exp, expected := range errByExp {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		_, err = Optimize(expression, udf)
+		if assert.NotNil(t, err, exp) {
+			assert.Equal(t, expected, err.Error(), exp)
+		}
+	}
+
+	// 用户注册的同名函数不是正则函数，参数不会被编译
+	expression, err = Parse("regexFind(a, '*')")
+	assert.Nil(t, err)
+	_, err = Optimize(expression, map[string]function.Function{
+		"regexFind": function.NewFunction("regexFind", nil, 2, false, nil),
+	})
+	assert.Nil(t, err)
+}
+
+func TestParseBitwisePriority(t *testing.T) {
+	// a == (b | (c ^ (d & (e << (f + (g * (h ** i)))))))
+	expression, err := Parse("a == b | c ^ d & e << f + g * h ** i")
+	assert.Nil(t, err)
+
+	priorities := make([]OperatorPriority, 0)
+	WalkDeepFirst(expression, func(deep int, exp Expression) WalkControl {
+		if binary, ok := exp.(*BinaryExpression); ok {
+			priorities = append(priorities, binary.priority)
+		}
+		return Continue
+	})
+
+	assert.Equal(t, []OperatorPriority{
+		equalityLevelOp, bitOrLevelOp, bitXorLevelOp, bitAndLevelOp, shiftLevelOp, firstLevelOp, secondLevelOp, powerLevelOp,
+	}, priorities)
+}
+
+func TestParsePowerRightAssociativity(t *testing.T) {
+	expression, err := Parse("2 ** 3 ** 2")
+	assert.Nil(t, err)
+
+	power, ok := expression.(*BinaryExpression)
+	assert.True(t, ok)
+	assert.Equal(t, RightAssociativity, power.Associativity())
+	assert.Len(t, power.GetArguments(), 2)
+
+	expression, err = Parse("2 - 3 - 2")
+	assert.Nil(t, err)
+	assert.Equal(t, LeftAssociativity, expression.(*BinaryExpression).Associativity())
+}
+
+func TestOptimizeArithmetic(t *testing.T) {
+	foldedByExp := map[string]interface{}{
+		"1 + 2 * 3":       int64(7),
+		"(1 + 2) * 3":     int64(9),
+		"2 ** 3 ** 2":     int64(512),
+		"1 << 4 | 1":      int64(17),
+		"7 / 2.0":         3.5,
+		"a + (1 + 2) * 3": nil,
+	}
+	for exp, expected := range foldedByExp {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		optimized, err := Optimize(expression, nil)
+		assert.Nil(t, err, exp)
+
+		if expected == nil {
+			binary := optimized.(*BinaryExpression)
+			constant := binary.arguments[0].arg.(*ConstantNode)
+			assert.Equal(t, int64(9), constant.GetValue(), exp)
+			continue
+		}
+		constant, ok := optimized.(*ConstantNode)
+		if assert.True(t, ok, exp) {
+			assert.Equal(t, expected, constant.GetValue(), exp)
+		}
+	}
+
+	// 除零和溢出不折叠，错误在执行阶段返回
+	for _, exp := range []string{"1 / 0", "1 % 0", "1.5 / 0", "9223372036854775807 + 1", "2 ** 64", "1 << 63"} {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		optimized, err := Optimize(expression, nil)
+		assert.Nil(t, err, exp)
+		_, ok := optimized.(*BinaryExpression)
+		assert.True(t, ok, exp)
+	}
+
+	// 折叠的结果和执行时的溢出处理方式相同
+	promoted, _ := new(big.Int).SetString("9223372036854775808", 10)
+	resultByMode := map[function.OverflowMode]interface{}{
+		function.OverflowWrap:     int64(math.MinInt64),
+		function.OverflowSaturate: int64(math.MaxInt64),
+		function.OverflowPromote:  promoted,
+	}
+	for mode, expected := range resultByMode {
+		expression, err := Parse("9223372036854775807 + 1")
+		assert.Nil(t, err)
+		optimized, err := OptimizeWithConfig(expression, nil, OptimizeConfig{Arithmetic: function.NewArithmetic(mode)})
+		assert.Nil(t, err)
+		constant, ok := optimized.(*ConstantNode)
+		if assert.True(t, ok, mode.String()) {
+			assert.Equal(t, expected, constant.GetValue(), mode.String())
+		}
+	}
+}
+
+func TestOptimizeDecimal(t *testing.T) {
+	expression, err := Parse("0.1 + 0.2 + a * 1.50")
+	assert.Nil(t, err)
+	optimized, err := OptimizeWithConfig(expression, nil, OptimizeConfig{
+		Arithmetic: function.NewDecimalArithmetic(2, function.RoundHalfUp),
+	})
+	assert.Nil(t, err)
+
+	decimals := make([]string, 0)
+	WalkDeepFirst(optimized, func(deep int, exp Expression) WalkControl {
+		if constant, ok := exp.(*ConstantNode); ok {
+			if d, ok := constant.GetValue().(function.Decimal); ok {
+				decimals = append(decimals, d.String())
+			}
+		}
+		return Continue
+	})
+	// 0.1 + 0.2 被折叠
+	assert.Equal(t, []string{"0.3", "1.50"}, decimals)
+}
+
+func TestOptimizeConstantFold(t *testing.T) {
+	udf := map[string]function.Function{}
+	for _, f := range function.Builtins() {
+		udf[f.Name()] = f
+	}
+
+	// 折叠之后是常量
+	foldedByExp := map[string]interface{}{
+		"-1":                       int64(-1),
+		"-(1 + 2)":                 int64(-3),
+		"--1.5":                    1.5,
+		"!true":                    false,
+		"not (1 > 2)":              true,
+		"'a' + 'b' + 1":            "ab1",
+		"1 < 2 && 'a' < 'b'":       true,
+		"false && a":               false,
+		"true || a > 1":            true,
+		"1 > 2 ? a : 'b'":          "b",
+		"upper('abc') + len('ab')": "ABC2",
+		"max(1, 2 ** 3) * -1":      int64(-8),
+		"(1 + 2) * 3 in 1..10":     true,
+	}
+	for exp, expected := range foldedByExp {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		optimized, err := Optimize(expression, udf)
+		assert.Nil(t, err, exp)
+
+		switch e := optimized.(type) {
+		case *ConstantNode:
+			assert.Equal(t, expected, e.GetValue(), exp)
+		case *BoolNode:
+			assert.Equal(t, expected, e.GetValue(), exp)
+		case *StringNode:
+			assert.Equal(t, expected, e.GetStringValue(), exp)
+		default:
+			t.Errorf("exp: %s, expected constant instead of %T", exp, optimized)
+		}
+	}
+
+	// 部分折叠
+	expression, err := Parse("1 + 2 + a - 1")
+	assert.Nil(t, err)
+	optimized, err := Optimize(expression, udf)
+	assert.Nil(t, err)
+	binary := optimized.(*BinaryExpression)
+	assert.Equal(t, int64(3), binary.left.(*ConstantNode).GetValue())
+	assert.Len(t, binary.arguments, 2)
+
+	expression, err = Parse("a ** 2 ** 3")
+	assert.Nil(t, err)
+	optimized, err = Optimize(expression, udf)
+	assert.Nil(t, err)
+	binary = optimized.(*BinaryExpression)
+	assert.Len(t, binary.arguments, 1)
+	assert.Equal(t, int64(8), binary.arguments[0].arg.(*ConstantNode).GetValue())
+
+	expression, err = Parse("true ? ((a)) : b")
+	assert.Nil(t, err)
+	optimized, err = Optimize(expression, udf)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", optimized.(*VariableNode).GetName())
+
+	// 不能折叠的表达式保持不变
+	for _, exp := range []string{"now()", "unknown(1)", "len(1, 2)", "len(1)", "1 / 0", "true && 1", "a ? 1 : 2"} {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		optimized, err := Optimize(expression, udf)
+		assert.Nil(t, err, exp)
+		switch optimized.(type) {
+		case *ConstantNode, *BoolNode, *NullNode:
+			t.Errorf("exp: %s should not be folded", exp)
+		}
+	}
+}
+
+func TestOptimizeConfig(t *testing.T) {
+	udf := map[string]function.Function{}
+	for _, f := range function.Builtins() {
+		udf[f.Name()] = f
+	}
+
+	typeByConfig := map[string]struct {
+		exp      string
+		config   OptimizeConfig
+		expected Expression
+	}{
+		"constant":   {"-1", OptimizeConfig{WithoutConstantFold: true}, &UnaryExpression{}},
+		"func":       {"len('abc')", OptimizeConfig{WithoutFuncFold: true}, &FuncExpression{}},
+		"func only":  {"len('abc')", OptimizeConfig{WithoutConstantFold: true}, &ConstantNode{}},
+		"membership": {"'a' in ['a']", OptimizeConfig{WithoutConstantFold: true, WithoutMembershipFold: true}, &BinaryExpression{}},
+	}
+	for name, testCase := range typeByConfig {
+		expression, err := Parse(testCase.exp)
+		assert.Nil(t, err, name)
+		optimized, err := OptimizeWithConfig(expression, udf, testCase.config)
+		assert.Nil(t, err, name)
+		assert.IsType(t, testCase.expected, optimized, name)
+	}
+
+	// 保留括号，note parser 不会生成 SubNode，所以这里手动构造
+	var expression Expression = &UnaryExpression{
+		op:  OperatorNode{op: "-"},
+		exp: &SubNode{subNode: &SubNode{subNode: &VariableNode{name: "a"}}},
+	}
+	optimized, err := OptimizeWithConfig(expression, udf, OptimizeConfig{WithoutSubNodeRemoval: true})
+	assert.Nil(t, err)
+	assert.IsType(t, &SubNode{}, optimized.(*UnaryExpression).exp)
+	optimized, err = Optimize(expression, udf)
+	assert.Nil(t, err)
+	assert.IsType(t, &VariableNode{}, optimized.(*UnaryExpression).exp)
+
+	// 不编译 pattern 时不合法的 pattern 在执行阶段才返回错误
+	expression, err = Parse("a =~ '('")
+	assert.Nil(t, err)
+	_, err = OptimizeWithConfig(expression, udf, OptimizeConfig{WithoutPatternCompile: true})
+	assert.Nil(t, err)
+}
+
+func TestOptimizeIdentity(t *testing.T) {
+	udf := map[string]function.Function{}
+
+	// 恒等运算替换之后剩下的表达式
+	restByExp := map[string]string{
+		"a * 1":         "a",
+		"1 * a":         "a",
+		"a + 0":         "a",
+		"0 + a":         "a",
+		"a - 0":         "a",
+		"a / 1":         "a",
+		"a ** 1":        "a",
+		"a | 0":         "a",
+		"a << 0":        "a",
+		"a & -1":        "a",
+		"a * b * 1":     "a * b",
+		"a * (2 - 1)":   "a",
+		"(a + b) * 1":   "a + b",
+		"f(a) + 0":      "f(a)",
+		"a ** b ** 1":   "b",
+		"a ** 1 ** b":   "",
+		"0 - a":         "",
+		"a * 1.0":       "",
+		"a + 1":         "",
+		"1 / a":         "",
+		"a == 0":        "",
+		"a && true":     "",
+		"a * 1 in [1]":  "a",
+		"a ** (b ** 1)": "b",
+	}
+	for exp, rest := range restByExp {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		optimized, err := OptimizeWithConfig(expression, udf, OptimizeConfig{WithoutCommonSubexpression: true})
+		assert.Nil(t, err, exp)
+
+		var identity *IdentityExpression
+		WalkDeepFirst(optimized, func(deep int, exp Expression) WalkControl {
+			if e, ok := exp.(*IdentityExpression); ok && identity == nil {
+				identity = e
+			}
+			return Continue
+		})
+		if rest == "" {
+			assert.Nil(t, identity, exp)
+			continue
+		}
+		if assert.NotNil(t, identity, exp) {
+			expected, err := Parse(rest)
+			assert.Nil(t, err, rest)
+			assert.True(t, Equal(expected, identity.Exp()), exp)
+		}
+	}
+
+	identity := optimizedIdentity(t, "a + 0", OptimizeConfig{})
+	assert.True(t, identity.IsIdentity(int64(1)))
+	assert.False(t, identity.IsIdentity(-0.0))
+	assert.False(t, identity.IsIdentity("a"))
+	assert.False(t, identity.IsIdentity(1))
+	assert.False(t, identity.IsIdentity(nil))
+
+	identity = optimizedIdentity(t, "a * 1", OptimizeConfig{})
+	assert.True(t, identity.IsIdentity(1.5))
+
+	// decimal 模式下整数和 Decimal 的运算结果是 Decimal，不能替换
+	expression, err := Parse("a * 1")
+	assert.Nil(t, err)
+	optimized, err := OptimizeWithConfig(expression, udf, OptimizeConfig{Arithmetic: function.NewDecimalArithmetic(2, function.RoundHalfUp)})
+	assert.Nil(t, err)
+	assert.IsType(t, &BinaryExpression{}, optimized)
+
+	optimized, err = OptimizeWithConfig(expression, udf, OptimizeConfig{WithoutAlgebraicSimplify: true})
+	assert.Nil(t, err)
+	assert.IsType(t, &BinaryExpression{}, optimized)
+}
+
+func optimizedIdentity(t *testing.T, exp string, config OptimizeConfig) *IdentityExpression {
+	expression, err := Parse(exp)
+	assert.Nil(t, err, exp)
+	optimized, err := OptimizeWithConfig(expression, map[string]function.Function{}, config)
+	assert.Nil(t, err, exp)
+	return optimized.(*IdentityExpression)
+}
+
+func TestOptimizeCommonSubexpression(t *testing.T) {
+	udf := map[string]function.Function{}
+	for _, f := range function.Builtins() {
+		udf[f.Name()] = f
+	}
+	udf["score"] = function.NewFunction("score", nil, 1, true, nil)
+	udf["random"] = function.NewFunction("random", nil, 0, false, nil)
+
+	// 公共子表达式的个数
+	countByExp := map[string]int{
+		"(a + b) * (a + b)":                                1,
+		"score(a) > 1 && score(a) < 10":                    1,
+		"(a + b) * (a + b) + (a + b)":                      1,
+		"(f(a) + 1) * 2 + (f(a) + 1)":                      0,
+		"(abs(a) + 1) * 2 + (abs(a) + 1)":                  1,
+		"abs(a) + 1 > 0 ? abs(a) + 1 : abs(a)":             2,
+		"a.b.c + a.b.c":                                    1,
+		"len(items[1:]) + len(items[1:])":                  1,
+		"a + a":                                            0,
+		"a + b":                                            0,
+		"random() + random()":                              0,
+		"x.f() + x.f()":                                    0,
+		"map(items, a -> a * 2) + [a * 2]":                 0,
+		"map(items, x -> x * 2) == map(items, x -> x * 2)": 1,
+		"a * 2 + a * 2 + count(map(items, a -> a * 2))":    1,
+	}
+	for exp, count := range countByExp {
+		expression, err := Parse(exp)
+		assert.Nil(t, err, exp)
+		optimized, err := Optimize(expression, udf)
+		assert.Nil(t, err, exp)
+		if count == 0 {
+			assert.NotContains(t, fmt.Sprintf("%T", optimized), "CommonScope", exp)
+			continue
+		}
+
+		scope, ok := optimized.(*CommonScope)
+		if !assert.True(t, ok, exp) {
+			continue
+		}
+		assert.Equal(t, count, scope.Count(), exp)
+		assert.True(t, Equal(expression, scope), exp)
+
+		// lambda 的 body 中没有公共子表达式
+		WalkDeepFirst(scope, func(deep int, exp Expression) WalkControl {
+			if lambda, ok := exp.(*LambdaExpression); ok {
+				WalkDeepFirst(lambda.Body(), func(deep int, exp Expression) WalkControl {
+					assert.NotEqual(t, "*ast.CommonExpression", fmt.Sprintf("%T", exp))
+					return Continue
+				})
+			}
+			return Continue
+		})
+	}
+
+	// 已经处理过的表达式不会重复处理
+	expression, err := Parse("(a + b) * (a + b)")
+	assert.Nil(t, err)
+	optimized, err := Optimize(expression, udf)
+	assert.Nil(t, err)
+	again, err := Optimize(optimized, udf)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, again.(*CommonScope).Count())
+
+	optimized, err = OptimizeWithConfig(expression, udf, OptimizeConfig{WithoutCommonSubexpression: true})
+	assert.Nil(t, err)
+	assert.IsType(t, &BinaryExpression{}, optimized)
+}
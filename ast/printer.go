@@ -1,6 +1,9 @@
 package ast
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 
 func PrintAST(exp Expression) {
@@ -14,10 +17,22 @@ func PrintAST(exp Expression) {
 		switch e := exp.(type) {
 		case *NumberNode:
 			printDeep(deep)
-			println(e.Value)
+			println(e.GetLiteral())
+		case *ConstantNode:
+			printDeep(deep)
+			println(fmt.Sprintf("<ConstantNode>: %v", e.value))
+		case *DurationNode:
+			printDeep(deep)
+			println(e.GetLiteral())
 		case *StringNode:
 			printDeep(deep)
 			println(e.GetStringValue())
+		case *BoolNode:
+			printDeep(deep)
+			println(e.value)
+		case *NullNode:
+			printDeep(deep)
+			println("nil")
 		case *VariableNode:
 			printDeep(deep)
 			println(e.name)
@@ -41,6 +56,9 @@ func PrintAST(exp Expression) {
 			//	print(fmt.Sprintf("arg %d: \n", i))
 			//	printVisitor(deep+1, arg.GetArg())
 			//}
+		case *ConditionalExpression:
+			printDeep(deep)
+			println("<ConditionalExpression>")
 		case *UnaryExpression:
 			printDeep(deep)
 			println("<UnaryExpression>")
@@ -57,10 +75,37 @@ func PrintAST(exp Expression) {
 			//	print(fmt.Sprintf("arg %d: ", i))
 			//	printVisitor(deep+1, arg)
 			//}
+		case *LambdaExpression:
+			printDeep(deep)
+			println("<LambdaExpression>: " + strings.Join(e.params, ", "))
+		case *ArrayExpression:
+			printDeep(deep)
+			println("<ArrayExpression>")
+		case *IndexExpression:
+			printDeep(deep)
+			println("<IndexExpression>")
+		case *MemberExpression:
+			printDeep(deep)
+			println("<MemberExpression>: " + e.name)
+		case *MethodCallExpression:
+			printDeep(deep)
+			println("<MethodCallExpression>: " + e.name)
+		case *SliceExpression:
+			printDeep(deep)
+			println("<SliceExpression>")
 		case *SubNode:
 			printDeep(deep)
 			println(fmt.Sprintf("<SubNode>"))
 			//printVisitor(deep+1, e.subNode)
+		case *IdentityExpression:
+			printDeep(deep)
+			println("<IdentityExpression>")
+		case *CommonScope:
+			printDeep(deep)
+			println(fmt.Sprintf("<CommonScope>: %d", e.count))
+		case *CommonExpression:
+			printDeep(deep)
+			println(fmt.Sprintf("<CommonExpression>: %d", e.id))
 		}
 		return Continue
 	}
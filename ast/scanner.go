@@ -13,6 +13,15 @@ func (s *Scanner) peek() *Token {
 	return &s.source[s.offset]
 }
 
+// peekN 查看当前位置之后的第 n 个 token，peekN(0) 等价于 peek()
+func (s *Scanner) peekN(n int) *Token {
+	if s.offset+n >= len(s.source) {
+		return nil
+	}
+
+	return &s.source[s.offset+n]
+}
+
 func (s *Scanner) pop() *Token {
 	if s.offset == len(s.source) {
 		return nil
@@ -14,9 +14,11 @@ const (
 	Control // 优先级控制， (, )
 	NewLine
 	WhiteSpace
-	Comma // ,
-	// todo Bool
-	//todo 三元组，[] 数组
+	Comma   // ,
+	Bool    // true, false
+	Null    // nil, null
+	Bracket // 数组和下标， [, ]
+	Duration // 时间间隔，5m、1h30m
 )
 
 var tokenKindDesc = map[tokenKind]string{
@@ -30,6 +32,10 @@ var tokenKindDesc = map[tokenKind]string{
 	NewLine:    "NewLine",
 	WhiteSpace: "WhiteSpace",
 	Comma:      "Comma",
+	Bool:       "Bool",
+	Null:       "Null",
+	Bracket:    "Bracket",
+	Duration:   "Duration",
 }
 
 func (kind *tokenKind) String() string {
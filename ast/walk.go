@@ -26,6 +26,11 @@ func walk(exp Expression, deep int, f func(deep int, exp Expression) WalkControl
 			walk(arg.GetArg(), deep+1, f)
 		}
 
+	case *ConditionalExpression:
+		walk(e.condition, deep+1, f)
+		walk(e.then, deep+1, f)
+		walk(e.otherwise, deep+1, f)
+
 	case *UnaryExpression:
 		walk(&e.op, deep+1, f)
 		walk(e.exp, deep+1, f)
@@ -36,10 +41,51 @@ func walk(exp Expression, deep int, f func(deep int, exp Expression) WalkControl
 			walk(arg, deep+1, f)
 		}
 
+	case *LambdaExpression:
+		walk(e.body, deep+1, f)
+
+	case *ArrayExpression:
+		for _, element := range e.elements {
+			walk(element, deep+1, f)
+		}
+
+	case *IndexExpression:
+		walk(e.object, deep+1, f)
+		walk(e.index, deep+1, f)
+
+	case *MemberExpression:
+		walk(e.object, deep+1, f)
+
+	case *MethodCallExpression:
+		walk(e.object, deep+1, f)
+		for _, arg := range e.arguments {
+			walk(arg, deep+1, f)
+		}
+
+	case *SliceExpression:
+		walk(e.object, deep+1, f)
+		if e.low != nil {
+			walk(e.low, deep+1, f)
+		}
+		if e.high != nil {
+			walk(e.high, deep+1, f)
+		}
+
 	case *SubNode:
 		walk(e.subNode, deep+1, f)
 
-	case *EmptyExpression, *NumberNode, *StringNode, *VariableNode, *OperatorNode, *funcNameNode:
+	case *IdentityExpression:
+		walk(e.exp, deep+1, f)
+		walk(&e.op, deep+1, f)
+		walk(e.operand, deep+1, f)
+
+	case *CommonScope:
+		walk(e.exp, deep+1, f)
+
+	case *CommonExpression:
+		walk(e.exp, deep+1, f)
+
+	case *EmptyExpression, *NumberNode, *DurationNode, *ConstantNode, *StringNode, *BoolNode, *NullNode, *VariableNode, *OperatorNode, *funcNameNode:
 	default:
 		panic(fmt.Sprintf("ast.Walk: unexpected expression type %T", e))
 	}
@@ -3,14 +3,170 @@ package config
 import (
 	"goscript/ast"
 	"goscript/function"
+	"time"
 )
 
 
 type Option func(config *Config)
 
+// DefaultCacheSize 没有使用 WithCacheSize 时 vm 最多缓存的表达式个数
+const DefaultCacheSize = 1024
+
+// Backend 表达式的执行方式
+type Backend int
+
+const (
+	// TreeWalkingBackend 遍历优化之后的 ast 计算表达式，默认的执行方式
+	TreeWalkingBackend Backend = iota
+	// BytecodeBackend 将优化之后的 ast 编译为字节码，使用基于栈的虚拟机执行，编译结果和 ast 一起缓存
+	BytecodeBackend
+)
+
+func (backend Backend) String() string {
+	switch backend {
+	case TreeWalkingBackend:
+		return "tree-walking"
+	case BytecodeBackend:
+		return "bytecode"
+	default:
+		return "unknown"
+	}
+}
+
 type Config struct {
 	expCache   map[string]ast.Expression
 	funcByName map[string]function.Function
+
+	// withoutBuiltins 为 true 时 vm 不注册内置函数
+	withoutBuiltins bool
+	// clock 内置函数 now 使用的时钟，nil 表示 time.Now
+	clock func() time.Time
+	// overflowMode 整数运算溢出时的处理方式，默认返回错误
+	overflowMode function.OverflowMode
+	// decimal 不为 nil 时是 decimal 模式，见 WithDecimal
+	decimal *function.DecimalContext
+	// optimizeConfig 表达式优化的开关，见 WithOptimizeConfig
+	optimizeConfig ast.OptimizeConfig
+	// backend 表达式的执行方式，见 WithBackend
+	backend Backend
+	// cacheSize 表达式缓存的容量，hasCacheSize 为 false 时使用 DefaultCacheSize
+	cacheSize    int
+	hasCacheSize bool
+}
+
+// NewConfig 按照顺序应用 opts
+func NewConfig(opts ...Option) *Config {
+	c := &Config{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	return c
+}
+
+// WithoutBuiltins 不注册 len、upper 等内置函数，见 function.Builtins
+func WithoutBuiltins() Option {
+	return func(config *Config) {
+		config.withoutBuiltins = true
+	}
+}
+
+func (c *Config) WithoutBuiltins() bool {
+	return c != nil && c.withoutBuiltins
+}
+
+// WithClock 替换内置函数 now 使用的时钟，主要用于测试
+func WithClock(clock func() time.Time) Option {
+	return func(config *Config) {
+		config.clock = clock
+	}
+}
+
+func (c *Config) Clock() func() time.Time {
+	if c == nil {
+		return nil
+	}
+	return c.clock
+}
+
+// WithOverflowMode 整数运算溢出时的处理方式，见 function.OverflowMode
+//
+//	内置函数 abs、sum 和 pow 也使用相同的处理方式，比如 sum([a, 1]) 和 a + 1 的结果相同
+func WithOverflowMode(mode function.OverflowMode) Option {
+	return func(config *Config) {
+		config.overflowMode = mode
+	}
+}
+
+func (c *Config) OverflowMode() function.OverflowMode {
+	if c == nil {
+		return function.OverflowError
+	}
+	return c.overflowMode
+}
+
+// WithDecimal decimal 模式，数字字面量是准确的十进制小数，适合金额的计算，比如 0.1 + 0.2 == 0.3
+//
+//	除法的结果保留 scale 位小数，按照 rounding 舍入，见 function.NewDecimalArithmetic
+//	note decimal 模式下整数运算不会溢出，WithOverflowMode 不起作用
+func WithDecimal(scale int32, rounding function.RoundingMode) Option {
+	return func(config *Config) {
+		config.decimal = function.NewDecimalContext(scale, rounding)
+	}
+}
+
+// Arithmetic vm 和常量折叠使用的运算规则
+func (c *Config) Arithmetic() *function.Arithmetic {
+	if c != nil && c.decimal != nil {
+		return function.NewDecimalArithmetic(c.decimal.Scale(), c.decimal.Rounding())
+	}
+	return function.NewArithmetic(c.OverflowMode())
+}
+
+// WithOptimizeConfig 关闭部分优化，比如 ast.OptimizeConfig{WithoutConstantFold: true}
+//
+//	note 常量折叠使用 vm 的运算规则，optimizeConfig 中的 Arithmetic 会被忽略
+func WithOptimizeConfig(optimizeConfig ast.OptimizeConfig) Option {
+	return func(config *Config) {
+		config.optimizeConfig = optimizeConfig
+	}
+}
+
+func (c *Config) OptimizeConfig() ast.OptimizeConfig {
+	if c == nil {
+		return ast.OptimizeConfig{}
+	}
+	return c.optimizeConfig
+}
+
+// WithBackend 选择表达式的执行方式，两种方式的计算结果和错误信息相同，见 Backend
+func WithBackend(backend Backend) Option {
+	return func(config *Config) {
+		config.backend = backend
+	}
+}
+
+func (c *Config) Backend() Backend {
+	if c == nil {
+		return TreeWalkingBackend
+	}
+	return c.backend
+}
+
+// WithCacheSize vm 最多缓存 size 个表达式，超过时淘汰最久没有使用的表达式，size <= 0 时不缓存
+func WithCacheSize(size int) Option {
+	return func(config *Config) {
+		config.cacheSize = size
+		config.hasCacheSize = true
+	}
+}
+
+func (c *Config) CacheSize() int {
+	if c == nil || !c.hasCacheSize {
+		return DefaultCacheSize
+	}
+	return c.cacheSize
 }
 
 func (c *Config) FuncByName() map[string]function.Function {
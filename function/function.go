@@ -1,11 +1,19 @@
 package function
 
-func NewFunction(name string, f interface{}, argumentsNum int, false bool) Function {
+import "fmt"
+
+// Invoker 函数统一的调用方式，参数和返回值都是表达式中的原始值
+//
+//	vm 注册函数的时候将各种形式的函数适配为 Invoker，这样 ast 在常量折叠的时候也可以调用函数
+type Invoker func(args []interface{}) (interface{}, error)
+
+func NewFunction(name string, f interface{}, argumentsNum int, allowFold bool, invoker Invoker) Function {
 	return Function{
 		name:         name,
 		f:            f,
 		argumentsNum: argumentsNum,
-		allowFold:    false,
+		allowFold:    allowFold,
+		invoker:      invoker,
 	}
 }
 
@@ -15,12 +23,18 @@ type Function struct {
 	// func
 	f interface{}
 
-	// number of arguments
+	// number of arguments，-1 表示可变参数
 	argumentsNum int
 
 	// 如果表达式中函数参数是常量，是否允许对结果进行预计算并替换表达式中的函数调用部分
 	// eg: a+add(1,2) -> a+3
+	// note allowFold 为 true 表示函数是纯函数，所以一次计算中参数相同的调用也只计算一次，比如 score(a) > 1 && score(a) < 10
 	allowFold bool
+
+	invoker Invoker
+
+	// builtin 是否是内置函数，见 Builtins
+	builtin bool
 }
 
 func (f Function) Name() string {
@@ -38,3 +52,18 @@ func (f Function) F() interface{} {
 func (f Function) ArgumentsNum() int {
 	return f.argumentsNum
 }
+
+// Call 调用函数，函数中的 panic 会被转换为 error
+func (f Function) Call(args []interface{}) (result interface{}, err error) {
+	if f.invoker == nil {
+		return nil, fmt.Errorf("function '%s' is not callable", f.name)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("call '%s' panic: %v", f.name, r)
+		}
+	}()
+
+	return f.invoker(args)
+}
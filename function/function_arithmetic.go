@@ -0,0 +1,738 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"time"
+)
+
+var (
+	// ErrDivisionByZero 除数或者模数为 0，可以通过 errors.Is 判断
+	ErrDivisionByZero = errors.New("division by zero")
+	// ErrIntegerOverflow 整数运算的结果超出 int64 的范围，只在 OverflowError 模式下返回
+	ErrIntegerOverflow = errors.New("integer overflow")
+)
+
+// OverflowMode 整数运算(+ - * / ** << 以及取负)的结果超出 int64 范围时的处理方式
+type OverflowMode int
+
+const (
+	// OverflowError 返回 ErrIntegerOverflow，默认的处理方式
+	OverflowError OverflowMode = iota
+	// OverflowWrap 和 go 的整数运算相同，按照补码截断，比如 9223372036854775807 + 1 = -9223372036854775808
+	OverflowWrap
+	// OverflowSaturate 结果限制在 [math.MinInt64, math.MaxInt64] 之间
+	OverflowSaturate
+	// OverflowPromote 结果提升为 *big.Int，有小数参与运算时 *big.Int 被转换为 float64
+	//	note 能使用 int64 表示的结果仍然是 int64
+	OverflowPromote
+)
+
+func (mode OverflowMode) String() string {
+	switch mode {
+	case OverflowError:
+		return "error"
+	case OverflowWrap:
+		return "wrap"
+	case OverflowSaturate:
+		return "saturate"
+	case OverflowPromote:
+		return "promote"
+	default:
+		return fmt.Sprintf("OverflowMode(%d)", int(mode))
+	}
+}
+
+// maxPromoteBits OverflowPromote 模式下 ** 和 << 结果的最大位数，避免 2 ** 1000000000 这样的表达式耗尽内存
+const maxPromoteBits = 1 << 16
+
+// Arithmetic 数字运算的规则，vm 和常量折叠使用同一个 Arithmetic 保证两者的结果相同
+//
+//	note 使用 Arithmetic.Builtins 注册的 abs、sum 和 pow 也使用相同的规则，见 config.WithOverflowMode
+type Arithmetic struct {
+	overflow OverflowMode
+	// decimal 不为 nil 时是 decimal 模式，见 NewDecimalArithmetic
+	decimal *DecimalContext
+}
+
+func NewArithmetic(overflow OverflowMode) *Arithmetic {
+	return &Arithmetic{overflow: overflow}
+}
+
+// NewDecimalArithmetic decimal 模式，数字字面量是 Decimal，+ - * / % ** 和比较都按照 Decimal 计算
+//
+//	除法的结果保留 scale 位小数，按照 rounding 舍入，其他运算的结果是准确的
+//	参与运算的 *big.Int、*big.Rat、小数以及数字字符串都会被转换为 Decimal，比如 '12.50' * 2 = 25.00
+func NewDecimalArithmetic(scale int32, rounding RoundingMode) *Arithmetic {
+	return &Arithmetic{decimal: NewDecimalContext(scale, rounding)}
+}
+
+var defaultArithmetic = NewArithmetic(OverflowError)
+
+// DefaultArithmetic 溢出时返回错误，Add、Subtract 等函数使用的规则
+func DefaultArithmetic() *Arithmetic {
+	return defaultArithmetic
+}
+
+// Overflow nil 表示默认的 OverflowError
+func (a *Arithmetic) Overflow() OverflowMode {
+	if a == nil {
+		return OverflowError
+	}
+	return a.overflow
+}
+
+// Decimal decimal 模式下除法的精度和舍入方式，nil 表示不是 decimal 模式
+func (a *Arithmetic) Decimal() *DecimalContext {
+	if a == nil {
+		return nil
+	}
+	return a.decimal
+}
+
+// decimalContext 不是 decimal 模式时 Decimal 和 *big.Rat 参与的运算使用默认的精度
+func (a *Arithmetic) decimalContext() *DecimalContext {
+	if context := a.Decimal(); context != nil {
+		return context
+	}
+	return defaultDecimalContext
+}
+
+// useDecimal decimal 模式下或者有 Decimal、*big.Rat 参与的运算按照 Decimal 计算
+func (a *Arithmetic) useDecimal(args ...interface{}) bool {
+	if a.Decimal() != nil {
+		return true
+	}
+	for _, arg := range args {
+		if isDecimalOperand(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Literal 数字字面量的值，decimal 模式下是 Decimal，否则是 value
+func (a *Arithmetic) Literal(literal string, value interface{}) (interface{}, error) {
+	if a.Decimal() == nil {
+		return value, nil
+	}
+	return ParseDecimal(literal)
+}
+
+// Operator 算术运算符和位运算符对应的函数，decimal 模式下也包括比较运算符，ok 为 false 表示 op 不是这些运算符
+func (a *Arithmetic) Operator(op string) (f func(arg1, arg2 interface{}) (interface{}, error), ok bool) {
+	if a.Decimal() != nil {
+		if compare, ok := comparisonOperators[op]; ok {
+			return func(arg1, arg2 interface{}) (interface{}, error) {
+				arg1, arg2 = a.coerceNumericString(arg1, arg2)
+				return compare(arg1, arg2)
+			}, true
+		}
+	}
+
+	switch op {
+	case "+":
+		return a.Add, true
+	case "-":
+		return a.Subtract, true
+	case "*":
+		return a.Multiplication, true
+	case "/":
+		return a.Division, true
+	case "%":
+		return a.Modulo, true
+	case "**":
+		return a.Power, true
+	case "<<":
+		return a.ShiftLeft, true
+	case ">>":
+		return ShiftRight, true
+	case "&":
+		return BitAnd, true
+	case "|":
+		return BitOr, true
+	case "^":
+		return BitXor, true
+	default:
+		return nil, false
+	}
+}
+
+// BinaryOperator 二元运算符对应的函数，包括 Operator 中的运算符和 function.Operator 中的运算符
+func (a *Arithmetic) BinaryOperator(op string) (f func(arg1, arg2 interface{}) (interface{}, error), ok bool) {
+	if f, ok = a.Operator(op); ok {
+		return f, true
+	}
+	return Operator(op)
+}
+
+// Operator 比较、逻辑、成员、区间以及正则运算符对应的函数，算术运算符和位运算符见 Arithmetic.Operator
+//
+//	note && 和 || 需要短路，左边的值由调用方使用 IsShortCircuit 判断，这里的结果只取决于右边的值
+func Operator(op string) (f func(arg1, arg2 interface{}) (interface{}, error), ok bool) {
+	if compare, ok := comparisonOperators[op]; ok {
+		return compare, true
+	}
+
+	switch op {
+	case "=~", "matches":
+		return Matches, true
+	case "in", "between":
+		return In, true
+	case "not in":
+		return NotIn, true
+	case "..":
+		return func(arg1, arg2 interface{}) (interface{}, error) {
+			return NewRange(arg1, arg2)
+		}, true
+	case "&&", "and", "||", "or":
+		return func(arg1, arg2 interface{}) (interface{}, error) {
+			b, err := Bool(arg2)
+			if err != nil {
+				return nil, fmt.Errorf("invalid right operand of '%s': %v", op, err)
+			}
+			return b, nil
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// IsLogicalOperator 是否是需要短路计算的 && 和 ||
+func IsLogicalOperator(op string) bool {
+	return op == "&&" || op == "and" || op == "||" || op == "or"
+}
+
+// IsShortCircuit 逻辑运算左边的值是否已经可以确定结果，false && x 为 false，true || x 为 true
+func IsShortCircuit(op string, left bool) bool {
+	if op == "&&" || op == "and" {
+		return !left
+	}
+	return left
+}
+
+// Identity x op operand 是否是恒等运算，比如 x * 1、x + 0，operandFirst 为 true 时是 operand op x，比如 1 * x
+//
+//	返回的函数判断 x 的值是否可以直接作为运算结果，x 是其他类型时需要按照原来的运算计算，
+//	比如 x 是 int 时 x * 1 的结果是 int64，x 是 float64 时 -0.0 + 0 的结果是 0.0
+//	note decimal 模式下的运算结果都是 Decimal，所以没有恒等运算
+func (a *Arithmetic) Identity(op string, operand interface{}, operandFirst bool) (identity func(val interface{}) bool, ok bool) {
+	i, isInt := operand.(int64)
+	if a.Decimal() != nil || !isInt {
+		return nil, false
+	}
+
+	var commutative, allowFloat bool
+	switch {
+	case i == 0 && (op == "+" || op == "|" || op == "^"):
+		commutative = true
+	case i == 0 && op == "-":
+		allowFloat = true
+	case i == 0 && (op == "<<" || op == ">>"):
+	case i == 1 && op == "*":
+		commutative, allowFloat = true, true
+	case i == 1 && (op == "/" || op == "**"):
+		allowFloat = true
+	case i == -1 && op == "&":
+		commutative = true
+	default:
+		return nil, false
+	}
+	if operandFirst && !commutative {
+		return nil, false
+	}
+
+	return func(val interface{}) bool {
+		switch val.(type) {
+		case int64:
+			return true
+		case float64:
+			return allowFloat
+		default:
+			return false
+		}
+	}, true
+}
+
+// Add 见 function.Add
+func (a *Arithmetic) Add(arg1, arg2 interface{}) (interface{}, error) {
+	_, isStr1 := arg1.(string)
+	_, isStr2 := arg2.(string)
+	if isStr1 || isStr2 {
+		// note decimal 模式下数字字符串和数字相加是加法，比如 '12.50' + 1 = 13.50，其他情况是字符串拼接
+		if a.Decimal() != nil && (IsNumber(arg1) || IsNumber(arg2)) {
+			if result, err := a.arithmetic(addition, arg1, arg2); err == nil {
+				return result, nil
+			}
+		}
+		return concat(arg1, arg2)
+	}
+
+	if result, handled, err := a.temporalArithmetic("+", arg1, arg2); handled {
+		return result, err
+	}
+	return a.arithmetic(addition, arg1, arg2)
+}
+
+// coerceNumericString decimal 模式下和数字比较的字符串转换为 Decimal，不是数字的字符串保持不变
+func (a *Arithmetic) coerceNumericString(arg1, arg2 interface{}) (interface{}, interface{}) {
+	str1, isStr1 := arg1.(string)
+	str2, isStr2 := arg2.(string)
+	if isStr1 && IsNumber(arg2) {
+		if d, err := a.decimalContext().toDecimal(str1); err == nil {
+			return d, arg2
+		}
+	}
+	if isStr2 && IsNumber(arg1) {
+		if d, err := a.decimalContext().toDecimal(str2); err == nil {
+			return arg1, d
+		}
+	}
+	return arg1, arg2
+}
+
+var comparisonOperators = map[string]func(arg1, arg2 interface{}) (interface{}, error){
+	"==": Equal,
+	"!=": NotEqual,
+	"<":  Less,
+	"<=": LessOrEqual,
+	">":  Greater,
+	">=": GreaterOrEqual,
+}
+
+func (a *Arithmetic) Subtract(arg1, arg2 interface{}) (interface{}, error) {
+	if result, handled, err := a.temporalArithmetic("-", arg1, arg2); handled {
+		return result, err
+	}
+	return a.arithmetic(subtraction, arg1, arg2)
+}
+
+func (a *Arithmetic) Multiplication(arg1, arg2 interface{}) (interface{}, error) {
+	if result, handled, err := a.temporalArithmetic("*", arg1, arg2); handled {
+		return result, err
+	}
+	return a.arithmetic(multiplication, arg1, arg2)
+}
+
+func (a *Arithmetic) Division(arg1, arg2 interface{}) (interface{}, error) {
+	if result, handled, err := a.temporalArithmetic("/", arg1, arg2); handled {
+		return result, err
+	}
+	return a.arithmetic(division, arg1, arg2)
+}
+
+func (a *Arithmetic) Modulo(arg1, arg2 interface{}) (interface{}, error) {
+	if result, handled, err := a.temporalArithmetic("%", arg1, arg2); handled {
+		return result, err
+	}
+	return a.arithmetic(modulo, arg1, arg2)
+}
+
+// Negative -math.MinInt64 超出了 int64 的范围
+func (a *Arithmetic) Negative(val interface{}) (interface{}, error) {
+	if d, ok := val.(time.Duration); ok {
+		if d == math.MinInt64 {
+			return a.durationOverflowed(new(big.Int).Neg(big.NewInt(int64(d))), "-(%v)", d)
+		}
+		return -d, nil
+	}
+
+	if a.useDecimal(val) {
+		d, err := a.decimalContext().toDecimal(val)
+		if err != nil {
+			return nil, err
+		}
+		return d.Neg(), nil
+	}
+
+	if b, ok := val.(*big.Int); ok && b != nil && !b.IsInt64() {
+		return a.overflowed(new(big.Int).Neg(b), "-(%v)", b)
+	}
+
+	i, f, isFloat, err := Number(val)
+	if err != nil {
+		return nil, err
+	}
+
+	if isFloat {
+		return -f, nil
+	}
+	if i == math.MinInt64 {
+		return a.overflowed(new(big.Int).Neg(big.NewInt(i)), "-(%d)", i)
+	}
+	return -i, nil
+}
+
+// Power 见 function.Power
+func (a *Arithmetic) Power(arg1, arg2 interface{}) (interface{}, error) {
+	if a.useDecimal(arg1, arg2) {
+		if result, ok, err := a.decimalPower(arg1, arg2); ok {
+			return result, err
+		}
+	}
+
+	if b, ok := arg1.(*big.Int); ok && b != nil && !b.IsInt64() {
+		if exponent, err := Int64(arg2); err == nil && exponent >= 0 {
+			return a.powOverflowed(b, exponent)
+		}
+	}
+
+	base, baseFloat, isFloat1, err := Number(arg1)
+	if err != nil {
+		return nil, fmt.Errorf("invalid left operand of '**': %v", err)
+	}
+
+	exponent, exponentFloat, isFloat2, err := Number(arg2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid right operand of '**': %v", err)
+	}
+
+	if !isFloat1 && !isFloat2 && exponent >= 0 {
+		if result, ok := powInt64(base, exponent); ok {
+			return result, nil
+		}
+		return a.powOverflowed(big.NewInt(base), exponent)
+	}
+
+	if !isFloat1 {
+		baseFloat = float64(base)
+	}
+	if !isFloat2 {
+		exponentFloat = float64(exponent)
+	}
+	// note 0 的负数次方相当于除以 0，和 1 / 0.0 相同返回 ErrDivisionByZero，而不是 +Inf
+	if baseFloat == 0 && exponentFloat < 0 {
+		return nil, divisionByZeroError("**", arg1, arg2)
+	}
+	return math.Pow(baseFloat, exponentFloat), nil
+}
+
+// ShiftLeft 见 function.ShiftLeft
+func (a *Arithmetic) ShiftLeft(arg1, arg2 interface{}) (interface{}, error) {
+	i, shift, err := shiftOperands("<<", arg1, arg2)
+	if err != nil {
+		return nil, err
+	}
+
+	if i == 0 || (shift < 63 && i<<shift>>shift == i) {
+		return i << shift, nil
+	}
+
+	bits := shift
+	if shift <= maxPromoteBits {
+		bits += int64(bitLen(i))
+	}
+	return a.hugeOverflowed(i < 0, bits,
+		func() *big.Int { return new(big.Int).Lsh(big.NewInt(i), uint(shift)) },
+		func() int64 { return i << shift },
+		"%d << %d", i, shift,
+	)
+}
+
+// decimalPower 指数是整数时按照 Decimal 计算，ok 为 false 表示需要按照 float64 计算，比如 2 ** 0.5
+func (a *Arithmetic) decimalPower(arg1, arg2 interface{}) (result interface{}, ok bool, err error) {
+	context := a.decimalContext()
+	base, err := context.toDecimal(arg1)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid left operand of '**': %v", err)
+	}
+	exponentDecimal, err := context.toDecimal(arg2)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid right operand of '**': %v", err)
+	}
+
+	exponent, isInteger := exponentDecimal.Int64()
+	if !isInteger {
+		return nil, false, nil
+	}
+	if exponent > maxDecimalExponent || exponent < -maxDecimalExponent {
+		return nil, true, &arithmeticError{
+			msg:  fmt.Sprintf("%v ** %d exceeds the max exponent %d", base, exponent, maxDecimalExponent),
+			kind: ErrIntegerOverflow,
+		}
+	}
+
+	powered := Decimal{
+		unscaled: new(big.Int).Exp(base.int(), big.NewInt(abs64(exponent)), nil),
+		scale:    base.scale * int32(abs64(exponent)),
+	}
+	if exponent >= 0 {
+		return powered, true, nil
+	}
+
+	result, err = NewDecimal(1, 0).Quo(powered, context.Scale(), context.Rounding())
+	if err != nil {
+		return nil, true, divisionByZeroError("**", base, exponent)
+	}
+	return result, true, nil
+}
+
+func abs64(i int64) int64 {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// integerOperation 数字运算，checked 的 ok 为 false 表示溢出，溢出时使用 exact 计算准确的结果
+//
+//	decimal 是 decimal 模式下的运算
+type integerOperation struct {
+	op      string
+	checked func(i1, i2 int64) (int64, bool)
+	exact   func(b1, b2 *big.Int) *big.Int
+	float   func(f1, f2 float64) float64
+	decimal func(d1, d2 Decimal, context *DecimalContext) (Decimal, error)
+}
+
+var (
+	addition = integerOperation{
+		op: "+",
+		checked: func(i1, i2 int64) (int64, bool) {
+			c := i1 + i2
+			return c, (c > i1) == (i2 > 0)
+		},
+		exact: func(b1, b2 *big.Int) *big.Int { return new(big.Int).Add(b1, b2) },
+		float: func(f1, f2 float64) float64 { return f1 + f2 },
+		decimal: func(d1, d2 Decimal, _ *DecimalContext) (Decimal, error) {
+			return d1.Add(d2), nil
+		},
+	}
+	subtraction = integerOperation{
+		op: "-",
+		checked: func(i1, i2 int64) (int64, bool) {
+			c := i1 - i2
+			return c, (c < i1) == (i2 > 0)
+		},
+		exact: func(b1, b2 *big.Int) *big.Int { return new(big.Int).Sub(b1, b2) },
+		float: func(f1, f2 float64) float64 { return f1 - f2 },
+		decimal: func(d1, d2 Decimal, _ *DecimalContext) (Decimal, error) {
+			return d1.Sub(d2), nil
+		},
+	}
+	multiplication = integerOperation{
+		op:      "*",
+		checked: mulInt64,
+		exact:   func(b1, b2 *big.Int) *big.Int { return new(big.Int).Mul(b1, b2) },
+		float:   func(f1, f2 float64) float64 { return f1 * f2 },
+		decimal: func(d1, d2 Decimal, _ *DecimalContext) (Decimal, error) {
+			return d1.Mul(d2), nil
+		},
+	}
+	// division 整数除法向 0 截断，和 go 相同，只有 math.MinInt64 / -1 会溢出
+	division = integerOperation{
+		op: "/",
+		checked: func(i1, i2 int64) (int64, bool) {
+			if i1 == math.MinInt64 && i2 == -1 {
+				return 0, false
+			}
+			return i1 / i2, true
+		},
+		exact: func(b1, b2 *big.Int) *big.Int { return new(big.Int).Quo(b1, b2) },
+		float: func(f1, f2 float64) float64 { return f1 / f2 },
+		decimal: func(d1, d2 Decimal, context *DecimalContext) (Decimal, error) {
+			return d1.Quo(d2, context.Scale(), context.Rounding())
+		},
+	}
+	modulo = integerOperation{
+		op:      "%",
+		checked: func(i1, i2 int64) (int64, bool) { return i1 % i2, true },
+		exact:   func(b1, b2 *big.Int) *big.Int { return new(big.Int).Rem(b1, b2) },
+		float:   math.Mod,
+		decimal: func(d1, d2 Decimal, _ *DecimalContext) (Decimal, error) {
+			return d1.Rem(d2)
+		},
+	}
+)
+
+// arithmetic 两个参数都是整数时按照整数计算，否则提升为 float64 进行计算
+//
+//	note 除数为 0 时返回 ErrDivisionByZero，包括小数，1 / 0.0 不会得到 +Inf
+func (a *Arithmetic) arithmetic(operation integerOperation, arg1, arg2 interface{}) (interface{}, error) {
+	isDivision := operation.op == "/" || operation.op == "%"
+
+	if a.useDecimal(arg1, arg2) {
+		return a.decimalArithmetic(operation, arg1, arg2)
+	}
+
+	if b1, b2, ok := bigOperands(arg1, arg2); ok {
+		if isDivision && b2.Sign() == 0 {
+			return nil, divisionByZeroError(operation.op, b1, b2)
+		}
+		return a.overflowed(operation.exact(b1, b2), "%v %s %v", b1, operation.op, b2)
+	}
+
+	i1, f1, isFloat1, err := Number(arg1)
+	if err != nil {
+		return nil, fmt.Errorf("invalid left operand of '%s': %v", operation.op, err)
+	}
+
+	i2, f2, isFloat2, err := Number(arg2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid right operand of '%s': %v", operation.op, err)
+	}
+
+	if !isFloat1 && !isFloat2 {
+		if isDivision && i2 == 0 {
+			return nil, divisionByZeroError(operation.op, i1, i2)
+		}
+		if result, ok := operation.checked(i1, i2); ok {
+			return result, nil
+		}
+		return a.overflowed(operation.exact(big.NewInt(i1), big.NewInt(i2)), "%d %s %d", i1, operation.op, i2)
+	}
+
+	if !isFloat1 {
+		f1 = float64(i1)
+	}
+	if !isFloat2 {
+		f2 = float64(i2)
+	}
+	if isDivision && f2 == 0 {
+		return nil, divisionByZeroError(operation.op, f1, f2)
+	}
+	return operation.float(f1, f2), nil
+}
+
+// decimalArithmetic Decimal 的运算不会溢出，所以不需要处理 OverflowMode
+func (a *Arithmetic) decimalArithmetic(operation integerOperation, arg1, arg2 interface{}) (interface{}, error) {
+	context := a.decimalContext()
+	d1, err := context.toDecimal(arg1)
+	if err != nil {
+		return nil, fmt.Errorf("invalid left operand of '%s': %v", operation.op, err)
+	}
+
+	d2, err := context.toDecimal(arg2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid right operand of '%s': %v", operation.op, err)
+	}
+
+	result, err := operation.decimal(d1, d2, context)
+	if errors.Is(err, ErrDivisionByZero) {
+		return nil, divisionByZeroError(operation.op, d1, d2)
+	}
+	return result, err
+}
+
+// overflowed 按照 OverflowMode 处理整数运算准确的结果 exact，format 和 args 用于描述溢出的运算
+func (a *Arithmetic) overflowed(exact *big.Int, format string, args ...interface{}) (interface{}, error) {
+	if exact.IsInt64() {
+		return exact.Int64(), nil
+	}
+
+	return a.hugeOverflowed(exact.Sign() < 0, int64(exact.BitLen()),
+		func() *big.Int { return exact },
+		func() int64 { return wrapInt64(exact) },
+		format, args...,
+	)
+}
+
+// powOverflowed 底数是 *big.Int 或者 int64 的结果溢出时的 **
+func (a *Arithmetic) powOverflowed(base *big.Int, exponent int64) (interface{}, error) {
+	if exponent == 0 {
+		return int64(1), nil
+	}
+	if exponent == 1 {
+		return a.overflowed(base, "%v ** %d", base, exponent)
+	}
+
+	bits := exponent
+	if exponent <= maxPromoteBits {
+		bits *= int64(base.BitLen())
+	}
+	return a.hugeOverflowed(base.Sign() < 0 && exponent%2 == 1, bits,
+		func() *big.Int { return new(big.Int).Exp(base, big.NewInt(exponent), nil) },
+		func() int64 { return wrapInt64(new(big.Int).Exp(base, big.NewInt(exponent), twoTo64)) },
+		"%v ** %d", base, exponent,
+	)
+}
+
+// hugeOverflowed ** 和 << 的结果可能非常大，所以只在需要时计算准确的结果或者截断之后的结果
+//
+//	negative 结果是否是负数，bits 结果的位数，超过 maxPromoteBits 时 OverflowPromote 模式也返回错误
+func (a *Arithmetic) hugeOverflowed(negative bool, bits int64, exact func() *big.Int, wrapped func() int64,
+	format string, args ...interface{}) (interface{}, error) {
+	switch a.Overflow() {
+	case OverflowWrap:
+		return wrapped(), nil
+	case OverflowSaturate:
+		if negative {
+			return int64(math.MinInt64), nil
+		}
+		return int64(math.MaxInt64), nil
+	case OverflowPromote:
+		if bits > maxPromoteBits {
+			return nil, &arithmeticError{
+				msg:  fmt.Sprintf(format, args...) + fmt.Sprintf(" exceeds %d bits", maxPromoteBits),
+				kind: ErrIntegerOverflow,
+			}
+		}
+		return exact(), nil
+	default:
+		return nil, &arithmeticError{msg: fmt.Sprintf(format, args...) + " overflows int64", kind: ErrIntegerOverflow}
+	}
+}
+
+var twoTo64 = new(big.Int).Lsh(big.NewInt(1), 64)
+
+// wrapInt64 取补码的低 64 位
+func wrapInt64(exact *big.Int) int64 {
+	return int64(new(big.Int).Mod(exact, twoTo64).Uint64())
+}
+
+// bitLen 绝对值的位数，和 big.Int.BitLen 相同
+func bitLen(i int64) int {
+	if i < 0 {
+		return bits.Len64(uint64(-(i + 1)) + 1)
+	}
+	return bits.Len64(uint64(i))
+}
+
+// bigOperands 至少有一个参数是 *big.Int 并且另一个参数是整数时按照 *big.Int 计算
+func bigOperands(arg1, arg2 interface{}) (*big.Int, *big.Int, bool) {
+	_, isBig1 := arg1.(*big.Int)
+	_, isBig2 := arg2.(*big.Int)
+	if !isBig1 && !isBig2 {
+		return nil, nil, false
+	}
+
+	b1, ok1 := bigInteger(arg1)
+	b2, ok2 := bigInteger(arg2)
+	return b1, b2, ok1 && ok2
+}
+
+func bigInteger(val interface{}) (*big.Int, bool) {
+	if b, ok := val.(*big.Int); ok {
+		if b == nil {
+			return new(big.Int), true
+		}
+		return b, true
+	}
+
+	i, err := Int64(val)
+	if err != nil {
+		return nil, false
+	}
+	return big.NewInt(i), true
+}
+
+func divisionByZeroError(op string, arg1, arg2 interface{}) error {
+	return &arithmeticError{msg: fmt.Sprintf("%v %s %v: division by zero", arg1, op, arg2), kind: ErrDivisionByZero}
+}
+
+// arithmeticError 保留具体的错误信息，同时可以通过 errors.Is 判断是 ErrDivisionByZero 还是 ErrIntegerOverflow
+type arithmeticError struct {
+	msg  string
+	kind error
+}
+
+func (e *arithmeticError) Error() string {
+	return e.msg
+}
+
+func (e *arithmeticError) Unwrap() error {
+	return e.kind
+}
@@ -0,0 +1,93 @@
+package function
+
+import "fmt"
+
+// BitAnd 按位与，两个参数都必须是整数
+func BitAnd(arg1, arg2 interface{}) (interface{}, error) {
+	i1, i2, err := integerOperands("&", arg1, arg2)
+	if err != nil {
+		return nil, err
+	}
+	return i1 & i2, nil
+}
+
+// BitOr 按位或
+func BitOr(arg1, arg2 interface{}) (interface{}, error) {
+	i1, i2, err := integerOperands("|", arg1, arg2)
+	if err != nil {
+		return nil, err
+	}
+	return i1 | i2, nil
+}
+
+// BitXor 按位异或
+func BitXor(arg1, arg2 interface{}) (interface{}, error) {
+	i1, i2, err := integerOperands("^", arg1, arg2)
+	if err != nil {
+		return nil, err
+	}
+	return i1 ^ i2, nil
+}
+
+// ShiftLeft 左移，位移数不能是负数，结果超出 int64 范围时返回 ErrIntegerOverflow
+func ShiftLeft(arg1, arg2 interface{}) (interface{}, error) {
+	return defaultArithmetic.ShiftLeft(arg1, arg2)
+}
+
+// ShiftRight 算术右移，负数的符号位保持不变，比如 -8 >> 1 = -4
+func ShiftRight(arg1, arg2 interface{}) (interface{}, error) {
+	i, shift, err := shiftOperands(">>", arg1, arg2)
+	if err != nil {
+		return nil, err
+	}
+
+	if shift >= 64 {
+		shift = 63
+	}
+	return i >> shift, nil
+}
+
+// Power 幂运算
+//
+//	底数是整数并且指数是非负整数时结果是 int64，溢出时返回 ErrIntegerOverflow，比如 2 ** 10 = 1024
+//	其他情况结果是 float64，比如 2 ** -1 = 0.5、4 ** 0.5 = 2.0
+func Power(arg1, arg2 interface{}) (interface{}, error) {
+	return defaultArithmetic.Power(arg1, arg2)
+}
+
+// integerOperands 位运算的参数必须是整数，note 和数字运算相同，nil 被当作 0
+func integerOperands(op string, arg1, arg2 interface{}) (int64, int64, error) {
+	i1, err := integerOperand(arg1)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid left operand of '%s': %v", op, err)
+	}
+
+	i2, err := integerOperand(arg2)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid right operand of '%s': %v", op, err)
+	}
+	return i1, i2, nil
+}
+
+func integerOperand(val interface{}) (int64, error) {
+	i, f, isFloat, err := Number(val)
+	if err != nil {
+		return 0, err
+	}
+	if isFloat {
+		return 0, fmt.Errorf("%v(%T) is not an integer", f, val)
+	}
+	return i, nil
+}
+
+func shiftOperands(op string, arg1, arg2 interface{}) (int64, int64, error) {
+	i, shift, err := integerOperands(op, arg1, arg2)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if shift < 0 {
+		return 0, 0, fmt.Errorf("invalid right operand of '%s': negative shift count %d", op, shift)
+	}
+	return i, shift, nil
+}
@@ -1,17 +1,169 @@
 package function
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
 
-// Add todo 字符串相加标识字符串拼接，所以这里还应该是 interface{}
-func Add(args ...int64) int64 {
-	result := int64(0)
-	for _, arg := range args {
-		result = result + arg
+// Add 加法
+//
+//	两个参数都是整数时结果是 int64，否则提升为 float64 进行计算
+//	有字符串参与时是字符串拼接，另一个参数是数字时会被转换为字符串，比如 'a' + 1.5 = 'a1.5'
+//	时间类型的运算见 temporalArithmetic
+//	整数运算溢出时返回 ErrIntegerOverflow，其他处理方式见 Arithmetic
+func Add(arg1, arg2 interface{}) (interface{}, error) {
+	return defaultArithmetic.Add(arg1, arg2)
+}
+
+// Subtract 减法
+func Subtract(arg1, arg2 interface{}) (interface{}, error) {
+	return defaultArithmetic.Subtract(arg1, arg2)
+}
+
+// Multiplication 乘法
+func Multiplication(arg1, arg2 interface{}) (interface{}, error) {
+	return defaultArithmetic.Multiplication(arg1, arg2)
+}
+
+// Division 除法，两个整数相除的结果仍然是整数，比如 7/2 = 3，7/2.0 = 3.5
+//
+//	除数为 0 时返回 ErrDivisionByZero
+func Division(arg1, arg2 interface{}) (interface{}, error) {
+	return defaultArithmetic.Division(arg1, arg2)
+}
+
+// Modulo 取余，有小数参与时使用 math.Mod，结果符号和被除数相同
+func Modulo(arg1, arg2 interface{}) (interface{}, error) {
+	return defaultArithmetic.Modulo(arg1, arg2)
+}
+
+// Negative 取负数
+func Negative(val interface{}) (interface{}, error) {
+	return defaultArithmetic.Negative(val)
+}
+
+// Positive 取正数，note 结果是统一之后的 int64 或者 float64，Decimal 保持不变
+func Positive(val interface{}) (interface{}, error) {
+	if d, ok := val.(Decimal); ok {
+		return d, nil
+	}
+
+	i, f, isFloat, err := Number(val)
+	if err != nil {
+		return nil, err
 	}
 
-	return result
+	if isFloat {
+		return f, nil
+	}
+	return i, nil
+}
+
+// concat 字符串拼接，note nil 被当作空字符串
+func concat(arg1, arg2 interface{}) (interface{}, error) {
+	str1, err := concatOperand(arg1)
+	if err != nil {
+		return nil, fmt.Errorf("invalid left operand of '+': %v", err)
+	}
+
+	str2, err := concatOperand(arg2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid right operand of '+': %v", err)
+	}
+
+	return str1 + str2, nil
 }
 
+func concatOperand(val interface{}) (string, error) {
+	if val == nil {
+		return "", nil
+	}
+
+	if str, ok := val.(string); ok {
+		return str, nil
+	}
+
+	if IsNumber(val) {
+		return FormatNumber(val)
+	}
+
+	switch v := val.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return v.String(), nil
+	}
+
+	return "", fmt.Errorf("can not concat %T with string", val)
+}
+
+// FormatNumber 数字转换为字符串，小数不使用科学计数法，比如 1500.0 -> "1500"、0.25 -> "0.25"
+func FormatNumber(val interface{}) (string, error) {
+	switch v := val.(type) {
+	case *big.Int:
+		if v != nil {
+			return v.String(), nil
+		}
+	case Decimal:
+		return v.String(), nil
+	case *big.Rat:
+		if v != nil {
+			return DecimalFromRat(v, DefaultDecimalScale, RoundHalfUp).stripZeros().String(), nil
+		}
+	}
+
+	i, f, isFloat, err := Number(val)
+	if err != nil {
+		return "", err
+	}
+
+	if isFloat {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+	return strconv.FormatInt(i, 10), nil
+}
+
+// Number 将数字统一为 int64 或者 float64
+//
+//	isFloat 为 true 时结果是 f，否则结果是 i
+//	note nil 被当作 0，表达式中不存在的变量参与计算时按照 0 处理
+func Number(val interface{}) (i int64, f float64, isFloat bool, err error) {
+	switch v := val.(type) {
+	case float32:
+		return 0, float64(v), true, nil
+	case float64:
+		return 0, v, true, nil
+	case Decimal:
+		// note 整数的 Decimal 按照 int64 处理，这样可以作为下标、函数参数等
+		if i, ok := v.Int64(); ok {
+			return i, 0, false, nil
+		}
+		return 0, v.Float64(), true, nil
+	case *big.Rat:
+		if v == nil {
+			return 0, 0, false, nil
+		}
+		if v.IsInt() && v.Num().IsInt64() {
+			return v.Num().Int64(), 0, false, nil
+		}
+		f, _ = v.Float64()
+		return 0, f, true, nil
+	case *big.Int:
+		// note OverflowPromote 模式下的结果，超出 int64 范围时按照 float64 处理
+		if v == nil || v.IsInt64() {
+			i, err = Int64(val)
+			return i, 0, false, err
+		}
+		f, _ = new(big.Float).SetInt(v).Float64()
+		return 0, f, true, nil
+	default:
+		i, err = Int64(val)
+		return i, 0, false, err
+	}
+}
 
 func Int64(val interface{}) (int64, error) {
 	if val == nil {
@@ -23,21 +175,56 @@ func Int64(val interface{}) (int64, error) {
 		return int64(v), nil
 	case int8:
 		return int64(v), nil
+	case int16:
+		return int64(v), nil
 	case int32:
 		return int64(v), nil
 	case int64:
 		return v, nil
+	case uint:
+		return uint64ToInt64(uint64(v))
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return uint64ToInt64(v)
+	case Decimal:
+		if i, ok := v.Int64(); ok {
+			return i, nil
+		}
+		return 0, fmt.Errorf("%v 不是 int64 范围内的整数", v)
+	case *big.Int:
+		if v == nil {
+			return 0, nil
+		}
+		if !v.IsInt64() {
+			return 0, fmt.Errorf("%v 超出了 int64 的范围", v)
+		}
+		return v.Int64(), nil
 	default:
 		return 0, fmt.Errorf("无法将类型 %T 转换为 int64", val)
 	}
 }
 
+// Float64 将数字转换为 float64
+func Float64(val interface{}) (float64, error) {
+	i, f, isFloat, err := Number(val)
+	if err != nil {
+		return 0, fmt.Errorf("无法将类型 %T 转换为 float64", val)
+	}
+
+	if isFloat {
+		return f, nil
+	}
+	return float64(i), nil
+}
 
-func Multiplication(args ...int64) int64 {
-	// 参数为0，返回1，参考 x^0 = 1
-	result := int64(1)
-	for _, arg := range args {
-		result = result * arg
+func uint64ToInt64(v uint64) (int64, error) {
+	if v > math.MaxInt64 {
+		return 0, fmt.Errorf("%d 超出了 int64 的范围", v)
 	}
-	return result
+	return int64(v), nil
 }
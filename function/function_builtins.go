@@ -0,0 +1,89 @@
+package function
+
+import (
+	"fmt"
+	"time"
+)
+
+// builtinFunctions vm 默认注册的内置函数，见 Builtins
+var builtinFunctions = concatFunctions(stringFunctions, mathFunctions, timeFunctions, collectionFunctions,
+	regexFunctions, []Function{NowFunction(time.Now)})
+
+// Builtins 内置函数，abs、sum 和 pow 使用 DefaultArithmetic，note 返回的是拷贝，修改结果不会影响内置函数
+func Builtins() []Function {
+	return append([]Function{}, builtinFunctions...)
+}
+
+// Builtins vm 注册的内置函数，abs、sum 和 pow 的整数溢出以及 decimal 模式和 a 的运算符相同，
+// 这样 sum([a, 1]) 和 a + 1 的结果相同
+func (a *Arithmetic) Builtins() []Function {
+	functions := Builtins()
+	for i, f := range functions {
+		switch f.Name() {
+		case "abs":
+			functions[i] = newBuiltin("abs", 1, 1, a.builtinAbs)
+		case "sum":
+			functions[i] = newBuiltin("sum", 1, 2, a.builtinSum)
+		case "pow":
+			functions[i] = newBuiltin("pow", 2, 2, a.builtinPow)
+		}
+	}
+	return functions
+}
+
+func concatFunctions(groups ...[]Function) []Function {
+	functions := make([]Function, 0)
+	for _, group := range groups {
+		functions = append(functions, group...)
+	}
+	return functions
+}
+
+// newBuiltin 内置函数都是纯函数，参数是常量时允许折叠
+//
+//	minArgs 和 maxArgs 不相等时注册为可变参数函数，参数个数在调用的时候检查
+func newBuiltin(name string, minArgs, maxArgs int, invoker Invoker) Function {
+	var f Function
+	if minArgs == maxArgs {
+		f = NewFunction(name, invoker, minArgs, true, invoker)
+	} else {
+		f = NewFunction(name, invoker, -1, true, func(args []interface{}) (interface{}, error) {
+			if len(args) < minArgs || (maxArgs >= 0 && len(args) > maxArgs) {
+				return nil, argumentsNumError(name, minArgs, maxArgs, len(args))
+			}
+			return invoker(args)
+		})
+	}
+
+	f.builtin = true
+	return f
+}
+
+func argumentsNumError(name string, minArgs, maxArgs, actual int) error {
+	if maxArgs < 0 {
+		return fmt.Errorf("'%s' require at least %d arguments instead of %d", name, minArgs, actual)
+	}
+	return fmt.Errorf("'%s' require %d to %d arguments instead of %d", name, minArgs, maxArgs, actual)
+}
+
+// ArgumentError 第 index(从1开始) 个参数不合法
+func ArgumentError(funcName string, index int, err error) error {
+	return fmt.Errorf("argument %d of '%s': %v", index, funcName, err)
+}
+
+// stringArg 第 index(从0开始) 个参数必须是 string
+func stringArg(funcName string, args []interface{}, index int) (string, error) {
+	if str, ok := args[index].(string); ok {
+		return str, nil
+	}
+	return "", ArgumentError(funcName, index+1, fmt.Errorf("can not use %v (%T) as string", args[index], args[index]))
+}
+
+// intArg 第 index(从0开始) 个参数必须是整数，note nil 不会被当作 0
+func intArg(funcName string, args []interface{}, index int) (int64, error) {
+	i, err := toInteger(args[index])
+	if err != nil {
+		return 0, ArgumentError(funcName, index+1, err)
+	}
+	return i, nil
+}
@@ -0,0 +1,204 @@
+package function
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// Index 下标访问，支持 slice、array、string 和 map
+//
+//	slice、array 和 string 的下标必须是整数，string 按照 rune 访问
+//	map 中不存在的 key 返回 nil
+func Index(object, index interface{}) (interface{}, error) {
+	if IsNil(object) {
+		return nil, fmt.Errorf("can not index nil value")
+	}
+
+	if str, ok := object.(string); ok {
+		runes := []rune(str)
+		i, err := toIndex(index, len(runes))
+		if err != nil {
+			return nil, err
+		}
+		return string(runes[i]), nil
+	}
+
+	value := indirect(reflect.ValueOf(object))
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		i, err := toIndex(index, value.Len())
+		if err != nil {
+			return nil, err
+		}
+		return value.Index(i).Interface(), nil
+	case reflect.Map:
+		key, err := toMapKey(index, value.Type().Key())
+		if err != nil {
+			return nil, err
+		}
+		element := value.MapIndex(key)
+		if !element.IsValid() {
+			return nil, nil
+		}
+		return element.Interface(), nil
+	default:
+		return nil, fmt.Errorf("can not index value of type %T", object)
+	}
+}
+
+// Slice 切片，支持 slice、array 和 string
+//
+//	low 和 high 为 nil 时分别表示从头开始和到结尾为止，string 按照 rune 切片
+func Slice(object, low, high interface{}) (interface{}, error) {
+	if IsNil(object) {
+		return nil, fmt.Errorf("can not slice nil value")
+	}
+
+	if str, ok := object.(string); ok {
+		runes := []rune(str)
+		l, h, err := toSliceBounds(low, high, len(runes))
+		if err != nil {
+			return nil, err
+		}
+		return string(runes[l:h]), nil
+	}
+
+	value := indirect(reflect.ValueOf(object))
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		l, h, err := toSliceBounds(low, high, value.Len())
+		if err != nil {
+			return nil, err
+		}
+
+		// note 不可寻址的数组不能直接切片，需要先拷贝
+		if value.Kind() == reflect.Array && !value.CanAddr() {
+			arrayPtr := reflect.New(value.Type())
+			arrayPtr.Elem().Set(value)
+			value = arrayPtr.Elem()
+		}
+		return value.Slice(l, h).Interface(), nil
+	default:
+		return nil, fmt.Errorf("can not slice value of type %T", object)
+	}
+}
+
+func toIndex(index interface{}, length int) (int, error) {
+	i, err := toInteger(index)
+	if err != nil {
+		return 0, fmt.Errorf("invalid index: %v", err)
+	}
+
+	if i < 0 || i >= int64(length) {
+		return 0, fmt.Errorf("index %d out of range with length %d", i, length)
+	}
+	return int(i), nil
+}
+
+func toSliceBounds(low, high interface{}, length int) (int, int, error) {
+	l, h := int64(0), int64(length)
+
+	var err error
+	if low != nil {
+		if l, err = toInteger(low); err != nil {
+			return 0, 0, fmt.Errorf("invalid slice index: %v", err)
+		}
+	}
+	if high != nil {
+		if h, err = toInteger(high); err != nil {
+			return 0, 0, fmt.Errorf("invalid slice index: %v", err)
+		}
+	}
+
+	if l < 0 || h > int64(length) || l > h {
+		return 0, 0, fmt.Errorf("slice bounds [%d:%d] out of range with length %d", l, h, length)
+	}
+	return int(l), int(h), nil
+}
+
+// toInteger 下标必须是整数，note 和数字运算不同，nil 不会被当作 0
+func toInteger(val interface{}) (int64, error) {
+	if val == nil || !IsNumber(val) {
+		return 0, fmt.Errorf("%v(%T) is not an integer", val, val)
+	}
+
+	i, f, isFloat, err := Number(val)
+	if err != nil {
+		return 0, err
+	}
+	if isFloat {
+		// note 超出 int64 范围的 *big.Int 和 Decimal 在 Number 中被转换为 float64，错误信息使用准确的值
+		switch v := val.(type) {
+		case *big.Int:
+			return 0, fmt.Errorf("%s(%T) is out of the int64 range", v.String(), val)
+		case Decimal:
+			if v.IsInteger() {
+				return 0, fmt.Errorf("%s(%T) is out of the int64 range", v.String(), val)
+			}
+			return 0, fmt.Errorf("%s(%T) is not an integer", v.String(), val)
+		}
+		return 0, fmt.Errorf("%v(%T) is not an integer", f, val)
+	}
+	return i, nil
+}
+
+// toMapKey 将下标转换为 map 的 key 类型，数字之间可以相互转换，比如 int64 -> int
+func toMapKey(index interface{}, keyType reflect.Type) (reflect.Value, error) {
+	if index == nil {
+		return reflect.Value{}, fmt.Errorf("invalid map key nil for key type %s", keyType)
+	}
+
+	key := reflect.ValueOf(index)
+	if key.Type().AssignableTo(keyType) {
+		return key, nil
+	}
+
+	if IsNumber(index) && isNumberKind(keyType.Kind()) {
+		if isNumberKind(key.Kind()) {
+			return key.Convert(keyType), nil
+		}
+		return bigMapKey(index, keyType)
+	}
+
+	return reflect.Value{}, fmt.Errorf("invalid map key %v(%T) for key type %s", index, index, keyType)
+}
+
+// bigMapKey *big.Int、*big.Rat 和 Decimal 不能直接使用 reflect 转换，整数的 key 必须是 int64 范围内的整数
+func bigMapKey(index interface{}, keyType reflect.Type) (reflect.Value, error) {
+	if keyType.Kind() == reflect.Float32 || keyType.Kind() == reflect.Float64 {
+		f, err := Float64(index)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid map key: %v", err)
+		}
+		return reflect.ValueOf(f).Convert(keyType), nil
+	}
+
+	i, err := toInteger(index)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("invalid map key: %v", err)
+	}
+	return reflect.ValueOf(i).Convert(keyType), nil
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// indirect 解开指针和接口
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return value
+		}
+		value = value.Elem()
+	}
+	return value
+}
@@ -0,0 +1,196 @@
+package function
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// Equal 判断两个值是否相等
+//
+//	数字之间按照数值比较，比如 1 == 1.0
+//	其他类型先使用 == 比较，不可比较的类型(比如 slice、map)使用 reflect.DeepEqual
+func Equal(arg1, arg2 interface{}) (interface{}, error) {
+	return isEqual(arg1, arg2), nil
+}
+
+// NotEqual 判断两个值是否不相等
+func NotEqual(arg1, arg2 interface{}) (interface{}, error) {
+	return !isEqual(arg1, arg2), nil
+}
+
+// Less <
+func Less(arg1, arg2 interface{}) (interface{}, error) {
+	result, err := compare("<", arg1, arg2)
+	return result < 0, err
+}
+
+// LessOrEqual <=
+func LessOrEqual(arg1, arg2 interface{}) (interface{}, error) {
+	result, err := compare("<=", arg1, arg2)
+	return result <= 0, err
+}
+
+// Greater >
+func Greater(arg1, arg2 interface{}) (interface{}, error) {
+	result, err := compare(">", arg1, arg2)
+	return result > 0, err
+}
+
+// GreaterOrEqual >=
+func GreaterOrEqual(arg1, arg2 interface{}) (interface{}, error) {
+	result, err := compare(">=", arg1, arg2)
+	return result >= 0, err
+}
+
+// Not 逻辑非
+func Not(val interface{}) (interface{}, error) {
+	b, err := Bool(val)
+	if err != nil {
+		return nil, fmt.Errorf("invalid operand of logical not: %v", err)
+	}
+	return !b, nil
+}
+
+// Bool 逻辑运算的参数必须是 bool 类型
+//
+//	note nil 被当作 false，和数字运算中 nil 被当作 0 保持一致
+func Bool(val interface{}) (bool, error) {
+	if val == nil {
+		return false, nil
+	}
+
+	if b, ok := val.(bool); ok {
+		return b, nil
+	}
+
+	return false, fmt.Errorf("无法将类型 %T 转换为 bool", val)
+}
+
+// IsNumber 是否是数字类型，note nil 不是数字
+func IsNumber(val interface{}) bool {
+	switch val.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, *big.Int, *big.Rat, Decimal:
+		return true
+	default:
+		return false
+	}
+}
+
+func isEqual(arg1, arg2 interface{}) bool {
+	if arg1 == nil || arg2 == nil {
+		return IsNil(arg1) && IsNil(arg2)
+	}
+
+	if IsNumber(arg1) && IsNumber(arg2) {
+		result, err := compareNumber(arg1, arg2)
+		return err == nil && result == 0
+	}
+
+	// note time.Time 使用 == 比较时会比较时区，同一时刻不同时区的时间应该相等
+	if result, ok := compareTemporal(arg1, arg2); ok {
+		return result == 0
+	}
+
+	if reflect.TypeOf(arg1).Comparable() && reflect.TypeOf(arg2).Comparable() {
+		return arg1 == arg2
+	}
+
+	return reflect.DeepEqual(arg1, arg2)
+}
+
+// IsNil 包括值为 nil 的指针、map、slice 等
+func IsNil(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Interface, reflect.Chan:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// compare 比较两个数字、两个字符串、两个时间或者两个时间间隔的大小，arg1 小于、等于、大于 arg2 时分别返回 -1、0、1
+func compare(op string, arg1, arg2 interface{}) (int, error) {
+	if IsNumber(arg1) && IsNumber(arg2) {
+		return compareNumber(arg1, arg2)
+	}
+
+	if result, ok := compareTemporal(arg1, arg2); ok {
+		return result, nil
+	}
+
+	if str1, ok := arg1.(string); ok {
+		if str2, ok := arg2.(string); ok {
+			switch {
+			case str1 < str2:
+				return -1, nil
+			case str1 > str2:
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("can not compare %T with %T by '%s'", arg1, arg2, op)
+}
+
+func compareNumber(arg1, arg2 interface{}) (int, error) {
+	if isDecimalOperand(arg1) || isDecimalOperand(arg2) {
+		d1, err := ToDecimal(arg1)
+		if err != nil {
+			return 0, err
+		}
+		d2, err := ToDecimal(arg2)
+		if err != nil {
+			return 0, err
+		}
+		return d1.Cmp(d2), nil
+	}
+
+	if b1, b2, ok := bigOperands(arg1, arg2); ok {
+		return b1.Cmp(b2), nil
+	}
+
+	i1, f1, isFloat1, err := Number(arg1)
+	if err != nil {
+		return 0, err
+	}
+
+	i2, f2, isFloat2, err := Number(arg2)
+	if err != nil {
+		return 0, err
+	}
+
+	if !isFloat1 && !isFloat2 {
+		switch {
+		case i1 < i2:
+			return -1, nil
+		case i1 > i2:
+			return 1, nil
+		default:
+			return 0, nil
+		}
+	}
+
+	if !isFloat1 {
+		f1 = float64(i1)
+	}
+	if !isFloat2 {
+		f2 = float64(i2)
+	}
+
+	switch {
+	case f1 < f2:
+		return -1, nil
+	case f1 > f2:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
@@ -0,0 +1,427 @@
+package function
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode Decimal 舍入的方式，命名和 java.math.RoundingMode 相同
+type RoundingMode int
+
+const (
+	// RoundHalfUp 四舍五入，.5 远离 0，比如 2.5 -> 3、-2.5 -> -3，默认的舍入方式
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven 银行家舍入，.5 舍入到偶数，比如 2.5 -> 2、3.5 -> 4
+	RoundHalfEven
+	// RoundHalfDown .5 向 0 舍入，比如 2.5 -> 2、-2.5 -> -2
+	RoundHalfDown
+	// RoundDown 向 0 截断
+	RoundDown
+	// RoundUp 远离 0
+	RoundUp
+	// RoundCeiling 向正无穷舍入
+	RoundCeiling
+	// RoundFloor 向负无穷舍入
+	RoundFloor
+)
+
+func (mode RoundingMode) String() string {
+	switch mode {
+	case RoundHalfUp:
+		return "half_up"
+	case RoundHalfEven:
+		return "half_even"
+	case RoundHalfDown:
+		return "half_down"
+	case RoundDown:
+		return "down"
+	case RoundUp:
+		return "up"
+	case RoundCeiling:
+		return "ceiling"
+	case RoundFloor:
+		return "floor"
+	default:
+		return fmt.Sprintf("RoundingMode(%d)", int(mode))
+	}
+}
+
+// DefaultDecimalScale 除法结果默认保留的小数位数
+const DefaultDecimalScale = 16
+
+// maxDecimalExponent 科学计数法指数的最大绝对值，避免 1e1000000000 这样的数字耗尽内存
+const maxDecimalExponent = 1 << 14
+
+// Decimal 十进制小数，值为 unscaled × 10^(-scale)，比如 12.50 的 unscaled 是 1250、scale 是 2
+//
+//	note Decimal 是不可变的，所有的运算都返回新的 Decimal，零值表示 0
+//		 scale 总是大于等于 0，+ - * % 的结果是准确的，只有除法需要指定结果的 scale 和舍入方式
+type Decimal struct {
+	unscaled *big.Int
+	scale    int32
+}
+
+// NewDecimal unscaled × 10^(-scale)，比如 NewDecimal(1250, 2) 是 12.50
+func NewDecimal(unscaled int64, scale int32) Decimal {
+	return normalizeDecimal(big.NewInt(unscaled), int(scale))
+}
+
+func DecimalFromBigInt(i *big.Int) Decimal {
+	if i == nil {
+		return Decimal{}
+	}
+	return Decimal{unscaled: new(big.Int).Set(i)}
+}
+
+// DecimalFromFloat64 使用能够还原 f 的最短十进制表示，比如 0.1 -> 0.1，而不是 0.1000000000000000055511151231257827
+func DecimalFromFloat64(f float64) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, fmt.Errorf("can not convert %v to decimal", f)
+	}
+	return ParseDecimal(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// DecimalFromRat 分数不一定能用有限小数表示，所以需要按照 scale 和 rounding 舍入，比如 1/3 -> 0.3333
+func DecimalFromRat(r *big.Rat, scale int32, rounding RoundingMode) Decimal {
+	if r == nil {
+		return Decimal{}
+	}
+	return quoRounded(r.Num(), r.Denom(), int(scale), rounding)
+}
+
+// ParseDecimal 支持 12.50、-0.5、.5、1.5e3 这样的格式
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exponent := s, 0
+	if index := strings.IndexAny(s, "eE"); index >= 0 {
+		e, err := strconv.Atoi(s[index+1:])
+		if err != nil || e > maxDecimalExponent || e < -maxDecimalExponent {
+			return Decimal{}, fmt.Errorf("invalid decimal '%s'", s)
+		}
+		mantissa, exponent = s[:index], e
+	}
+
+	digits, scale := mantissa, 0
+	if index := strings.IndexByte(mantissa, '.'); index >= 0 {
+		digits = mantissa[:index] + mantissa[index+1:]
+		scale = len(mantissa) - index - 1
+	}
+
+	unsigned := strings.TrimLeft(digits, "+-")
+	if unsigned == "" || len(digits)-len(unsigned) > 1 || strings.Trim(unsigned, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("invalid decimal '%s'", s)
+	}
+
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal '%s'", s)
+	}
+	return normalizeDecimal(unscaled, scale-exponent), nil
+}
+
+// normalizeDecimal 保证 scale 大于等于 0，比如 1e3 的 unscaled 是 1000、scale 是 0
+func normalizeDecimal(unscaled *big.Int, scale int) Decimal {
+	if scale < 0 {
+		unscaled = new(big.Int).Mul(unscaled, pow10(-scale))
+		scale = 0
+	}
+	return Decimal{unscaled: unscaled, scale: int32(scale)}
+}
+
+func (d Decimal) int() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+// Scale 小数位数，比如 12.50 的 scale 是 2
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	a, b, scale := alignDecimals(d, other)
+	return Decimal{unscaled: new(big.Int).Add(a, b), scale: scale}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	a, b, scale := alignDecimals(d, other)
+	return Decimal{unscaled: new(big.Int).Sub(a, b), scale: scale}
+}
+
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.int(), other.int()), scale: d.scale + other.scale}
+}
+
+// Quo 除法，结果保留 scale 位小数并去掉末尾的 0，比如 1 / 4 = 0.25、1 / 3 = 0.3333(scale 为 4)
+func (d Decimal) Quo(other Decimal, scale int32, rounding RoundingMode) (Decimal, error) {
+	if other.Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+
+	// d / other = (d.unscaled × 10^other.scale) / (other.unscaled × 10^d.scale)
+	numerator := new(big.Int).Mul(d.int(), pow10(int(other.scale)))
+	denominator := new(big.Int).Mul(other.int(), pow10(int(d.scale)))
+	return quoRounded(numerator, denominator, int(scale), rounding).stripZeros(), nil
+}
+
+// Rem 取余，结果符号和被除数相同，和整数的 % 保持一致，比如 -7.5 % 2 = -1.5
+func (d Decimal) Rem(other Decimal) (Decimal, error) {
+	if other.Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+
+	a, b, scale := alignDecimals(d, other)
+	return Decimal{unscaled: new(big.Int).Rem(a, b), scale: scale}, nil
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{unscaled: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{unscaled: new(big.Int).Abs(d.int()), scale: d.scale}
+}
+
+// Round 保留 scale 位小数，scale 大于等于当前的 scale 时保持不变
+func (d Decimal) Round(scale int32, rounding RoundingMode) Decimal {
+	if scale < 0 {
+		scale = 0
+	}
+	if d.scale <= scale {
+		return d
+	}
+	return quoRounded(d.int(), pow10(int(d.scale)), int(scale), rounding)
+}
+
+// Cmp 按照数值比较，1.0 和 1.00 相等
+func (d Decimal) Cmp(other Decimal) int {
+	a, b, _ := alignDecimals(d, other)
+	return a.Cmp(b)
+}
+
+// IsInteger 小数部分是否为 0，比如 2.00
+func (d Decimal) IsInteger() bool {
+	if d.scale == 0 {
+		return true
+	}
+	return new(big.Int).Rem(d.int(), pow10(int(d.scale))).Sign() == 0
+}
+
+// Int64 ok 为 false 表示有小数部分或者超出了 int64 的范围
+func (d Decimal) Int64() (i int64, ok bool) {
+	if !d.IsInteger() {
+		return 0, false
+	}
+
+	integer := new(big.Int).Quo(d.int(), pow10(int(d.scale)))
+	if !integer.IsInt64() {
+		return 0, false
+	}
+	return integer.Int64(), true
+}
+
+// Float64 最接近的 float64，可能丢失精度
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.int(), pow10(int(d.scale)))
+}
+
+// String 不使用科学计数法，保留末尾的 0，比如 12.50
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.int()).String()
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+
+	scale := int(d.scale)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// stripZeros 去掉小数部分末尾的 0，比如 0.2500 -> 0.25
+func (d Decimal) stripZeros() Decimal {
+	unscaled, scale := d.int(), d.scale
+	ten := big.NewInt(10)
+	for scale > 0 {
+		quotient, remainder := new(big.Int).QuoRem(unscaled, ten, new(big.Int))
+		if remainder.Sign() != 0 {
+			break
+		}
+		unscaled, scale = quotient, scale-1
+	}
+	return Decimal{unscaled: unscaled, scale: scale}
+}
+
+// alignDecimals 将两个 Decimal 转换为相同的 scale，返回对应的 unscaled
+func alignDecimals(d1, d2 Decimal) (*big.Int, *big.Int, int32) {
+	switch {
+	case d1.scale == d2.scale:
+		return d1.int(), d2.int(), d1.scale
+	case d1.scale < d2.scale:
+		return new(big.Int).Mul(d1.int(), pow10(int(d2.scale-d1.scale))), d2.int(), d2.scale
+	default:
+		return d1.int(), new(big.Int).Mul(d2.int(), pow10(int(d1.scale-d2.scale))), d1.scale
+	}
+}
+
+// quoRounded numerator / denominator 保留 scale 位小数
+func quoRounded(numerator, denominator *big.Int, scale int, rounding RoundingMode) Decimal {
+	numerator = new(big.Int).Mul(numerator, pow10(scale))
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+	if remainder.Sign() == 0 {
+		return Decimal{unscaled: quotient, scale: int32(scale)}
+	}
+
+	// note QuoRem 向 0 截断，需要舍入时 quotient 的绝对值加 1
+	negative := (remainder.Sign() < 0) != (denominator.Sign() < 0)
+	half := new(big.Int).Abs(remainder)
+	half.Lsh(half, 1)
+	half.Sub(half, new(big.Int).Abs(denominator))
+
+	var up bool
+	switch rounding {
+	case RoundDown:
+		up = false
+	case RoundUp:
+		up = true
+	case RoundCeiling:
+		up = !negative
+	case RoundFloor:
+		up = negative
+	case RoundHalfDown:
+		up = half.Sign() > 0
+	case RoundHalfEven:
+		up = half.Sign() > 0 || (half.Sign() == 0 && quotient.Bit(0) == 1)
+	default:
+		up = half.Sign() >= 0
+	}
+
+	if up {
+		if negative {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return Decimal{unscaled: quotient, scale: int32(scale)}
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// roundDecimal 和 Round 相同，digits 是负数时舍入到整数位，比如 round(1250, -2) = 1300
+func roundDecimal(d Decimal, digits int64, rounding RoundingMode) Decimal {
+	if digits >= 0 {
+		if digits > maxDecimalExponent {
+			return d
+		}
+		return d.Round(int32(digits), rounding)
+	}
+
+	if digits < -maxDecimalExponent {
+		digits = -maxDecimalExponent
+	}
+	shift := pow10(int(-digits))
+	rounded := quoRounded(d.int(), new(big.Int).Mul(shift, pow10(int(d.scale))), 0, rounding)
+	return Decimal{unscaled: rounded.unscaled.Mul(rounded.unscaled, shift)}
+}
+
+// DecimalContext decimal 模式下除法的精度和舍入方式，见 NewDecimalArithmetic
+type DecimalContext struct {
+	scale    int32
+	rounding RoundingMode
+}
+
+func NewDecimalContext(scale int32, rounding RoundingMode) *DecimalContext {
+	if scale < 0 {
+		scale = 0
+	}
+	return &DecimalContext{scale: scale, rounding: rounding}
+}
+
+var defaultDecimalContext = NewDecimalContext(DefaultDecimalScale, RoundHalfUp)
+
+func (c *DecimalContext) Scale() int32 {
+	if c == nil {
+		return DefaultDecimalScale
+	}
+	return c.scale
+}
+
+func (c *DecimalContext) Rounding() RoundingMode {
+	if c == nil {
+		return RoundHalfUp
+	}
+	return c.rounding
+}
+
+// ToDecimal 数字、*big.Int、*big.Rat 以及数字字符串转换为 Decimal，nil 被当作 0
+//
+//	*big.Rat 按照 DefaultDecimalScale 和 RoundHalfUp 舍入
+func ToDecimal(val interface{}) (Decimal, error) {
+	return defaultDecimalContext.toDecimal(val)
+}
+
+func (c *DecimalContext) toDecimal(val interface{}) (Decimal, error) {
+	switch v := val.(type) {
+	case nil:
+		return Decimal{}, nil
+	case Decimal:
+		return v, nil
+	case *big.Int:
+		return DecimalFromBigInt(v), nil
+	case *big.Rat:
+		return DecimalFromRat(v, c.Scale(), c.Rounding()), nil
+	case float32:
+		return DecimalFromFloat64(float64(v))
+	case float64:
+		return DecimalFromFloat64(v)
+	case string:
+		d, err := ParseDecimal(strings.TrimSpace(v))
+		if err != nil {
+			return Decimal{}, fmt.Errorf("无法将字符串 '%s' 转换为 decimal", v)
+		}
+		return d, nil
+	case uint, uint64:
+		u, _ := v.(uint64)
+		if i, ok := v.(uint); ok {
+			u = uint64(i)
+		}
+		return DecimalFromBigInt(new(big.Int).SetUint64(u)), nil
+	default:
+		i, err := Int64(val)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("无法将类型 %T 转换为 decimal", val)
+		}
+		return NewDecimal(i, 0), nil
+	}
+}
+
+// isDecimalOperand 是否需要按照 Decimal 计算，*big.Rat 只能按照 Decimal 计算
+func isDecimalOperand(val interface{}) bool {
+	switch val.(type) {
+	case Decimal, *big.Rat:
+		return true
+	default:
+		return false
+	}
+}
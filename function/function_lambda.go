@@ -0,0 +1,316 @@
+package function
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Lambda 表达式中的匿名函数计算之后的值，比如 x -> x.price > 10
+type Lambda struct {
+	params  []string
+	invoker Invoker
+}
+
+// NewLambda invoker 的参数个数和 params 相同
+func NewLambda(params []string, invoker Invoker) *Lambda {
+	return &Lambda{params: params, invoker: invoker}
+}
+
+// NumParams 参数个数
+func (lambda *Lambda) NumParams() int {
+	return len(lambda.params)
+}
+
+func (lambda *Lambda) String() string {
+	return fmt.Sprintf("lambda(%d)", len(lambda.params))
+}
+
+// Call 参数个数必须和 lambda 定义的相同
+func (lambda *Lambda) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != len(lambda.params) {
+		return nil, fmt.Errorf("lambda require %d arguments instead of %d", len(lambda.params), len(args))
+	}
+	return lambda.invoker(args)
+}
+
+// collectionFunctions 集合相关的内置函数，集合可以是 slice、array 或者整数区间，nil 被当作空集合
+//
+//	filter(items, x -> x.price > 10)、map(items, x -> x.price)、any、all、count、sum、sort_by(items, x -> x.price)、
+//	reduce(items, (acc, x) -> acc + x, init)
+var collectionFunctions = []Function{
+	newBuiltin("filter", 2, 2, builtinFilter),
+	newBuiltin("map", 2, 2, builtinMap),
+	newBuiltin("any", 2, 2, matchAny("any", true)),
+	newBuiltin("all", 2, 2, matchAny("all", false)),
+	newBuiltin("count", 1, 2, builtinCount),
+	newBuiltin("sum", 1, 2, defaultArithmetic.builtinSum),
+	newBuiltin("sort_by", 2, 2, builtinSortBy),
+	newBuiltin("reduce", 3, 3, builtinReduce),
+}
+
+// elementsArg 第 index(从0开始) 个参数必须是 slice、array 或者整数区间，结果是元素的拷贝
+func elementsArg(funcName string, args []interface{}, index int) ([]interface{}, error) {
+	if IsNil(args[index]) {
+		return []interface{}{}, nil
+	}
+
+	if r, ok := args[index].(*Range); ok {
+		elements, err := r.elements()
+		if err != nil {
+			return nil, ArgumentError(funcName, index+1, err)
+		}
+		return elements, nil
+	}
+
+	value := indirect(reflect.ValueOf(args[index]))
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return nil, ArgumentError(funcName, index+1, fmt.Errorf("can not use %v (%T) as array", args[index], args[index]))
+	}
+
+	elements := make([]interface{}, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		elements = append(elements, value.Index(i).Interface())
+	}
+	return elements, nil
+}
+
+// lambdaArg 第 index(从0开始) 个参数必须是参数个数为 numParams 的 lambda
+func lambdaArg(funcName string, args []interface{}, index int, numParams int) (*Lambda, error) {
+	lambda, ok := args[index].(*Lambda)
+	if !ok || lambda == nil {
+		return nil, ArgumentError(funcName, index+1, fmt.Errorf("can not use %v (%T) as lambda", args[index], args[index]))
+	}
+
+	if lambda.NumParams() != numParams {
+		return nil, ArgumentError(funcName, index+1,
+			fmt.Errorf("lambda should have %d parameters instead of %d", numParams, lambda.NumParams()))
+	}
+	return lambda, nil
+}
+
+// predicate 调用 lambda 并且要求结果是 bool，note 和逻辑运算相同，nil 被当作 false
+func predicate(funcName string, lambda *Lambda, element interface{}) (bool, error) {
+	result, err := lambda.Call(element)
+	if err != nil {
+		return false, err
+	}
+
+	b, err := Bool(result)
+	if err != nil {
+		return false, fmt.Errorf("lambda of '%s' should return bool: %v", funcName, err)
+	}
+	return b, nil
+}
+
+func builtinFilter(args []interface{}) (interface{}, error) {
+	elements, err := elementsArg("filter", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	lambda, err := lambdaArg("filter", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]interface{}, 0)
+	for _, element := range elements {
+		ok, err := predicate("filter", lambda, element)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = append(result, element)
+		}
+	}
+	return result, nil
+}
+
+func builtinMap(args []interface{}) (interface{}, error) {
+	elements, err := elementsArg("map", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	lambda, err := lambdaArg("map", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		mapped, err := lambda.Call(element)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mapped)
+	}
+	return result, nil
+}
+
+// matchAny expected 为 true 时是 any，遇到满足条件的元素返回 true；为 false 时是 all，遇到不满足条件的元素返回 false
+//
+//	note 和逻辑运算一样会短路，空集合的 any 是 false、all 是 true
+func matchAny(funcName string, expected bool) Invoker {
+	return func(args []interface{}) (interface{}, error) {
+		elements, err := elementsArg(funcName, args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		lambda, err := lambdaArg(funcName, args, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, element := range elements {
+			ok, err := predicate(funcName, lambda, element)
+			if err != nil {
+				return nil, err
+			}
+			if ok == expected {
+				return expected, nil
+			}
+		}
+		return !expected, nil
+	}
+}
+
+// builtinCount count(items) 是元素个数，count(items, x -> cond) 是满足条件的元素个数
+func builtinCount(args []interface{}) (interface{}, error) {
+	elements, err := elementsArg("count", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 1 {
+		return int64(len(elements)), nil
+	}
+
+	lambda, err := lambdaArg("count", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	count := int64(0)
+	for _, element := range elements {
+		ok, err := predicate("count", lambda, element)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// builtinSum sum(items) 或者 sum(items, x -> x.price)，按照 + 的规则累加，空集合的结果是 0
+//
+//	note 字符串不参与求和，避免 + 变成字符串拼接，整数溢出按照 a 的 OverflowMode 处理
+func (a *Arithmetic) builtinSum(args []interface{}) (interface{}, error) {
+	elements, err := elementsArg("sum", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 2 {
+		lambda, err := lambdaArg("sum", args, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		for i, element := range elements {
+			if elements[i], err = lambda.Call(element); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var total interface{} = int64(0)
+	for i, element := range elements {
+		if _, isStr := element.(string); isStr {
+			return nil, fmt.Errorf("'sum' can not add element %d of type string", i)
+		}
+
+		// note 第一个元素不做加法，这样 sum 也可以用于 time.Duration；数字统一为 int64 或者 float64
+		if i == 0 {
+			if total = element; element != nil && IsNumber(element) {
+				total, _ = Positive(element)
+			}
+			continue
+		}
+		if total, err = a.Add(total, element); err != nil {
+			return nil, fmt.Errorf("'sum' failed at element %d: %w", i, err)
+		}
+	}
+	return total, nil
+}
+
+// builtinSortBy 按照 lambda 的结果升序排列，结果是新的数组，相同的 key 保持原来的顺序
+//
+//	key 之间的比较规则和 < 相同，只能是数字、字符串、时间等可以比较的类型
+func builtinSortBy(args []interface{}) (interface{}, error) {
+	elements, err := elementsArg("sort_by", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	lambda, err := lambdaArg("sort_by", args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		key, err := lambda.Call(element)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	indexes := make([]int, len(elements))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	var compareErr error
+	sort.SliceStable(indexes, func(i, j int) bool {
+		result, err := compare("<", keys[indexes[i]], keys[indexes[j]])
+		if err != nil && compareErr == nil {
+			compareErr = fmt.Errorf("'sort_by' can not compare keys: %v", err)
+		}
+		return result < 0
+	})
+	if compareErr != nil {
+		return nil, compareErr
+	}
+
+	result := make([]interface{}, 0, len(elements))
+	for _, i := range indexes {
+		result = append(result, elements[i])
+	}
+	return result, nil
+}
+
+// builtinReduce reduce(items, (acc, x) -> acc + x, init)
+func builtinReduce(args []interface{}) (interface{}, error) {
+	elements, err := elementsArg("reduce", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	lambda, err := lambdaArg("reduce", args, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	acc := args[2]
+	for _, element := range elements {
+		if acc, err = lambda.Call(acc, element); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
@@ -0,0 +1,327 @@
+package function
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// mathFunctions 数学相关的内置函数
+//
+//	参数都是整数时结果仍然是 int64，有小数参与时结果是 float64，比如 max(1, 2) = 2、max(1, 2.0) = 2.0
+//	sqrt、log 的结果总是 float64
+var mathFunctions = []Function{
+	newBuiltin("abs", 1, 1, defaultArithmetic.builtinAbs),
+	newBuiltin("min", 1, -1, extremum("min", false)),
+	newBuiltin("max", 1, -1, extremum("max", true)),
+	newBuiltin("round", 1, 2, builtinRound),
+	newBuiltin("floor", 1, 1, roundFloat("floor", math.Floor, RoundFloor)),
+	newBuiltin("ceil", 1, 1, roundFloat("ceil", math.Ceil, RoundCeiling)),
+	newBuiltin("pow", 2, 2, defaultArithmetic.builtinPow),
+	newBuiltin("sqrt", 1, 1, builtinSqrt),
+	newBuiltin("log", 1, 2, builtinLog),
+	newBuiltin("clamp", 3, 3, builtinClamp),
+}
+
+// numberArg 第 index(从0开始) 个参数必须是数字，note 和数字运算不同，nil 不会被当作 0
+func numberArg(funcName string, args []interface{}, index int) (int64, float64, bool, error) {
+	if args[index] == nil || !IsNumber(args[index]) {
+		return 0, 0, false, ArgumentError(funcName, index+1,
+			fmt.Errorf("can not use %v (%T) as number", args[index], args[index]))
+	}
+
+	i, f, isFloat, err := Number(args[index])
+	if err != nil {
+		return 0, 0, false, ArgumentError(funcName, index+1, err)
+	}
+	return i, f, isFloat, nil
+}
+
+// floatArg 第 index(从0开始) 个参数必须是数字，结果统一为 float64
+func floatArg(funcName string, args []interface{}, index int) (float64, error) {
+	i, f, isFloat, err := numberArg(funcName, args, index)
+	if err != nil {
+		return 0, err
+	}
+
+	if isFloat {
+		return f, nil
+	}
+	return float64(i), nil
+}
+
+// builtinAbs abs(math.MinInt64) 的溢出和取负相同，按照 a 的 OverflowMode 处理
+func (a *Arithmetic) builtinAbs(args []interface{}) (interface{}, error) {
+	if d, ok := args[0].(Decimal); ok {
+		return d.Abs(), nil
+	}
+	if b, ok := args[0].(*big.Int); ok && b != nil && !b.IsInt64() {
+		return new(big.Int).Abs(b), nil
+	}
+
+	i, f, isFloat, err := numberArg("abs", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if isFloat {
+		return math.Abs(f), nil
+	}
+	if i == math.MinInt64 {
+		if a.Overflow() == OverflowError {
+			return nil, &arithmeticError{msg: fmt.Sprintf("abs(%d) overflows int64", i), kind: ErrIntegerOverflow}
+		}
+		return a.Negative(i)
+	}
+	if i < 0 {
+		return -i, nil
+	}
+	return i, nil
+}
+
+// extremum min 和 max，greater 为 true 时取最大值，有 Decimal 参数时见 decimalExtremum
+func extremum(name string, greater bool) Invoker {
+	return func(args []interface{}) (interface{}, error) {
+		for _, arg := range args {
+			if _, ok := arg.(Decimal); ok {
+				return decimalExtremum(name, greater, args)
+			}
+		}
+
+		var resultInt int64
+		var resultFloat float64
+		allInt := true
+
+		for index := range args {
+			i, f, isFloat, err := numberArg(name, args, index)
+			if err != nil {
+				return nil, err
+			}
+			if !isFloat {
+				f = float64(i)
+			}
+
+			// note 都是整数时不使用 float64 比较，避免大整数精度丢失
+			var cmp int
+			if allInt && !isFloat {
+				cmp = compareInt64(i, resultInt)
+			} else {
+				cmp = compareFloat64(f, resultFloat)
+			}
+
+			allInt = allInt && !isFloat
+			if index == 0 || (greater && cmp > 0) || (!greater && cmp < 0) {
+				resultInt, resultFloat = i, f
+			}
+		}
+
+		if allInt {
+			return resultInt, nil
+		}
+		return resultFloat, nil
+	}
+}
+
+// decimalExtremum 参数都转换为 Decimal 比较，结果是 Decimal，这样 decimal 模式下的金额不会变成 float64
+func decimalExtremum(name string, greater bool, args []interface{}) (interface{}, error) {
+	var result Decimal
+	for index, arg := range args {
+		if _, _, _, err := numberArg(name, args, index); err != nil {
+			return nil, err
+		}
+		d, err := ToDecimal(arg)
+		if err != nil {
+			return nil, ArgumentError(name, index+1, err)
+		}
+
+		cmp := d.Cmp(result)
+		if index == 0 || (greater && cmp > 0) || (!greater && cmp < 0) {
+			result = d
+		}
+	}
+	return result, nil
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareFloat64(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// builtinRound 四舍五入(远离 0)，round(x, digits) 保留 digits 位小数，整数保持不变
+//
+//	Decimal 按照 RoundHalfUp 舍入，结果是准确的，比如 round(2.675, 2) = 2.68
+func builtinRound(args []interface{}) (interface{}, error) {
+	i, f, isFloat, err := numberArg("round", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	digits := int64(0)
+	if len(args) == 2 {
+		if digits, err = intArg("round", args, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	if d, ok := args[0].(Decimal); ok {
+		return roundDecimal(d, digits, RoundHalfUp), nil
+	}
+
+	if !isFloat {
+		return i, nil
+	}
+	if digits == 0 {
+		return math.Round(f), nil
+	}
+
+	scale := math.Pow(10, float64(digits))
+	return math.Round(f*scale) / scale, nil
+}
+
+// roundFloat floor 和 ceil，整数保持不变，Decimal 按照 rounding 舍入到整数
+func roundFloat(name string, f func(float64) float64, rounding RoundingMode) Invoker {
+	return func(args []interface{}) (interface{}, error) {
+		i, x, isFloat, err := numberArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if d, ok := args[0].(Decimal); ok {
+			return d.Round(0, rounding), nil
+		}
+
+		if !isFloat {
+			return i, nil
+		}
+		return f(x), nil
+	}
+}
+
+// builtinPow 和 ** 相同，底数是整数并且指数是非负整数时结果是 int64，溢出时按照 a 的 OverflowMode 处理
+func (a *Arithmetic) builtinPow(args []interface{}) (interface{}, error) {
+	base, _, isFloat1, err := numberArg("pow", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent, _, isFloat2, err := numberArg("pow", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Overflow() == OverflowError && a.Decimal() == nil && !isFloat1 && !isFloat2 && exponent >= 0 {
+		if _, ok := powInt64(base, exponent); !ok {
+			return nil, &arithmeticError{msg: fmt.Sprintf("pow(%d, %d) overflows int64", base, exponent),
+				kind: ErrIntegerOverflow}
+		}
+	}
+	return a.Power(args[0], args[1])
+}
+
+// powInt64 快速幂，ok 为 false 表示溢出
+func powInt64(base, exponent int64) (result int64, ok bool) {
+	result = 1
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			if result, ok = mulInt64(result, base); !ok {
+				return 0, false
+			}
+		}
+		exponent >>= 1
+		if exponent > 0 {
+			if base, ok = mulInt64(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return c, true
+}
+
+func builtinSqrt(args []interface{}) (interface{}, error) {
+	x, err := floatArg("sqrt", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if x < 0 {
+		return nil, ArgumentError("sqrt", 1, fmt.Errorf("%v should not be negative", args[0]))
+	}
+	return math.Sqrt(x), nil
+}
+
+// builtinLog log(x) 是自然对数，log(x, base) 是以 base 为底的对数
+func builtinLog(args []interface{}) (interface{}, error) {
+	x, err := floatArg("log", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if x <= 0 {
+		return nil, ArgumentError("log", 1, fmt.Errorf("%v should be positive", args[0]))
+	}
+
+	if len(args) == 1 {
+		return math.Log(x), nil
+	}
+
+	base, err := floatArg("log", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if base <= 0 || base == 1 {
+		return nil, ArgumentError("log", 2, fmt.Errorf("invalid base %v", args[1]))
+	}
+	return math.Log(x) / math.Log(base), nil
+}
+
+// builtinClamp clamp(x, low, high) 将 x 限制在 [low, high] 之间
+func builtinClamp(args []interface{}) (interface{}, error) {
+	low, err := floatArg("clamp", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	high, err := floatArg("clamp", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if low > high {
+		return nil, fmt.Errorf("'clamp' require low <= high instead of %v > %v", args[1], args[2])
+	}
+
+	// note 结果的类型和 min、max 相同，比如 clamp(5, 0, 10) = 5、clamp(5, 0, 10.0) = 5.0，
+	//		 decimal 模式下是 Decimal，比如 clamp(0.15, 0.1, 0.2) = 0.15
+	result, err := extremum("clamp", true)([]interface{}{args[0], args[1]})
+	if err != nil {
+		return nil, err
+	}
+	return extremum("clamp", false)([]interface{}{result, args[2]})
+}
@@ -0,0 +1,89 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// MemberTag 结构体字段的 tag，用于指定字段在表达式中的名称，`expr:"-"` 表示该字段不可访问
+const MemberTag = "expr"
+
+// ErrMemberNotFound 结构体中不存在对应的字段，或者 map 中不存在对应的 key
+var ErrMemberNotFound = errors.New("member not found")
+
+// fieldIndexByType 缓存结构体字段名称和字段下标的对应关系，reflect.Type -> map[string][]int
+var fieldIndexByType sync.Map
+
+// Member 获取对象的成员，支持 key 为字符串的 map、结构体以及指向它们的指针和接口
+//
+//	结构体字段优先使用 expr tag 指定的名称，没有 tag 时使用字段名，未导出的字段不可访问
+func Member(object interface{}, name string) (interface{}, error) {
+	if IsNil(object) {
+		return nil, fmt.Errorf("can not access member '%s' of nil", name)
+	}
+
+	value := indirect(reflect.ValueOf(object))
+	switch value.Kind() {
+	case reflect.Map:
+		keyType := value.Type().Key()
+		if keyType.Kind() != reflect.String && keyType.Kind() != reflect.Interface {
+			return nil, fmt.Errorf("can not access member '%s' of %T, key type should be string", name, object)
+		}
+
+		element := value.MapIndex(reflect.ValueOf(name).Convert(keyType))
+		if !element.IsValid() {
+			return nil, fmt.Errorf("%w: '%s' in %T", ErrMemberNotFound, name, object)
+		}
+		return element.Interface(), nil
+
+	case reflect.Struct:
+		index, ok := structFields(value.Type())[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: '%s' in %T", ErrMemberNotFound, name, object)
+		}
+
+		field, err := value.FieldByIndexErr(index)
+		if err != nil {
+			// 内嵌的结构体指针为 nil
+			return nil, fmt.Errorf("can not access member '%s' of %T: %v", name, object, err)
+		}
+		return field.Interface(), nil
+
+	default:
+		return nil, fmt.Errorf("can not access member '%s' of %T", name, object)
+	}
+}
+
+// structFields 结构体中可访问的字段，包括内嵌结构体中的字段
+func structFields(structType reflect.Type) map[string][]int {
+	if cached, ok := fieldIndexByType.Load(structType); ok {
+		return cached.(map[string][]int)
+	}
+
+	indexByName := make(map[string][]int)
+	for _, field := range reflect.VisibleFields(structType) {
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup(MemberTag); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		// note tag 可能和其他字段重名，同名时层级浅的字段优先
+		if existing, exist := indexByName[name]; !exist || len(existing) > len(field.Index) {
+			indexByName[name] = field.Index
+		}
+	}
+
+	fieldIndexByType.Store(structType, indexByName)
+	return indexByName
+}
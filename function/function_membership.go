@@ -0,0 +1,126 @@
+package function
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Range 闭区间 low..high，low 和 high 必须可以比较，比如数字、字符串、时间
+type Range struct {
+	low  interface{}
+	high interface{}
+}
+
+// NewRange 1..10、date('2026-01-01')..date('2026-12-31')
+//
+//	note low 大于 high 时是空区间
+func NewRange(low, high interface{}) (*Range, error) {
+	if _, err := compare("..", low, high); err != nil {
+		return nil, err
+	}
+	return &Range{low: low, high: high}, nil
+}
+
+func (r *Range) Low() interface{} {
+	return r.low
+}
+
+func (r *Range) High() interface{} {
+	return r.high
+}
+
+func (r *Range) String() string {
+	return fmt.Sprintf("%v..%v", r.low, r.high)
+}
+
+// Contains low <= val <= high，不能和边界比较的值不在区间中
+func (r *Range) Contains(val interface{}) bool {
+	if result, err := compare("..", r.low, val); err != nil || result > 0 {
+		return false
+	}
+	if result, err := compare("..", val, r.high); err != nil || result > 0 {
+		return false
+	}
+	return true
+}
+
+// maxRangeElements 整数区间展开为数组时的最大长度，避免 map(1..1e18, ...) 这种表达式耗尽内存
+const maxRangeElements = 1 << 20
+
+// elements 整数区间展开为数组，比如 1..3 -> [1, 2, 3]
+func (r *Range) elements() ([]interface{}, error) {
+	low, err := toInteger(r.low)
+	if err != nil {
+		return nil, fmt.Errorf("can not iterate range %v: %v", r, err)
+	}
+	high, err := toInteger(r.high)
+	if err != nil {
+		return nil, fmt.Errorf("can not iterate range %v: %v", r, err)
+	}
+
+	if low > high {
+		return []interface{}{}, nil
+	}
+	if uint64(high-low) >= maxRangeElements {
+		return nil, fmt.Errorf("range %v has too many elements", r)
+	}
+
+	count := high - low + 1
+	elements := make([]interface{}, 0, count)
+	for i := int64(0); i < count; i++ {
+		elements = append(elements, low+i)
+	}
+	return elements, nil
+}
+
+// In item in collection
+//
+//	collection 是 slice 或者 array 时判断是否有相等的元素，相等的规则和 == 相同
+//	collection 是 map 时判断 key 是否存在，collection 是字符串时判断是否是子串，collection 是 Range 时判断是否在区间中
+//	note collection 为 nil 时结果是 false
+func In(item, collection interface{}) (interface{}, error) {
+	if IsNil(collection) {
+		return false, nil
+	}
+
+	switch c := collection.(type) {
+	case *Range:
+		return c.Contains(item), nil
+	case string:
+		str, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("can not check whether %v (%T) is in string", item, item)
+		}
+		return strings.Contains(c, str), nil
+	}
+
+	value := indirect(reflect.ValueOf(collection))
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if isEqual(value.Index(i).Interface(), item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case reflect.Map:
+		// note 类型不匹配的 key 一定不存在
+		key, err := toMapKey(item, value.Type().Key())
+		if err != nil {
+			return false, nil
+		}
+		return value.MapIndex(key).IsValid(), nil
+	default:
+		return nil, fmt.Errorf("invalid right operand of 'in': %T is not a collection", collection)
+	}
+}
+
+// NotIn item not in collection
+func NotIn(item, collection interface{}) (interface{}, error) {
+	result, err := In(item, collection)
+	if err != nil {
+		return nil, err
+	}
+	return !result.(bool), nil
+}
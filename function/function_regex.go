@@ -0,0 +1,128 @@
+package function
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// regexFunctions 正则相关的内置函数，pattern 是第 2 个参数
+//
+//	pattern 是字符串常量时在 ast.Optimize 阶段编译，见 PatternArgIndex
+var regexFunctions = []Function{
+	newBuiltin("regexMatch", 2, 2, builtinRegexMatch),
+	newBuiltin("regexFind", 2, 2, builtinRegexFind),
+	newBuiltin("regexReplace", 3, 3, builtinRegexReplace),
+}
+
+// PatternArgIndex 内置的正则函数中 pattern 参数的下标(从0开始)，用户注册的同名函数不是正则函数
+func PatternArgIndex(f Function) (int, bool) {
+	if !f.builtin {
+		return 0, false
+	}
+
+	switch f.name {
+	case "regexMatch", "regexFind", "regexReplace":
+		return 1, true
+	default:
+		return 0, false
+	}
+}
+
+// CompilePattern 语法和 regexp 包相同(RE2)
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(pattern)
+}
+
+// Matches str =~ pattern，部分匹配即可，需要完全匹配时使用 ^ 和 $
+//
+//	pattern 可以是字符串或者编译好的 *regexp.Regexp，note str 为 nil 时结果是 false
+func Matches(str, pattern interface{}) (interface{}, error) {
+	if str == nil {
+		return false, nil
+	}
+
+	s, ok := str.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid left operand of 'matches': can not use %v (%T) as string", str, str)
+	}
+
+	re, err := toRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid right operand of 'matches': %v", err)
+	}
+	return re.MatchString(s), nil
+}
+
+func toRegexp(pattern interface{}) (*regexp.Regexp, error) {
+	switch p := pattern.(type) {
+	case *regexp.Regexp:
+		if p == nil {
+			return nil, fmt.Errorf("pattern should not be nil")
+		}
+		return p, nil
+	case string:
+		return CompilePattern(p)
+	default:
+		return nil, fmt.Errorf("can not use %v (%T) as pattern", pattern, pattern)
+	}
+}
+
+// patternArg 第 index(从0开始) 个参数必须是字符串或者编译好的 *regexp.Regexp
+func patternArg(funcName string, args []interface{}, index int) (*regexp.Regexp, error) {
+	re, err := toRegexp(args[index])
+	if err != nil {
+		return nil, ArgumentError(funcName, index+1, err)
+	}
+	return re, nil
+}
+
+func builtinRegexMatch(args []interface{}) (interface{}, error) {
+	str, err := stringArg("regexMatch", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := patternArg("regexMatch", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString(str), nil
+}
+
+// builtinRegexFind 第一个匹配的子串，没有匹配时返回 nil
+func builtinRegexFind(args []interface{}) (interface{}, error) {
+	str, err := stringArg("regexFind", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := patternArg("regexFind", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := re.FindStringIndex(str)
+	if loc == nil {
+		return nil, nil
+	}
+	return str[loc[0]:loc[1]], nil
+}
+
+// builtinRegexReplace 替换所有匹配的子串，replacement 中可以使用 $1、${name} 引用分组
+func builtinRegexReplace(args []interface{}) (interface{}, error) {
+	str, err := stringArg("regexReplace", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := patternArg("regexReplace", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	replacement, err := stringArg("regexReplace", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return re.ReplaceAllString(str, replacement), nil
+}
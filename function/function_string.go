@@ -0,0 +1,298 @@
+package function
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringFunctions 字符串相关的内置函数
+//
+//	note 下标和长度都按照 rune 计算，比如 len('中文') = 2、indexOf('中文abc', 'a') = 2
+var stringFunctions = []Function{
+	newBuiltin("len", 1, 1, builtinLen),
+	newBuiltin("upper", 1, 1, mapString("upper", strings.ToUpper)),
+	newBuiltin("lower", 1, 1, mapString("lower", strings.ToLower)),
+	newBuiltin("trim", 1, 2, builtinTrim),
+	newBuiltin("contains", 2, 2, matchString("contains", strings.Contains)),
+	newBuiltin("startsWith", 2, 2, matchString("startsWith", strings.HasPrefix)),
+	newBuiltin("endsWith", 2, 2, matchString("endsWith", strings.HasSuffix)),
+	newBuiltin("replace", 3, 4, builtinReplace),
+	newBuiltin("split", 2, 2, builtinSplit),
+	newBuiltin("join", 2, 2, builtinJoin),
+	newBuiltin("substr", 2, 3, builtinSubstr),
+	newBuiltin("indexOf", 2, 2, builtinIndexOf),
+	newBuiltin("format", 1, -1, builtinFormat),
+}
+
+// builtinLen 字符串的字符数，或者 slice、array、map 的元素个数，nil 的长度是 0
+func builtinLen(args []interface{}) (interface{}, error) {
+	if IsNil(args[0]) {
+		return int64(0), nil
+	}
+
+	if str, ok := args[0].(string); ok {
+		return int64(utf8.RuneCountInString(str)), nil
+	}
+
+	value := indirect(reflect.ValueOf(args[0]))
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return int64(value.Len()), nil
+	default:
+		return nil, ArgumentError("len", 1, fmt.Errorf("can not get length of %v (%T)", args[0], args[0]))
+	}
+}
+
+func mapString(name string, f func(string) string) Invoker {
+	return func(args []interface{}) (interface{}, error) {
+		str, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return f(str), nil
+	}
+}
+
+func matchString(name string, f func(s, substr string) bool) Invoker {
+	return func(args []interface{}) (interface{}, error) {
+		str, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		substr, err := stringArg(name, args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return f(str, substr), nil
+	}
+}
+
+// builtinTrim trim(s) 去掉首尾的空白字符，trim(s, cutset) 去掉首尾在 cutset 中的字符
+func builtinTrim(args []interface{}) (interface{}, error) {
+	str, err := stringArg("trim", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 1 {
+		return strings.TrimSpace(str), nil
+	}
+
+	cutset, err := stringArg("trim", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Trim(str, cutset), nil
+}
+
+// builtinReplace replace(s, old, new) 替换所有的 old，replace(s, old, new, n) 最多替换 n 个
+func builtinReplace(args []interface{}) (interface{}, error) {
+	strs := make([]string, 3)
+	for i := range strs {
+		str, err := stringArg("replace", args, i)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = str
+	}
+
+	n := int64(-1)
+	if len(args) == 4 {
+		var err error
+		if n, err = intArg("replace", args, 3); err != nil {
+			return nil, err
+		}
+	}
+	return strings.Replace(strs[0], strs[1], strs[2], int(n)), nil
+}
+
+// builtinSplit 结果和数组字面量一样是 []interface{}，sep 为空字符串时按照字符拆分
+func builtinSplit(args []interface{}) (interface{}, error) {
+	str, err := stringArg("split", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sep, err := stringArg("split", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(str, sep)
+	result := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		result = append(result, part)
+	}
+	return result, nil
+}
+
+// builtinJoin join(items, sep)，元素可以是字符串或者数字，数字按照字符串拼接的规则转换
+func builtinJoin(args []interface{}) (interface{}, error) {
+	sep, err := stringArg("join", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	value := indirect(reflect.ValueOf(args[0]))
+	if IsNil(args[0]) || (value.Kind() != reflect.Slice && value.Kind() != reflect.Array) {
+		return nil, ArgumentError("join", 1, fmt.Errorf("can not use %v (%T) as array", args[0], args[0]))
+	}
+
+	parts := make([]string, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		part, err := concatOperand(value.Index(i).Interface())
+		if err != nil {
+			return nil, ArgumentError("join", 1, fmt.Errorf("element %d: %v", i, err))
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// builtinSubstr substr(s, start) 或者 substr(s, start, length)
+//
+//	start 为负数时从结尾开始计算，超出范围的部分会被截断，比如 substr('abc', -2) = 'bc'、substr('abc', 1, 10) = 'bc'
+func builtinSubstr(args []interface{}) (interface{}, error) {
+	str, err := stringArg("substr", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := intArg("substr", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	runes := []rune(str)
+	size := int64(len(runes))
+	if start < 0 {
+		start += size
+	}
+	start = clampInt64(start, 0, size)
+
+	end := size
+	if len(args) == 3 {
+		length, err := intArg("substr", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, ArgumentError("substr", 3, fmt.Errorf("length %d should not be negative", length))
+		}
+		if length < size-start {
+			end = start + length
+		}
+	}
+	return string(runes[start:end]), nil
+}
+
+// builtinIndexOf substr 第一次出现的字符下标，不存在时返回 -1
+func builtinIndexOf(args []interface{}) (interface{}, error) {
+	str, err := stringArg("indexOf", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	substr, err := stringArg("indexOf", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	i := strings.Index(str, substr)
+	if i < 0 {
+		return int64(-1), nil
+	}
+	return int64(utf8.RuneCountInString(str[:i])), nil
+}
+
+// builtinFormat 和 fmt.Sprintf 相同，note 表达式中的整数是 int64、小数是 float64，
+// decimal 模式下的数字是 Decimal，按照对应的动词转换，见 formatArg
+//
+//	参数的个数和 layout 中的动词不一致时返回错误，而不是 fmt 的 %!d(MISSING)、%!(EXTRA ...)，
+//	不支持 %[1]d 这种指定参数下标的动词
+func builtinFormat(args []interface{}) (interface{}, error) {
+	layout, err := stringArg("format", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	verbs, err := formatVerbs(layout)
+	if err != nil {
+		return nil, ArgumentError("format", 1, err)
+	}
+	if len(verbs) != len(args)-1 {
+		return nil, ArgumentError("format", 1,
+			fmt.Errorf("%q requires %d arguments instead of %d", layout, len(verbs), len(args)-1))
+	}
+
+	values := make([]interface{}, 0, len(args)-1)
+	for i, arg := range args[1:] {
+		values = append(values, formatArg(verbs[i], arg))
+	}
+	return fmt.Sprintf(layout, values...), nil
+}
+
+// formatVerbs layout 中依次使用参数的动词，%% 不使用参数，宽度或者精度是 * 时使用一个整数参数，对应的动词是 '*'
+func formatVerbs(layout string) ([]rune, error) {
+	var verbs []rune
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			continue
+		}
+
+		for i++; i < len(layout) && strings.IndexByte("+-# 0123456789.*", layout[i]) >= 0; i++ {
+			if layout[i] == '*' {
+				verbs = append(verbs, '*')
+			}
+		}
+		if i >= len(layout) {
+			return nil, fmt.Errorf("%q ends with an incomplete verb", layout)
+		}
+		if layout[i] == '%' {
+			continue
+		}
+		if layout[i] == '[' {
+			return nil, fmt.Errorf("%q uses explicit argument indexes, which are not supported", layout)
+		}
+
+		verb, size := utf8.DecodeRuneInString(layout[i:])
+		verbs = append(verbs, verb)
+		i += size - 1
+	}
+	return verbs, nil
+}
+
+// formatArg Decimal 不能直接使用 fmt 格式化，整数的动词转换为 int64，小数的动词转换为 float64，其他动词使用十进制字符串
+//
+//	note 有小数部分的 Decimal 使用整数的动词时转换为 float64，和非 decimal 模式下的结果相同，比如 %!d(float64=1.5)
+func formatArg(verb rune, arg interface{}) interface{} {
+	d, ok := arg.(Decimal)
+	if !ok {
+		return arg
+	}
+
+	switch verb {
+	case 'd', 'c', 'b', 'o', 'O', 'x', 'X', 'U', '*':
+		if i, ok := d.Int64(); ok {
+			return i
+		}
+		return d.Float64()
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		return d.Float64()
+	default:
+		return d.String()
+	}
+}
+
+func clampInt64(i, low, high int64) int64 {
+	if i < low {
+		return low
+	}
+	if i > high {
+		return high
+	}
+	return i
+}
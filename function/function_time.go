@@ -0,0 +1,269 @@
+package function
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+)
+
+var (
+	// timeFunctions 时间相关的内置函数，note now 不在其中，见 NowFunction
+	timeFunctions = []Function{
+		newBuiltin("date", 1, 1, builtinDate),
+		newBuiltin("parseTime", 2, 2, builtinParseTime),
+		newBuiltin("addDays", 2, 2, builtinAddDays),
+		newBuiltin("diffHours", 2, 2, builtinDiffHours),
+		newBuiltin("year", 1, 1, timeField("year", func(t time.Time) int { return t.Year() })),
+		newBuiltin("month", 1, 1, timeField("month", func(t time.Time) int { return int(t.Month()) })),
+		newBuiltin("day", 1, 1, timeField("day", func(t time.Time) int { return t.Day() })),
+		newBuiltin("weekday", 1, 1, timeField("weekday", func(t time.Time) int { return int(t.Weekday()) })),
+	}
+
+	// dateLayouts date 函数支持的格式，没有时区的时间按照 UTC 解析
+	dateLayouts = []string{
+		"2006-01-02",
+		"2006-01-02 15:04:05",
+		time.RFC3339,
+		time.RFC3339Nano,
+	}
+)
+
+// NowFunction 返回当前时间的 now 函数，clock 可以被替换以便于测试
+//
+//	note now 的结果每次调用都不同，所以不允许折叠
+func NowFunction(clock func() time.Time) Function {
+	invoker := func(args []interface{}) (interface{}, error) {
+		return clock(), nil
+	}
+	f := NewFunction("now", clock, 0, false, invoker)
+	f.builtin = true
+	return f
+}
+
+// builtinDate date('2026-01-02')，格式见 dateLayouts
+func builtinDate(args []interface{}) (interface{}, error) {
+	str, err := stringArg("date", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, str); err == nil {
+			return t, nil
+		}
+	}
+	return nil, ArgumentError("date", 1, fmt.Errorf("can not parse '%s' as date", str))
+}
+
+// builtinParseTime parseTime(s, layout)，layout 和 time.Parse 相同，比如 '2006-01-02 15:04'
+func builtinParseTime(args []interface{}) (interface{}, error) {
+	str, err := stringArg("parseTime", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	layout, err := stringArg("parseTime", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := time.Parse(layout, str)
+	if err != nil {
+		return nil, ArgumentError("parseTime", 1, err)
+	}
+	return t, nil
+}
+
+func builtinAddDays(args []interface{}) (interface{}, error) {
+	t, err := timeArg("addDays", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := intArg("addDays", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return t.AddDate(0, 0, int(days)), nil
+}
+
+// builtinDiffHours diffHours(t1, t2) = t1 - t2 的小时数，结果是 float64
+func builtinDiffHours(args []interface{}) (interface{}, error) {
+	t1, err := timeArg("diffHours", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	t2, err := timeArg("diffHours", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return t1.Sub(t2).Hours(), nil
+}
+
+// timeField year、month、day、weekday，结果是 int64，weekday 中 0 表示周日
+func timeField(name string, f func(t time.Time) int) Invoker {
+	return func(args []interface{}) (interface{}, error) {
+		t, err := timeArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return int64(f(t)), nil
+	}
+}
+
+// timeArg 第 index(从0开始) 个参数必须是 time.Time
+func timeArg(funcName string, args []interface{}, index int) (time.Time, error) {
+	if t, ok := args[index].(time.Time); ok {
+		return t, nil
+	}
+	return time.Time{}, ArgumentError(funcName, index+1, fmt.Errorf("can not use %v (%T) as time", args[index], args[index]))
+}
+
+func isTemporal(val interface{}) bool {
+	switch val.(type) {
+	case time.Time, time.Duration:
+		return true
+	default:
+		return false
+	}
+}
+
+// temporalArithmetic time.Time 和 time.Duration 参与的四则运算，handled 为 false 表示两个参数都不是时间类型
+//
+//	time + duration、duration + time、time - duration 结果是 time
+//	time - time、duration ± duration、duration * 数字、duration / 数字 结果是 duration
+//	duration / duration 结果是 float64
+//	note time.Duration 是 int64，除零和溢出的处理和整数相同，见 durationArithmetic
+func (a *Arithmetic) temporalArithmetic(op string, arg1, arg2 interface{}) (result interface{}, handled bool, err error) {
+	if !isTemporal(arg1) && !isTemporal(arg2) {
+		return nil, false, nil
+	}
+
+	t1, isTime1 := arg1.(time.Time)
+	t2, isTime2 := arg2.(time.Time)
+	d1, isDuration1 := arg1.(time.Duration)
+	d2, isDuration2 := arg2.(time.Duration)
+
+	switch {
+	case op == "+" && isTime1 && isDuration2:
+		return t1.Add(d2), true, nil
+	case op == "+" && isDuration1 && isTime2:
+		return t2.Add(d1), true, nil
+	case op == "+" && isDuration1 && isDuration2:
+		return a.durationArithmetic(addition, d1, int64(d2), d2)
+	case op == "-" && isTime1 && isTime2:
+		return t1.Sub(t2), true, nil
+	case op == "-" && isTime1 && isDuration2:
+		return t1.Add(-d2), true, nil
+	case op == "-" && isDuration1 && isDuration2:
+		return a.durationArithmetic(subtraction, d1, int64(d2), d2)
+	case op == "/" && isDuration1 && isDuration2:
+		if d2 == 0 {
+			return nil, true, divisionByZeroError(op, d1, d2)
+		}
+		return float64(d1) / float64(d2), true, nil
+	case (op == "*" || op == "/") && isDuration1 && arg2 != nil && IsNumber(arg2):
+		return a.scaleDuration(op, d1, arg2)
+	case op == "*" && arg1 != nil && IsNumber(arg1) && isDuration2:
+		return a.scaleDuration(op, d2, arg1)
+	}
+
+	return nil, true, fmt.Errorf("invalid operation: %s(%T) %s %s(%T)",
+		formatOperand(arg1), arg1, op, formatOperand(arg2), arg2)
+}
+
+func (a *Arithmetic) scaleDuration(op string, d time.Duration, factor interface{}) (interface{}, bool, error) {
+	i, f, isFloat, err := Number(factor)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if !isFloat {
+		if op == "*" {
+			return a.durationArithmetic(multiplication, d, i, factor)
+		}
+		return a.durationArithmetic(division, d, i, factor)
+	}
+
+	if op == "/" && f == 0 {
+		return nil, true, divisionByZeroError(op, d, factor)
+	}
+	scaled := float64(d) * f
+	if op == "/" {
+		scaled = float64(d) / f
+	}
+	if math.IsNaN(scaled) || math.IsInf(scaled, 0) {
+		return nil, true, fmt.Errorf("invalid operation: %v %s %v", d, op, factor)
+	}
+	// note float64 超出 int64 范围时转换的结果是不确定的，所以按照溢出处理
+	if scaled >= -(1<<63) && scaled < 1<<63 {
+		return time.Duration(scaled), true, nil
+	}
+	exact, _ := new(big.Float).SetFloat64(scaled).Int(nil)
+	result, err := a.durationOverflowed(exact, "%v %s %v", d, op, factor)
+	return result, true, err
+}
+
+// durationArithmetic time.Duration 和整数 i 的运算，operand 是 i 原来的值，用于错误信息
+func (a *Arithmetic) durationArithmetic(operation integerOperation, d time.Duration, i int64,
+	operand interface{}) (interface{}, bool, error) {
+	if operation.op == "/" && i == 0 {
+		return nil, true, divisionByZeroError(operation.op, d, operand)
+	}
+	if result, ok := operation.checked(int64(d), i); ok {
+		return time.Duration(result), true, nil
+	}
+
+	exact := operation.exact(big.NewInt(int64(d)), big.NewInt(i))
+	result, err := a.durationOverflowed(exact, "%v %s %v", d, operation.op, operand)
+	return result, true, err
+}
+
+// durationOverflowed 和 overflowed 相同，结果是 time.Duration
+//
+//	note time.Duration 不能提升为 *big.Int，所以 OverflowPromote 模式下也返回错误
+func (a *Arithmetic) durationOverflowed(exact *big.Int, format string, args ...interface{}) (interface{}, error) {
+	mode := a.Overflow()
+	if mode == OverflowPromote {
+		mode = OverflowError
+	}
+
+	result, err := NewArithmetic(mode).overflowed(exact, format, args...)
+	if err != nil {
+		return nil, err
+	}
+	return time.Duration(result.(int64)), nil
+}
+
+func formatOperand(val interface{}) string {
+	if str, err := concatOperand(val); err == nil {
+		return str
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+// compareTemporal 两个 time.Time 或者两个 time.Duration 之间比较
+func compareTemporal(arg1, arg2 interface{}) (result int, ok bool) {
+	if t1, isTime := arg1.(time.Time); isTime {
+		if t2, isTime := arg2.(time.Time); isTime {
+			switch {
+			case t1.Before(t2):
+				return -1, true
+			case t1.After(t2):
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+
+	if d1, isDuration := arg1.(time.Duration); isDuration {
+		if d2, isDuration := arg2.(time.Duration); isDuration {
+			return compareInt64(int64(d1), int64(d2)), true
+		}
+	}
+
+	return 0, false
+}
@@ -1,13 +1,14 @@
 package goscript
 
 import (
+	"goscript/config"
 	"goscript/vm"
 )
 
-func NewVm() *vm.VM {
-	return vm.NewVM()
+func NewVm(opts ...config.Option) *vm.VM {
+	return vm.NewVM(opts...)
 }
 
-func Eval(exp string, env map[string]interface{}) (*vm.Value, error) {
+func Eval(exp string, env interface{}) (*vm.Value, error) {
 	return vm.Eval(exp, env)
 }
@@ -1,9 +1,19 @@
 package goscript
 
 import (
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"goscript/ast"
+	"goscript/config"
+	"goscript/function"
 	"goscript/vm"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // todo 计算中报错，比如将 string 传入参数类型为 int 的udf
@@ -52,21 +62,24 @@ var resultByValidExp = map[string]interface{}{
 //- `t.Fail`：标记测试为失败，但继续执行测试。
 //- `t.FailNow`：标记测试为失败，并立即终止测试。
 
-var virtualMachine = vm.NewVM()
+var virtualMachine = newTestVM()
 
-func init() {
-	_ = virtualMachine.RegisterFunc0("oneNotFold", true, func() (interface{}, error) {
+// newTestVM 注册测试使用的函数
+func newTestVM(opts ...config.Option) *vm.VM {
+	machine := vm.NewVM(opts...)
+	_ = machine.RegisterFunc0("oneNotFold", true, func() (interface{}, error) {
 		return 1, nil
 	})
 
-	_ = virtualMachine.RegisterFunc0("one", true, func() (interface{}, error) {
+	_ = machine.RegisterFunc0("one", true, func() (interface{}, error) {
 		return 1, nil
 	})
 
-	_ = virtualMachine.RegisterFunc1("same", true, func(arg vm.Value) (interface{}, error) {
+	_ = machine.RegisterFunc1("same", true, func(arg vm.Value) (interface{}, error) {
 		intValue, _ := arg.AsInt()
 		return intValue, nil
 	})
+	return machine
 }
 
 func TestEval(t *testing.T) {
@@ -84,6 +97,998 @@ func TestEval(t *testing.T) {
 
 
 
+var resultByFloatExp = map[string]interface{}{
+	"price * 0.85":  85.0,
+	"1.5e3":         1500.0,
+	"1.5e3 + 1":     1501.0,
+	"7 / 2":         int64(3),
+	"7 / 2.0":       3.5,
+	"7.5 % 2":       1.5,
+	"-0.5 * a":      -1.0,
+	"-(1.5 + 1)":    -2.5,
+	"a * 1.5e-1":    0.3,
+	"discount * 10": 2.5,
+}
+
+func TestEvalFloat(t *testing.T) {
+	env := map[string]interface{}{"a": 2, "price": 100, "discount": float32(0.25)}
+	for exp, expected := range resultByFloatExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+}
+
+var resultByLogicalExp = map[string]interface{}{
+	"a == 2":                      true,
+	"a != 2":                      false,
+	"a == 2.0":                    true,
+	"a < b":                       true,
+	"a <= 2 && b >= 3":            true,
+	"a > b || c > b":              true,
+	"a > b or c < b":              false,
+	"!(a > b)":                    true,
+	"not (a > b)":                 true,
+	"!!(a < b)":                   true,
+	"a + 1 == b":                  true,
+	"a * 2 > b and b * 2 > c":     true,
+	"name == 'go'":                true,
+	"name < 'java'":               true,
+	"a == 2 == (b == 3)":          true,
+	"a > b && notExist() > 0":     false,
+	"a < b || notExist() > 0":     true,
+	"a > b and c > b or a < b":    true,
+	"price * 0.85 >= 85 && a > 1": true,
+}
+
+func TestEvalLogical(t *testing.T) {
+	env := map[string]interface{}{"a": 2, "b": 3, "c": 4, "name": "go", "price": 100}
+	for exp, expected := range resultByLogicalExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+}
+
+func TestEvalLogicalBadCase(t *testing.T) {
+	env := map[string]interface{}{"a": 2, "name": "go"}
+	for _, exp := range []string{"a && true1 > 0", "a > 1 && a", "name < 1", "!a"} {
+		_, err := virtualMachine.Eval(exp, env)
+		t.Logf("%v", err)
+		assert.NotNil(t, err, "exp: "+exp)
+	}
+}
+
+var resultByLiteralExp = map[string]interface{}{
+	"true":                   true,
+	"false":                  false,
+	"nil":                    nil,
+	"null":                   nil,
+	"!true":                  false,
+	"a == 2 && true":         true,
+	"false || a > 1":         true,
+	"missing == nil":         true,
+	"a == null":              false,
+	"true == (a == 2)":       true,
+	"false && notExist()":    false,
+	"nil == null and !false": true,
+}
+
+func TestEvalLiteral(t *testing.T) {
+	env := map[string]interface{}{"a": 2}
+	for exp, expected := range resultByLiteralExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+}
+
+var resultByStringExp = map[string]interface{}{
+	"'a' + b":                     "ab",
+	"'n=' + 1.5":                  "n=1.5",
+	"1 + 'a'":                     "1a",
+	"'total: ' + (1 + 2)":         "total: 3",
+	"'x' + missing":               "x",
+	"'a\\tb' == 'a' + `\t` + 'b'": true,
+	"`a\\tb`":                     `a\tb`,
+	"'\\u4e2d' + '文'":             "中文",
+	"'' + 1e21":                   "1000000000000000000000",
+}
+
+func TestEvalString(t *testing.T) {
+	env := map[string]interface{}{"b": "b"}
+	for exp, expected := range resultByStringExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+
+	_, err := virtualMachine.Eval("'a' + true", nil)
+	assert.NotNil(t, err)
+	_, err = virtualMachine.Eval("'a' - 1", nil)
+	assert.NotNil(t, err)
+}
+
+var resultByStringFuncExp = map[string]interface{}{
+	"len('中文abc')":                                      int64(5),
+	"len([1, 2, 3])":                                    int64(3),
+	"len(m)":                                            int64(2),
+	"len(missing)":                                      int64(0),
+	"upper('abc') + lower('ÄBC')":                       "ABCäbc",
+	"trim('  a b  ')":                                   "a b",
+	"trim('--a-b--', '-')":                              "a-b",
+	"contains(name, '世界')":                              true,
+	"startsWith(name, 'hello')":                         true,
+	"endsWith(name, 'hello')":                           false,
+	"replace('a-b-c', '-', '+')":                        "a+b+c",
+	"replace('a-b-c', '-', '+', 1)":                     "a+b-c",
+	"split('a,b,,c', ',')":                              []interface{}{"a", "b", "", "c"},
+	"split('中文', '')":                                   []interface{}{"中", "文"},
+	"join(split('a,b', ','), '|')":                      "a|b",
+	"join([1, 2.5, 'x'], ',')":                          "1,2.5,x",
+	"substr('中文abc', 1, 2)":                             "文a",
+	"substr('中文abc', -3)":                               "abc",
+	"substr('abc', 1, 10)":                              "bc",
+	"substr('abc', 5)":                                  "",
+	"indexOf(name, '世界')":                               int64(6),
+	"indexOf(name, 'x')":                                int64(-1),
+	"format('%s has %d items, %.1f%%', name, 3, 12.34)": "hello 世界 has 3 items, 12.3%",
+	"len(upper(name)) == len(name)":                     true,
+}
+
+func TestEvalStringFunc(t *testing.T) {
+	env := map[string]interface{}{
+		"name": "hello 世界",
+		"m":    map[string]int{"a": 1, "b": 2},
+	}
+	for exp, expected := range resultByStringFuncExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+}
+
+func TestEvalStringFuncBadCase(t *testing.T) {
+	errByExp := map[string]string{
+		"upper(1)":             "argument 1 of 'upper': can not use 1 (int64) as string",
+		"contains('a', nil)":   "argument 2 of 'contains': can not use <nil> (<nil>) as string",
+		"len(1)":               "argument 1 of 'len': can not get length of 1 (int64)",
+		"substr('abc', 1.5)":   "argument 2 of 'substr': 1.5(float64) is not an integer",
+		"substr('abc', 0, -1)": "argument 3 of 'substr': length -1 should not be negative",
+		"join('abc', ',')":     "argument 1 of 'join': can not use abc (string) as array",
+		"join([true], ',')":    "argument 1 of 'join': element 0: can not concat bool with string",
+		"trim('a', 'b', 'c')":  "'trim' require 1 to 2 arguments instead of 3",
+		"format()":             "'format' require at least 1 arguments instead of 0",
+		"startsWith('a')":      "the func of 'startsWith' require 2 argument instead of 1",
+	}
+	for exp, expected := range errByExp {
+		_, err := virtualMachine.Eval(exp, nil)
+		if assert.NotNil(t, err, exp) {
+			assert.Equal(t, expected, err.Error(), exp)
+		}
+	}
+
+	// format 的参数个数和动词不一致时返回错误
+	formatErrByExp := map[string]string{
+		"format('%d')":             `argument 1 of 'format': "%d" requires 1 arguments instead of 0`,
+		"format('%s', 1, 2)":       `argument 1 of 'format': "%s" requires 1 arguments instead of 2`,
+		"format('%*d', 1)":         `argument 1 of 'format': "%*d" requires 2 arguments instead of 1`,
+		"format('100%%', 1)":       `argument 1 of 'format': "100%%" requires 0 arguments instead of 1`,
+		"format('100%')":           `argument 1 of 'format': "100%" ends with an incomplete verb`,
+		"format('%[1]d %[1]d', 1)": `argument 1 of 'format': "%[1]d %[1]d" uses explicit argument indexes, which are not supported`,
+	}
+	for exp, expected := range formatErrByExp {
+		_, err := virtualMachine.Eval(exp, nil)
+		if assert.NotNil(t, err, exp) {
+			assert.Equal(t, expected, err.Error(), exp)
+		}
+	}
+
+	eval, err := virtualMachine.Eval("format('%5.1f%% of %-3s|%+d', 12.34, 'ab', 7)", nil)
+	if assert.Nil(t, err) {
+		assert.Equal(t, " 12.3% of ab |+7", eval.RawValue())
+	}
+}
+
+var resultByMathFuncExp = map[string]interface{}{
+	"abs(-3)":      int64(3),
+	"abs(-2.5)":    2.5,
+	"min(3, 1, 2)": int64(1),
+	"max(3, 1, 2)": int64(3),
+	"max(1, 2.5)":  2.5,
+	"min(1, 2.5)":  1.0,
+	"max(a)":       int64(7),
+	"max(9007199254740993, 9007199254740992)": int64(9007199254740993),
+	"round(2.5)":                3.0,
+	"round(-2.5)":               -3.0,
+	"round(3.14159, 2)":         3.14,
+	"round(7)":                  int64(7),
+	"floor(-1.5)":               -2.0,
+	"ceil(1.2)":                 2.0,
+	"floor(a)":                  int64(7),
+	"pow(2, 10)":                int64(1024),
+	"pow(2, -1)":                0.5,
+	"pow(4, 0.5)":               2.0,
+	"sqrt(16)":                  4.0,
+	"log(1)":                    0.0,
+	"log(8, 2)":                 3.0,
+	"clamp(a, 0, 5)":            int64(5),
+	"clamp(-1, 0, 5)":           int64(0),
+	"clamp(a, 0, 10)":           int64(7),
+	"clamp(2.5, 0, 1)":          1.0,
+	"max(price * 0.85, 10) > 0": true,
+}
+
+func TestEvalMathFunc(t *testing.T) {
+	env := map[string]interface{}{"a": 7, "price": 100}
+	for exp, expected := range resultByMathFuncExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+}
+
+func TestEvalMathFuncBadCase(t *testing.T) {
+	errByExp := map[string]string{
+		"abs('1')":                      "argument 1 of 'abs': can not use 1 (string) as number",
+		"max(1, nil)":                   "argument 2 of 'max': can not use <nil> (<nil>) as number",
+		"min()":                         "'min' require at least 1 arguments instead of 0",
+		"abs(-9223372036854775807 - 1)": "abs(-9223372036854775808) overflows int64",
+		"pow(10, 19)":                   "pow(10, 19) overflows int64",
+		"sqrt(-1)":                      "argument 1 of 'sqrt': -1 should not be negative",
+		"log(0)":                        "argument 1 of 'log': 0 should be positive",
+		"log(8, 1)":                     "argument 2 of 'log': invalid base 1",
+		"clamp(1, 5, 0)":                "'clamp' require low <= high instead of 5 > 0",
+		"round(1.5, 0.5)":               "argument 2 of 'round': 0.5(float64) is not an integer",
+	}
+	for exp, expected := range errByExp {
+		_, err := virtualMachine.Eval(exp, nil)
+		if assert.NotNil(t, err, exp) {
+			assert.Equal(t, expected, err.Error(), exp)
+		}
+	}
+
+	for _, f := range function.Builtins() {
+		assert.True(t, f.AllowFold() || f.Name() == "now", f.Name())
+	}
+}
+
+func TestEvalTime(t *testing.T) {
+	fixedNow := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	clockVM := vm.NewVM(config.WithClock(func() time.Time { return fixedNow }))
+
+	env := map[string]interface{}{
+		"created": time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+		"timeout": 90 * time.Second,
+	}
+	resultByExp := map[string]interface{}{
+		"now()":                               fixedNow,
+		"now() - created":                     27*time.Hour + 4*time.Minute + 5*time.Second,
+		"now() - created > 24h":               true,
+		"created + 2h":                        time.Date(2026, 1, 1, 14, 0, 0, 0, time.UTC),
+		"created - 1h30m":                     time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC),
+		"timeout * 2 == 3m":                   true,
+		"timeout / 2":                         45 * time.Second,
+		"-timeout + 2m":                       30 * time.Second,
+		"1h / 30m":                            2.0,
+		"date('2026-01-02')":                  time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		"date('2026-01-02 08:00:00') < now()": true,
+		"date('2026-01-02T16:04:05+01:00') == now()": true,
+		"parseTime('02/01/2026', '02/01/2006')":      time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		"addDays(created, 31)":                       time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC),
+		"diffHours(now(), created)":                  27.068055555555556,
+		"year(now()) * 100 + month(now())":           int64(202601),
+		"day(created)":                               int64(1),
+		"weekday(date('2026-01-04'))":                int64(0),
+		"'created at ' + created":                    "created at 2026-01-01T12:00:00Z",
+		"'timeout ' + timeout":                       "timeout 1m30s",
+		"max(diffHours(now(), created), 48) > 30":    true,
+	}
+	for exp, expected := range resultByExp {
+		eval, err := clockVM.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+
+	eval, err := clockVM.Eval("now() + 1h", nil)
+	assert.Nil(t, err)
+	tm, err := eval.AsTime()
+	assert.Nil(t, err)
+	assert.Equal(t, fixedNow.Add(time.Hour), tm)
+	_, err = eval.AsDuration()
+	assert.NotNil(t, err)
+
+	eval, err = clockVM.Eval("5m", nil)
+	assert.Nil(t, err)
+	d, err := eval.AsDuration()
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Minute, d)
+
+	for _, exp := range []string{
+		"created + created", "created * 2", "created > 1", "5m + 1", "5m / 0", "date('2026/01/02')",
+		"addDays(1, 1)", "year('2026')", "parseTime('x', '2006')",
+	} {
+		_, err := clockVM.Eval(exp, env)
+		assert.NotNil(t, err, exp)
+	}
+
+	for _, f := range function.Builtins() {
+		if f.Name() == "now" {
+			assert.False(t, f.AllowFold())
+		}
+	}
+}
+
+var resultByLambdaExp = map[string]interface{}{
+	"count(filter(items, x -> x.Price > 10))":             int64(2),
+	"map(items, x -> x.Name)":                             []interface{}{"apple", "pear", "melon"},
+	"map([1, 2, 3], x -> x * factor)":                     []interface{}{int64(3), int64(6), int64(9)},
+	"any(items, x -> x.Price > 30)":                       false,
+	"all(items, x -> x.Price > 1)":                        true,
+	"all([], x -> false)":                                 true,
+	"any(missing, x -> true)":                             false,
+	"count(items)":                                        int64(3),
+	"count(items, item -> startsWith(item.Name, 'p'))":    int64(1),
+	"sum(items, x -> x.Price)":                            48.5,
+	"sum([1, 2, 3])":                                      int64(6),
+	"sum([])":                                             int64(0),
+	"sum([1m, 30s])":                                      90 * time.Second,
+	"map(sort_by(items, x -> x.Price), x -> x.Name)":      []interface{}{"pear", "apple", "melon"},
+	"map(sort_by(items, x -> -len(x.Name)), x -> x.Name)": []interface{}{"apple", "melon", "pear"},
+	"reduce(items, (acc, x) -> acc + x.Name, '')":         "applepearmelon",
+	"reduce([1, 2, 3], (acc, x) -> acc * x, 1)":           int64(6),
+	"map(items, factor -> factor.Price)[0]":               12.5,
+	"filter([1, 2, 3], x -> x > factor)":                  []interface{}{},
+	"map(filter(items, x -> x.Price < 20), x -> x.Name)":  []interface{}{"apple", "pear"},
+	"any(items, x -> any(x.Tags, t -> t == 'fruit'))":     true,
+}
+
+type orderLine struct {
+	Name  string
+	Price float64
+	Tags  []string
+}
+
+func TestEvalLambda(t *testing.T) {
+	env := map[string]interface{}{
+		"factor": 3,
+		"items": []orderLine{
+			{Name: "apple", Price: 12.5, Tags: []string{"fruit"}},
+			{Name: "pear", Price: 6},
+			{Name: "melon", Price: 30},
+		},
+	}
+	for exp, expected := range resultByLambdaExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+
+	errByExp := map[string]string{
+		"filter(items, x -> x.Price)": "lambda of 'filter' should return bool: 无法将类型 float64 转换为 bool",
+		"filter(items, 1)":            "argument 2 of 'filter': can not use 1 (int64) as lambda",
+		"map(1, x -> x)":              "argument 1 of 'map': can not use 1 (int64) as array",
+		"reduce(items, x -> x, 0)":    "argument 2 of 'reduce': lambda should have 2 parameters instead of 1",
+		"sum(['a', 'b'])":             "'sum' can not add element 0 of type string",
+		"sort_by([1, 'a'], x -> x)":   "'sort_by' can not compare keys: can not compare string with int64 by '<'",
+	}
+	for exp, expected := range errByExp {
+		_, err := virtualMachine.Eval(exp, env)
+		if assert.NotNil(t, err, exp) {
+			assert.Equal(t, expected, err.Error(), exp)
+		}
+	}
+}
+
+var resultByMembershipExp = map[string]interface{}{
+	"country in ['US', 'CA']":     true,
+	"country not in ['US', 'CA']": false,
+	"country in countries":        true,
+	"'MX' in countries":           false,
+	"1 in [1.0, 2]":               true,
+	"'a' in m":                    true,
+	"'z' in m":                    false,
+	"1 in m":                      false,
+	"2 in scores":                 true,
+	"'ell' in 'hello'":            true,
+	"country in missing":          false,
+	"age between 18 and 60":       true,
+	"age between 18 and 60 and country == 'US'": true,
+	"age not in 18..20":                         true,
+	"age in 1..age":                             true,
+	"age in 31..1":                              false,
+	"'b' in 'a'..'c'":                           true,
+	"created in date('2026-01-01')..date('2026-12-31')": true,
+	"map(1..3, x -> x * x)":                             []interface{}{int64(1), int64(4), int64(9)},
+	"sum(1..100)":                                       int64(5050),
+	"[1, 2] in [[1, 2], [3]]":                           true,
+}
+
+func TestEvalMembership(t *testing.T) {
+	env := map[string]interface{}{
+		"country":   "US",
+		"countries": []string{"US", "CA"},
+		"m":         map[string]int{"a": 1},
+		"scores":    map[int]string{2: "b"},
+		"age":       30,
+		"created":   time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for exp, expected := range resultByMembershipExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+
+	for _, exp := range []string{"1 in 'abc'", "1 in 2", "1..'a'", "map(1..'c', x -> x)", "count(1..1e18)"} {
+		_, err := virtualMachine.Eval(exp, env)
+		assert.NotNil(t, err, exp)
+	}
+}
+
+var resultByRegexExp = map[string]interface{}{
+	"code =~ '^[A-Z]{2}\\\\d+$'":          true,
+	"code matches '^\\\\d'":               false,
+	"email =~ pattern":                    true,
+	"missing =~ 'a'":                      false,
+	"regexMatch(code, '\\\\d{3}')":        true,
+	"regexFind(code, '\\\\d+')":           "123",
+	"regexFind(code, 'x')":                nil,
+	"regexReplace(email, '@.*$', '@***')": "jane@***",
+	"regexReplace('2026-01-02', `(\\d+)-(\\d+)-(\\d+)`, '$3/$2/$1')": "02/01/2026",
+	"filter(['a1', 'b', 'c2'], x -> x =~ '\\\\d')":                   []interface{}{"a1", "c2"},
+}
+
+func TestEvalRegex(t *testing.T) {
+	env := map[string]interface{}{
+		"code":    "US123",
+		"email":   "jane@example.com",
+		"pattern": "^[a-z]+@",
+	}
+	for i := 0; i < 2; i++ {
+		for exp, expected := range resultByRegexExp {
+			eval, err := virtualMachine.Eval(exp, env)
+			if err != nil {
+				t.Fatalf("ext: '%s', err: %v", exp, err)
+			}
+			assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+		}
+	}
+
+	_, err := virtualMachine.Eval("code =~ '(' ", env)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "line:1, column:9")
+	}
+
+	for _, exp := range []string{"1 =~ 'a'", "code =~ 1", "code =~ bad", "regexMatch(code, bad)", "regexFind(1, 'a')"} {
+		_, err := virtualMachine.Eval(exp, map[string]interface{}{"code": "a", "bad": "("})
+		assert.NotNil(t, err, exp)
+	}
+}
+
+var resultByBitwiseExp = map[string]interface{}{
+	"6 & 3":                  int64(2),
+	"6 | 3":                  int64(7),
+	"6 ^ 3":                  int64(5),
+	"1 << 10":                int64(1024),
+	"-8 >> 1":                int64(-4),
+	"1 >> 100":               int64(0),
+	"flags & 4 == 4":         true,
+	"flags & 1 << 2":         int64(4),
+	"1 | 2 ^ 3 & 4":          int64(3),
+	"2 ** 10":                int64(1024),
+	"2 ** 3 ** 2":            int64(512),
+	"2 ** -1":                0.5,
+	"4 ** 0.5":               2.0,
+	"-2 ** 2":                int64(4),
+	"3 * 2 ** 2":             int64(12),
+	"(2 ** 62 - 1) * 2 + 1":  int64(9223372036854775807),
+	"price * (1 - 0.1) ** 2": 81.0,
+	"0 << 80":                int64(0),
+}
+
+func TestEvalBitwise(t *testing.T) {
+	env := map[string]interface{}{"flags": uint8(6), "price": 100}
+	for exp, expected := range resultByBitwiseExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		if f, ok := expected.(float64); ok {
+			assert.InDelta(t, f, eval.RawValue(), 1e-9, "exp: "+exp)
+			continue
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+
+	errByExp := map[string]string{
+		"1.5 & 1":      "invalid left operand of '&': 1.5(float64) is not an integer",
+		"1 | 'a'":      "invalid right operand of '|': 无法将类型 string 转换为 int64",
+		"1 << -1":      "invalid right operand of '<<': negative shift count -1",
+		"1 << 63":      "1 << 63 overflows int64",
+		"3 << 62":      "3 << 62 overflows int64",
+		"2 ** 63":      "2 ** 63 overflows int64",
+		"10 ** 3 ** 7": "10 ** 2187 overflows int64",
+	}
+	for exp, expected := range errByExp {
+		_, err := virtualMachine.Eval(exp, env)
+		if assert.NotNil(t, err, exp) {
+			assert.Equal(t, expected, err.Error(), exp)
+		}
+	}
+}
+
+func TestBuiltinFuncOption(t *testing.T) {
+	bareVM := vm.NewVM(config.WithoutBuiltins())
+	_, err := bareVM.Eval("upper('a')", nil)
+	assert.NotNil(t, err)
+
+	// 用户注册的同名函数覆盖内置函数
+	customVM := vm.NewVM()
+	assert.Nil(t, customVM.RegisterFunc("upper", true, func(s string) string { return s + "!" }))
+	eval, err := customVM.Eval("upper('a') + lower('B')", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "a!b", eval.RawValue())
+	assert.NotNil(t, customVM.RegisterFunc("upper", true, func(s string) string { return s }))
+}
+
+func TestValueAsString(t *testing.T) {
+	eval, err := virtualMachine.Eval("a", map[string]interface{}{"a": "abc"})
+	assert.Nil(t, err)
+	str, err := eval.AsString()
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", str)
+
+	eval, err = virtualMachine.Eval("0.1 + 0.2 > 0 ? 2.50 : 0", nil)
+	assert.Nil(t, err)
+	str, err = eval.AsString()
+	assert.Nil(t, err)
+	assert.Equal(t, "2.5", str)
+
+	eval, err = virtualMachine.Eval("true", nil)
+	assert.Nil(t, err)
+	str, err = eval.AsString()
+	assert.Nil(t, err)
+	assert.Equal(t, "true", str)
+}
+
+func TestValueAsBoolAndIsNil(t *testing.T) {
+	eval, err := virtualMachine.Eval("a > 1", map[string]interface{}{"a": 2})
+	assert.Nil(t, err)
+	b, err := eval.AsBool()
+	assert.Nil(t, err)
+	assert.True(t, b)
+	assert.False(t, eval.IsNil())
+
+	eval, err = virtualMachine.Eval("null", nil)
+	assert.Nil(t, err)
+	assert.True(t, eval.IsNil())
+	_, err = eval.AsBool()
+	assert.NotNil(t, err)
+
+	var nilPtr *int
+	eval, err = virtualMachine.Eval("a", map[string]interface{}{"a": nilPtr})
+	assert.Nil(t, err)
+	assert.True(t, eval.IsNil())
+}
+
+var resultByConditionalExp = map[string]interface{}{
+	"a > 10 ? 'big' : 'small'":          "small",
+	"a < 10 ? 'big' : 'small'":          "big",
+	"a > 10 ? 1 : a > 1 ? 2 : 3":        int64(2),
+	"a > 1 ? a > 10 ? 1 : 2 : 3":        int64(2),
+	"(a > 1 ? 10 : 20) + 1":             int64(11),
+	"a > 1 ? 1 : notExist()":            int64(1),
+	"a < 1 ? notExist() : 2":            int64(2),
+	"a > 1 && a < 3 ? a * 2 : a":        int64(4),
+	"false ? 1 : true ? 2 : notExist()": int64(2),
+	"missing ? 'present' : 'absent'":    "absent",
+}
+
+func TestEvalConditional(t *testing.T) {
+	env := map[string]interface{}{"a": 2}
+	for exp, expected := range resultByConditionalExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+
+	_, err := virtualMachine.Eval("a ? 1 : 2", env)
+	assert.NotNil(t, err)
+}
+
+var resultByArrayExp = map[string]interface{}{
+	"[1, 2, a]":           []interface{}{int64(1), int64(2), 2},
+	"[]":                  []interface{}{},
+	"[1, 2, a][2]":        2,
+	"[[1, 2], [3]][0][1]": int64(2),
+	"arr[0] + arr[2]":     int64(40),
+	"arr[a]":              30,
+	"arr[1:3]":            []int{20, 30},
+	"arr[:2]":             []int{10, 20},
+	"arr[2:]":             []int{30},
+	"arr[:]":              []int{10, 20, 30},
+	"fixed[1:]":           []string{"y"},
+	"fixed[0]":            "x",
+	"m['key']":            "value",
+	"m['missing']":        nil,
+	"scores[1]":           1.5,
+	"'héllo'[1]":          "é",
+	"'héllo'[1:3]":        "él",
+	"arr[a - 1] > arr[0]": true,
+	"ptr[0]":              10,
+	"[1, 2, 3][1:2][0]":   int64(2),
+	"[a > 1 ? 1 : 2][0]":  int64(1),
+}
+
+func TestEvalArray(t *testing.T) {
+	arr := []int{10, 20, 30}
+	env := map[string]interface{}{
+		"a":      2,
+		"arr":    arr,
+		"ptr":    &arr,
+		"fixed":  [2]string{"x", "y"},
+		"m":      map[string]string{"key": "value"},
+		"scores": map[int]float64{1: 1.5},
+	}
+	for exp, expected := range resultByArrayExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+}
+
+func TestEvalArrayBadCase(t *testing.T) {
+	env := map[string]interface{}{"arr": []int{10, 20, 30}, "m": map[string]int{}}
+	for _, exp := range []string{"arr[3]", "arr[-1]", "arr[1.5]", "arr[missing]", "arr[2:1]", "arr[1:4]",
+		"m[1]", "missing[0]", "1[0]", "arr[missing:]"} {
+		_, err := virtualMachine.Eval(exp, env)
+		t.Logf("%v", err)
+		assert.NotNil(t, err, "exp: "+exp)
+	}
+}
+
+type customer struct {
+	Level    int    `expr:"level"`
+	Name     string `expr:"name"`
+	Password string `expr:"-"`
+	internal string
+}
+
+type lineItem struct {
+	Price float64
+}
+
+type base struct {
+	ID int64 `expr:"id"`
+}
+
+type order struct {
+	*base
+	Customer *customer `expr:"customer"`
+	Items    []lineItem
+	Extra    interface{}
+}
+
+func TestEvalMember(t *testing.T) {
+	o := &order{
+		base:     &base{ID: 7},
+		Customer: &customer{Level: 3, Name: "Tom", Password: "secret", internal: "x"},
+		Items:    []lineItem{{Price: 12.5}, {Price: 5}},
+		Extra:    map[string]interface{}{"tags": map[string]interface{}{"vip": true}},
+	}
+	env := map[string]interface{}{
+		"order": o,
+		"nested": map[string]interface{}{
+			"customer": map[string]interface{}{"level": 2},
+		},
+	}
+
+	resultByExp := map[string]interface{}{
+		"order.customer.level":                         3,
+		"order.customer.name":                          "Tom",
+		"order.id":                                     int64(7),
+		"order.Items[0].Price":                         12.5,
+		"order.Items[1].Price * 2":                     10.0,
+		"order.Extra.tags.vip":                         true,
+		"nested.customer.level + 1":                    int64(3),
+		"nested['customer'].level":                     2,
+		"order.customer.level > nested.customer.level": true,
+	}
+	for exp, expected := range resultByExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+
+	errorByExp := map[string]string{
+		"order.customer.age":      "can not resolve 'age' of 'order.customer'",
+		"nested.customer.vip":     "can not resolve 'vip' of 'nested.customer'",
+		"order.customer.Password": "can not resolve 'Password' of 'order.customer'",
+		"order.customer.internal": "can not resolve 'internal' of 'order.customer'",
+		"order.customer.level.x":  "can not resolve 'x' of 'order.customer.level'",
+		"missing.x":               "can not resolve 'x' of 'missing'",
+	}
+	for exp, expected := range errorByExp {
+		_, err := virtualMachine.Eval(exp, env)
+		if assert.NotNil(t, err, "exp: "+exp) {
+			assert.Contains(t, err.Error(), expected, "exp: "+exp)
+		}
+	}
+}
+
+type request struct {
+	UserID  int64 `expr:"userId"`
+	Amount  float64
+	Order   *order
+	private int
+}
+
+func TestEvalWithEnv(t *testing.T) {
+	req := &request{UserID: 42, Amount: 99.5, Order: &order{Customer: &customer{Level: 3}}, private: 1}
+
+	eval, err := virtualMachine.Eval("userId == 42 && Amount > 50 && Order.customer.level == 3", req)
+	assert.Nil(t, err)
+	assert.Equal(t, true, eval.RawValue())
+
+	// 结构体值和 key 为字符串的任意 map
+	eval, err = virtualMachine.Eval("Amount * 2", *req)
+	assert.Nil(t, err)
+	assert.Equal(t, 199.0, eval.RawValue())
+
+	eval, err = virtualMachine.Eval("a + b", map[string]int{"a": 1, "b": 2})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), eval.RawValue())
+
+	// 不存在或者不可访问的字段和 map 一样为 nil
+	eval, err = virtualMachine.Eval("private == nil && missing == nil", req)
+	assert.Nil(t, err)
+	assert.Equal(t, true, eval.RawValue())
+
+	_, err = virtualMachine.Eval("a", 1)
+	assert.NotNil(t, err)
+}
+
+func TestEvalWithEnvFuncAndChain(t *testing.T) {
+	loaded := make([]string, 0)
+	lazy := vm.EnvFunc(func(name string) (interface{}, bool) {
+		loaded = append(loaded, name)
+		if name == "score" {
+			return 80, true
+		}
+		return nil, false
+	})
+
+	eval, err := virtualMachine.Eval("score >= 60 || other > 0", lazy)
+	assert.Nil(t, err)
+	assert.Equal(t, true, eval.RawValue())
+	assert.Equal(t, []string{"score"}, loaded)
+
+	structEnv, err := vm.NewStructEnv(&request{Amount: 10})
+	assert.Nil(t, err)
+	chain := vm.NewChainEnv(vm.MapEnv{"Amount": 1}, structEnv, lazy)
+
+	eval, err = virtualMachine.Eval("Amount + score", chain)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(81), eval.RawValue())
+
+	_, err = vm.NewStructEnv([]int{1})
+	assert.NotNil(t, err)
+}
+
+type user struct {
+	First string
+	Last  string
+	Age   int
+}
+
+func (u user) FullName() string {
+	return u.First + " " + u.Last
+}
+
+func (u *user) OlderThan(age int) bool {
+	return u.Age > age
+}
+
+func (u *user) Discount(rate float64) (float64, error) {
+	if rate < 0 {
+		return 0, errors.New("negative rate")
+	}
+	return float64(u.Age) * rate, nil
+}
+
+type items []lineItem
+
+func (i items) Len() int {
+	return len(i)
+}
+
+func (i items) PriceAbove(prices ...float64) int {
+	count := 0
+	for _, item := range i {
+		for _, price := range prices {
+			if item.Price > price {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestEvalMethodCall(t *testing.T) {
+	env := map[string]interface{}{
+		"user":  user{First: "Ada", Last: "Lovelace", Age: 36},
+		"ptr":   &user{First: "Alan", Last: "Turing", Age: 41},
+		"items": items{{Price: 12.5}, {Price: 5}},
+	}
+
+	resultByExp := map[string]interface{}{
+		"user.FullName()":                       "Ada Lovelace",
+		"ptr.FullName()":                        "Alan Turing",
+		"items.Len()":                           2,
+		"items.Len() > 1 && user.OlderThan(30)": true,
+		"ptr.OlderThan(50)":                     false,
+		"ptr.Discount(0.5)":                     20.5,
+		"items.PriceAbove(1, 10)":               3,
+		"items.PriceAbove()":                    0,
+	}
+	for exp, expected := range resultByExp {
+		eval, err := virtualMachine.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+
+	for _, exp := range []string{"ptr.Discount(-1)", "user.OlderThan('30')", "user.OlderThan(1.5)",
+		"user.Missing()", "missing.FullName()", "user.FullName(1)"} {
+		_, err := virtualMachine.Eval(exp, env)
+		t.Logf("%v", err)
+		assert.NotNil(t, err, "exp: "+exp)
+	}
+}
+
+func TestEvalMethodAllowList(t *testing.T) {
+	restricted := vm.NewVM()
+	restricted.AllowMethods("goscript.user.FullName", "Len")
+
+	env := map[string]interface{}{"u": &user{First: "Ada", Last: "Lovelace"}, "items": items{}}
+	eval, err := restricted.Eval("u.FullName()", env)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "Ada Lovelace", eval.RawValue())
+	}
+
+	eval, err = restricted.Eval("items.Len()", env)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 0, eval.RawValue())
+	}
+
+	_, err = restricted.Eval("u.OlderThan(1)", env)
+	assert.NotNil(t, err)
+
+	// 类型名必须带包路径，只有类型名时不允许调用，避免和其他包中的同名类型混淆
+	unqualified := vm.NewVM()
+	unqualified.AllowMethods("user.FullName")
+	_, err = unqualified.Eval("u.FullName()", env)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "method 'FullName' of *goscript.user is not allowed", err.Error())
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	reflectVM := vm.NewVM()
+	assert.Nil(t, reflectVM.RegisterFunc("repeat", true, func(n int64, s string) string {
+		return strings.Repeat(s, int(n))
+	}))
+	assert.Nil(t, reflectVM.RegisterFunc("isAdult", true, func(age int) bool { return age >= 18 }))
+	assert.Nil(t, reflectVM.RegisterFunc("sum", true, func(base float64, values ...float64) float64 {
+		for _, v := range values {
+			base += v
+		}
+		return base
+	}))
+	assert.Nil(t, reflectVM.RegisterFunc("safeDiv", true, func(a, b int64) (int64, error) {
+		if b == 0 {
+			return 0, errors.New("divide by zero")
+		}
+		return a / b, nil
+	}))
+	assert.Nil(t, reflectVM.RegisterFunc("join", true, func(sep string, parts []string) string {
+		return strings.Join(parts, sep)
+	}))
+	assert.Nil(t, reflectVM.RegisterFunc("describe", false, func(v vm.Value) string {
+		return fmt.Sprintf("%v", v.RawValue())
+	}))
+	assert.Nil(t, reflectVM.RegisterFunc("check", false, func(ok bool) error {
+		if !ok {
+			return errors.New("check failed")
+		}
+		return nil
+	}))
+
+	resultByExp := map[string]interface{}{
+		"repeat(3, 'ab')":            "ababab",
+		"isAdult(age)":               true,
+		"isAdult(10)":                false,
+		"sum(1)":                     1.0,
+		"sum(1, 2, 3.5)":             6.5,
+		"safeDiv(7, 2)":              int64(3),
+		"join('-', ['a', 'b', 'c'])": "a-b-c",
+		"describe(age)":              "20",
+		"check(true)":                nil,
+	}
+	env := map[string]interface{}{"age": 20}
+	for exp, expected := range resultByExp {
+		eval, err := reflectVM.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+
+	errorByExp := map[string]string{
+		"repeat('3', 'ab')": "argument 1 of 'repeat': can not use 3 (string) as int64",
+		"isAdult(1.5)":      "argument 1 of 'isAdult': can not use 1.5 (float64) as int",
+		"sum()":             "'sum' require at least 1 arguments instead of 0",
+		"safeDiv(1, 0)":     "divide by zero",
+		"join('-', [1])":    "argument 2 of 'join': element 0: can not use 1 (int64) as string",
+		"isAdult(1, 2)":     "require 1 argument instead of 2",
+		"check(false)":      "check failed",
+		"repeat(nil, 'ab')": "can not use nil as int64",
+	}
+	for exp, expected := range errorByExp {
+		_, err := reflectVM.Eval(exp, env)
+		if assert.NotNil(t, err, "exp: "+exp) {
+			assert.Contains(t, err.Error(), expected, "exp: "+exp)
+		}
+	}
+
+	assert.NotNil(t, reflectVM.RegisterFunc("notFunc", true, 1))
+	assert.NotNil(t, reflectVM.RegisterFunc("tooManyResults", true, func() (int, int, error) { return 0, 0, nil }))
+	assert.NotNil(t, reflectVM.RegisterFunc("badError", true, func() (int, int) { return 0, 0 }))
+	assert.NotNil(t, reflectVM.RegisterFunc("repeat", true, func() int { return 0 }))
+
+	reflectVM.RemoveFunc("repeat")
+	_, err := reflectVM.Eval("repeat(3, 'ab')", env)
+	assert.NotNil(t, err)
+	assert.Nil(t, reflectVM.RegisterFunc("repeat", true, func() int { return 0 }))
+}
+
+func TestRegisterFuncPanic(t *testing.T) {
+	panicVM := vm.NewVM()
+	assert.Nil(t, panicVM.RegisterFunc1("boom", false, func(arg vm.Value) (interface{}, error) {
+		panic("boom")
+	}))
+
+	_, err := panicVM.Eval("boom(1)", nil)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "call 'boom' panic: boom")
+	}
+}
+
 //func TestEvalBadCase(t *testing.T) {
 //	exp := "-1-1"
 //	expected := int64(-2)
@@ -96,3 +1101,987 @@ func TestEval(t *testing.T) {
 //	assert.Equal(t, expected, eval, "exp: "+exp)
 //}
 
+func TestEvalDivisionByZero(t *testing.T) {
+	env := map[string]interface{}{"a": 1, "zero": 0, "f": 1.5}
+	errByExp := map[string]string{
+		"a / zero":    "1 / 0: division by zero",
+		"a % zero":    "1 % 0: division by zero",
+		"f / zero":    "1.5 / 0: division by zero",
+		"f % 0.0":     "1.5 % 0: division by zero",
+		"a / nil":     "1 / 0: division by zero",
+		"1 + 10 / 0":  "10 / 0: division by zero",
+		"a / (1 - 1)": "1 / 0: division by zero",
+	}
+	for exp, expected := range errByExp {
+		_, err := virtualMachine.Eval(exp, env)
+		if !assert.NotNil(t, err, exp) {
+			continue
+		}
+		assert.Equal(t, expected, err.Error(), exp)
+
+		var evalErr *vm.EvalError
+		if assert.True(t, errors.As(err, &evalErr), exp) {
+			assert.Contains(t, []string{"/", "%"}, evalErr.Operator, exp)
+			assert.Len(t, evalErr.Operands, 2, exp)
+		}
+		assert.True(t, errors.Is(err, function.ErrDivisionByZero), exp)
+	}
+
+	// 0 的负数次方也是除零
+	powErrByExp := map[string]string{
+		"zero ** -1":     "0 ** -1: division by zero",
+		"0.0 ** -2":      "0 ** -2: division by zero",
+		"zero ** -0.5":   "0 ** -0.5: division by zero",
+		"pow(zero, -1)":  "0 ** -1: division by zero",
+		"pow(0.0, -0.5)": "0 ** -0.5: division by zero",
+	}
+	for exp, expected := range powErrByExp {
+		for _, machine := range []*vm.VM{virtualMachine, newTestVM(config.WithBackend(config.BytecodeBackend))} {
+			_, err := machine.Eval(exp, env)
+			if !assert.NotNil(t, err, exp) {
+				continue
+			}
+			assert.Equal(t, expected, err.Error(), exp)
+
+			var evalErr *vm.EvalError
+			if assert.True(t, errors.As(err, &evalErr), exp) {
+				assert.Contains(t, []string{"**", "pow"}, evalErr.Operator, exp)
+				assert.Len(t, evalErr.Operands, 2, exp)
+			}
+			assert.True(t, errors.Is(err, function.ErrDivisionByZero), exp)
+		}
+	}
+	eval, err := virtualMachine.Eval("0 ** 0 + 0.0 ** 2 + 2 ** -1", nil)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1.5, eval.RawValue())
+	}
+
+	// time.Duration 和整数相同
+	durationErrByExp := map[string]string{
+		"2m / 0":         "2m0s / 0: division by zero",
+		"2m / zero":      "2m0s / 0: division by zero",
+		"2m / 0.0":       "2m0s / 0: division by zero",
+		"2m / (1m - 1m)": "2m0s / 0s: division by zero",
+	}
+	for exp, expected := range durationErrByExp {
+		_, err := virtualMachine.Eval(exp, env)
+		if !assert.NotNil(t, err, exp) {
+			continue
+		}
+		assert.Equal(t, expected, err.Error(), exp)
+
+		var evalErr *vm.EvalError
+		assert.True(t, errors.As(err, &evalErr), exp)
+		assert.True(t, errors.Is(err, function.ErrDivisionByZero), exp)
+	}
+
+	// 其他错误不会被包装为 EvalError
+	_, err = virtualMachine.Eval("a / 'x'", env)
+	var evalErr *vm.EvalError
+	assert.False(t, errors.As(err, &evalErr))
+}
+
+func TestEvalOverflow(t *testing.T) {
+	promoted := func(s string) *big.Int {
+		b, _ := new(big.Int).SetString(s, 10)
+		return b
+	}
+
+	env := map[string]interface{}{"max": int64(math.MaxInt64), "min": int64(math.MinInt64)}
+	resultByModeAndExp := map[function.OverflowMode]map[string]interface{}{
+		function.OverflowWrap: {
+			"max + 1":     int64(math.MinInt64),
+			"min - 1":     int64(math.MaxInt64),
+			"max * 2":     int64(-2),
+			"-min":        int64(math.MinInt64),
+			"min / -1":    int64(math.MinInt64),
+			"min % -1":    int64(0),
+			"2 ** 64":     int64(0),
+			"3 ** 41":     int64(-420491770248316829),
+			"1 << 63":     int64(math.MinInt64),
+			"1 << 64":     int64(0),
+			"max + 1 < 0": true,
+		},
+		function.OverflowSaturate: {
+			"max + 1":         int64(math.MaxInt64),
+			"min - 1":         int64(math.MinInt64),
+			"min * 2":         int64(math.MinInt64),
+			"-min":            int64(math.MaxInt64),
+			"(-2) ** 63":      int64(math.MinInt64),
+			"(-2) ** 64":      int64(math.MaxInt64),
+			"-1 << 100":       int64(math.MinInt64),
+			"max + 1 - 1":     int64(math.MaxInt64 - 1),
+			"max + 1.5 > max": false,
+		},
+		function.OverflowPromote: {
+			"max + 1":            promoted("9223372036854775808"),
+			"-min":               promoted("9223372036854775808"),
+			"min / -1":           promoted("9223372036854775808"),
+			"2 ** 100":           promoted("1267650600228229401496703205376"),
+			"1 << 64":            promoted("18446744073709551616"),
+			"max + 1 - 1":        int64(math.MaxInt64),
+			"max * max / max":    int64(math.MaxInt64),
+			"2 ** 64 % 7":        int64(2),
+			"max + 1 > max":      true,
+			"max + 1 == 2 ** 63": true,
+			"(max + 1) * 0.5":    4611686018427387904.0,
+			"'n=' + (max + 1)":   "n=9223372036854775808",
+		},
+	}
+	for mode, resultByExp := range resultByModeAndExp {
+		machine := vm.NewVM(config.WithOverflowMode(mode))
+		for exp, expected := range resultByExp {
+			eval, err := machine.Eval(exp, env)
+			if !assert.Nil(t, err, mode.String()+": "+exp) {
+				continue
+			}
+			assert.Equal(t, expected, eval.RawValue(), mode.String()+": "+exp)
+		}
+	}
+
+	// 默认返回错误，常量表达式也一样
+	errByExp := map[string]string{
+		"max + 1":                 "9223372036854775807 + 1 overflows int64",
+		"min - 1":                 "-9223372036854775808 - 1 overflows int64",
+		"max * 2":                 "9223372036854775807 * 2 overflows int64",
+		"-min":                    "-(-9223372036854775808) overflows int64",
+		"min / -1":                "-9223372036854775808 / -1 overflows int64",
+		"9223372036854775807 + 1": "9223372036854775807 + 1 overflows int64",
+	}
+	for exp, expected := range errByExp {
+		_, err := virtualMachine.Eval(exp, env)
+		if !assert.NotNil(t, err, exp) {
+			continue
+		}
+		assert.Equal(t, expected, err.Error(), exp)
+		assert.True(t, errors.Is(err, function.ErrIntegerOverflow), exp)
+
+		var evalErr *vm.EvalError
+		assert.True(t, errors.As(err, &evalErr), exp)
+	}
+
+	machine := vm.NewVM(config.WithOverflowMode(function.OverflowPromote))
+	_, err := machine.Eval("2 ** 100000", nil)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "2 ** 100000 exceeds 65536 bits", err.Error())
+	}
+
+	// 内置函数 abs、sum 和 pow 的溢出处理和运算符相同
+	builtinByModeAndExp := map[function.OverflowMode]map[string]interface{}{
+		function.OverflowWrap: {
+			"abs(min)":                 int64(math.MinInt64),
+			"sum([max, 1])":            int64(math.MinInt64),
+			"pow(2, 64)":               int64(0),
+			"sum([max, 1]) == max + 1": true,
+		},
+		function.OverflowSaturate: {
+			"abs(min)":      int64(math.MaxInt64),
+			"sum([max, 1])": int64(math.MaxInt64),
+			"pow(-2, 63)":   int64(math.MinInt64),
+		},
+		function.OverflowPromote: {
+			"abs(min)":          promoted("9223372036854775808"),
+			"sum([max, 1])":     promoted("9223372036854775808"),
+			"pow(2, 100)":       promoted("1267650600228229401496703205376"),
+			"abs(-(2 ** 70))":   promoted("1180591620717411303424"),
+			"sum([max, 1, -1])": int64(math.MaxInt64),
+		},
+	}
+	for mode, resultByExp := range builtinByModeAndExp {
+		machine := vm.NewVM(config.WithOverflowMode(mode))
+		for exp, expected := range resultByExp {
+			eval, err := machine.Eval(exp, env)
+			if assert.Nil(t, err, mode.String()+": "+exp) {
+				assert.Equal(t, expected, eval.RawValue(), mode.String()+": "+exp)
+			}
+		}
+	}
+	builtinErrByExp := map[string]string{
+		"abs(min)":      "abs(-9223372036854775808) overflows int64",
+		"sum([max, 1])": "'sum' failed at element 1: 9223372036854775807 + 1 overflows int64",
+		"pow(2, 64)":    "pow(2, 64) overflows int64",
+	}
+	for exp, expected := range builtinErrByExp {
+		_, err := virtualMachine.Eval(exp, env)
+		if assert.NotNil(t, err, exp) {
+			assert.Equal(t, expected, err.Error(), exp)
+			assert.True(t, errors.Is(err, function.ErrIntegerOverflow), exp)
+		}
+	}
+
+	// 提升为 *big.Int 的下标超出 int64 范围时返回错误，错误信息中是准确的值
+	indexEnv := map[string]interface{}{
+		"arr":    []int{1, 2, 3},
+		"counts": map[int]string{1: "one"},
+		"prices": map[float64]string{1: "one"},
+	}
+	indexErrByExp := map[string]string{
+		"arr[2 ** 63]":          "invalid index: 9223372036854775808(*big.Int) is out of the int64 range",
+		"arr[1:2 ** 64]":        "invalid slice index: 18446744073709551616(*big.Int) is out of the int64 range",
+		"counts[2 ** 64]":       "invalid map key: 18446744073709551616(*big.Int) is out of the int64 range",
+		"'abc'[-(2 ** 63) - 1]": "invalid index: -9223372036854775809(*big.Int) is out of the int64 range",
+	}
+	for exp, expected := range indexErrByExp {
+		_, err := machine.Eval(exp, indexEnv)
+		if assert.NotNil(t, err, exp) {
+			assert.Equal(t, expected, err.Error(), exp)
+		}
+	}
+	eval, err := machine.Eval("prices[2 ** 64]", indexEnv)
+	if assert.Nil(t, err) {
+		assert.Nil(t, eval.RawValue())
+	}
+
+	// decimal 模式下的 Decimal 下标也一样
+	decimalMachine := vm.NewVM(config.WithDecimal(2, function.RoundHalfUp))
+	for exp, expected := range map[string]interface{}{
+		"counts[0.5 + 0.5]": "one",
+		"prices[0.5 + 0.5]": "one",
+		"arr[0.5 + 0.5]":    2,
+	} {
+		eval, err := decimalMachine.Eval(exp, indexEnv)
+		if assert.Nil(t, err, exp) {
+			assert.Equal(t, expected, eval.RawValue(), exp)
+		}
+	}
+	_, err = decimalMachine.Eval("counts[1.5]", indexEnv)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "invalid map key: 1.5(function.Decimal) is not an integer", err.Error())
+	}
+
+	// time.Duration 是 int64，溢出的处理和整数相同，note time.Duration 不能提升为 *big.Int，所以 OverflowPromote 模式下返回错误
+	huge := 2562047 * time.Hour
+	durationEnv := map[string]interface{}{"huge": huge, "min": time.Duration(math.MinInt64)}
+	durationErrByExp := map[string]string{
+		"2562047h * 2":        "2562047h0m0s * 2 overflows int64",
+		"2 * huge":            "2562047h0m0s * 2 overflows int64",
+		"2562047h + 2562047h": "2562047h0m0s + 2562047h0m0s overflows int64",
+		"-huge - huge":        "-2562047h0m0s - 2562047h0m0s overflows int64",
+		"huge * 1.5":          "2562047h0m0s * 1.5 overflows int64",
+		"-min":                "-(-2562047h47m16.854775808s) overflows int64",
+		"min / -1":            "-2562047h47m16.854775808s / -1 overflows int64",
+	}
+	for _, mode := range []function.OverflowMode{function.OverflowError, function.OverflowPromote} {
+		machine := vm.NewVM(config.WithOverflowMode(mode))
+		for exp, expected := range durationErrByExp {
+			_, err := machine.Eval(exp, durationEnv)
+			if !assert.NotNil(t, err, mode.String()+": "+exp) {
+				continue
+			}
+			assert.Equal(t, expected, err.Error(), mode.String()+": "+exp)
+			assert.True(t, errors.Is(err, function.ErrIntegerOverflow), mode.String()+": "+exp)
+
+			var evalErr *vm.EvalError
+			assert.True(t, errors.As(err, &evalErr), mode.String()+": "+exp)
+		}
+	}
+
+	durationByModeAndExp := map[function.OverflowMode]map[string]time.Duration{
+		function.OverflowWrap: {
+			"huge * 2":    huge * 2,
+			"huge + huge": huge + huge,
+			"-min":        math.MinInt64,
+		},
+		function.OverflowSaturate: {
+			"huge * 2":     math.MaxInt64,
+			"-huge - huge": math.MinInt64,
+			"huge * 1.5":   math.MaxInt64,
+			"-min":         math.MaxInt64,
+		},
+	}
+	for mode, durationByExp := range durationByModeAndExp {
+		machine := vm.NewVM(config.WithOverflowMode(mode))
+		for exp, expected := range durationByExp {
+			eval, err := machine.Eval(exp, durationEnv)
+			if assert.Nil(t, err, mode.String()+": "+exp) {
+				assert.Equal(t, expected, eval.RawValue(), mode.String()+": "+exp)
+			}
+		}
+	}
+}
+
+func TestEvalDecimal(t *testing.T) {
+	third := big.NewRat(1, 3)
+	huge, _ := new(big.Int).SetString("1000000000000000000000000000000", 10)
+	env := map[string]interface{}{
+		"price":  "19.99",
+		"qty":    3,
+		"amount": 0.3,
+		"rate":   1.5,
+		"third":  third,
+		"huge":   huge,
+		"items":  []int{10, 20, 30},
+	}
+
+	machine := vm.NewVM(config.WithDecimal(4, function.RoundHalfUp))
+	resultByExp := map[string]string{
+		"0.1 + 0.2":                "0.3",
+		"12.50 + 0":                "12.50",
+		"1.10 * 3":                 "3.30",
+		"1 / 3":                    "0.3333",
+		"2 / 3":                    "0.6667",
+		"1 / 4":                    "0.25",
+		"10 / 2":                   "5",
+		"-7.5 % 2":                 "-1.5",
+		"1.1 ** 2":                 "1.21",
+		"2 ** -2":                  "0.25",
+		"-0.5 * 3":                 "-1.5",
+		"price * qty":              "59.97",
+		"'0.10' + 0.2":             "0.30",
+		"amount * 3":               "0.9",
+		"rate + qty":               "4.5",
+		"third * 3":                "0.9999",
+		"huge + 1":                 "1000000000000000000000000000001",
+		"huge * huge / huge":       "1000000000000000000000000000000",
+		"round(2.675, 2)":          "2.68",
+		"round(1250, -2)":          "1300",
+		"floor(-1.5)":              "-2",
+		"ceil(-1.5)":               "-1",
+		"abs(-1.25)":               "1.25",
+		"max(0.1, 0.2)":            "0.2",
+		"min(0.30, 0.3, 1)":        "0.30",
+		"max(qty * 6.5, 19.99)":    "19.99",
+		"min(amount, third)":       "0.3",
+		"clamp(0.15, 0.1, 0.2)":    "0.15",
+		"clamp(5, 0.1, 0.25)":      "0.25",
+		"clamp(-1, 0.10, 2)":       "0.10",
+		"items[1] * 0.1":           "2.0",
+		"sum(items, x -> x * 0.1)": "6.0",
+	}
+	for exp, expected := range resultByExp {
+		eval, err := machine.Eval(exp, env)
+		if !assert.Nil(t, err, exp) {
+			continue
+		}
+		decimal, err := eval.AsDecimalString()
+		assert.Nil(t, err, exp)
+		assert.Equal(t, expected, decimal, exp)
+	}
+
+	logicalByExp := map[string]bool{
+		"0.1 + 0.2 == 0.3":       true,
+		"1.0 == 1":               true,
+		"1.10 == 1.1":            true,
+		"price > 19.98":          true,
+		"price == 19.99":         true,
+		"amount == 0.3":          true,
+		"third > 0.3333":         true,
+		"huge > 999999999999999": true,
+		"qty in [1, 2, 3]":       true,
+		"qty between 1 and 3":    true,
+		"2.5 in 1..3":            true,
+		"'a' + 1.50 == 'a1.50'":  true,
+	}
+	for exp, expected := range logicalByExp {
+		eval, err := machine.Eval(exp, env)
+		if !assert.Nil(t, err, exp) {
+			continue
+		}
+		assert.Equal(t, expected, eval.RawValue(), exp)
+	}
+
+	eval, err := machine.Eval("0.1 + 0.2", nil)
+	assert.Nil(t, err)
+	_, ok := eval.RawValue().(function.Decimal)
+	assert.True(t, ok)
+
+	_, err = machine.Eval("1 / 0.00", nil)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "1 / 0.00: division by zero", err.Error())
+		var evalErr *vm.EvalError
+		assert.True(t, errors.As(err, &evalErr))
+	}
+
+	_, err = machine.Eval("price * true", env)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "invalid right operand of '*': 无法将类型 bool 转换为 decimal", err.Error())
+	}
+
+	formatByExp := map[string]string{
+		"format('%d', 3)":                 "3",
+		"format('%.2f', 0.1)":             "0.10",
+		"format('%v + %s', 0.1, 2.50)":    "0.1 + 2.50",
+		"format('%x %5.1f%%', 255, rate)": "ff   1.5%",
+		"format('%*d|', 4, 7)":            "   7|",
+		"format('%08.3f', price * qty)":   "0059.970",
+		"format('%d', 1.5)":               "%!d(float64=1.5)",
+	}
+	for exp, expected := range formatByExp {
+		eval, err := machine.Eval(exp, env)
+		if assert.Nil(t, err, exp) {
+			assert.Equal(t, expected, eval.RawValue(), exp)
+		}
+	}
+
+	// 用户函数的参数是 function.Decimal，整数可以使用 AsInt64 转换
+	assert.Nil(t, machine.RegisterFunc1("twice", false, func(arg vm.Value) (interface{}, error) {
+		i, err := arg.AsInt64()
+		if err != nil {
+			return nil, err
+		}
+		return i * 2, nil
+	}))
+	for exp, expected := range map[string]string{"twice(3)": "6", "twice(qty) + 0.5": "6.5", "twice(2.00)": "4"} {
+		eval, err = machine.Eval(exp, env)
+		if assert.Nil(t, err, exp) {
+			decimal, err := eval.AsDecimalString()
+			assert.Nil(t, err, exp)
+			assert.Equal(t, expected, decimal, exp)
+		}
+	}
+	_, err = machine.Eval("twice(1.5)", env)
+	assert.NotNil(t, err)
+
+	eval, err = newTestVM(config.WithDecimal(4, function.RoundHalfUp)).Eval("same(3) + one()", nil)
+	if assert.Nil(t, err) {
+		result, err := eval.AsInt()
+		assert.Nil(t, err)
+		assert.Equal(t, 4, result)
+	}
+
+	// 非 decimal 模式下也可以将结果格式化为十进制小数
+	eval, err = virtualMachine.Eval("0.1 + 0.2", nil)
+	assert.Nil(t, err)
+	decimal, err := eval.AsDecimalString()
+	assert.Nil(t, err)
+	assert.Equal(t, "0.30000000000000004", decimal)
+}
+
+func TestEvalDecimalRounding(t *testing.T) {
+	resultByRounding := map[function.RoundingMode][2]string{
+		function.RoundHalfUp:   {"0.13", "-0.13"},
+		function.RoundHalfEven: {"0.12", "-0.12"},
+		function.RoundHalfDown: {"0.12", "-0.12"},
+		function.RoundDown:     {"0.12", "-0.12"},
+		function.RoundUp:       {"0.13", "-0.13"},
+		function.RoundCeiling:  {"0.13", "-0.12"},
+		function.RoundFloor:    {"0.12", "-0.13"},
+	}
+	for rounding, expected := range resultByRounding {
+		machine := vm.NewVM(config.WithDecimal(2, rounding))
+		for i, exp := range []string{"1 / 8", "-1 / 8"} {
+			eval, err := machine.Eval(exp, nil)
+			if !assert.Nil(t, err, rounding.String()) {
+				continue
+			}
+			decimal, err := eval.AsDecimalString()
+			assert.Nil(t, err)
+			assert.Equal(t, expected[i], decimal, rounding.String()+": "+exp)
+		}
+	}
+}
+
+// 常量折叠前后的计算结果必须一致，包括报错信息
+func TestEvalOptimizeEquivalence(t *testing.T) {
+	unoptimizedVM := vm.NewVM(config.WithOptimizeConfig(ast.OptimizeConfig{
+		WithoutConstantFold:   true,
+		WithoutFuncFold:       true,
+		WithoutSubNodeRemoval: true,
+		WithoutMembershipFold: true,
+		WithoutAlgebraicSimplify: true,
+		WithoutCommonSubexpression: true,
+	}))
+	env := map[string]interface{}{"a": 2, "b": 3, "name": "go", "items": []interface{}{1, 2, 3},
+		"f": 1.5, "negativeZero": math.Copysign(0, -1), "d": 5 * time.Second, "big": new(big.Int).Lsh(big.NewInt(1), 70)}
+	expressions := []string{
+		"1 + 2 * 3 - a",
+		"a + 1 + 2",
+		"1 + 2 + a",
+		"(1 + 2) * (a + b)",
+		"-(1 + 2) * a",
+		"2 ** 3 ** 2",
+		"2 ** 3 ** a",
+		"1 << 2 | a & 7 ^ 1",
+		"1.5 * 2 + a",
+		"'go' + 1 + 2",
+		"1 + 2 + 'go'",
+		"name + 'lang' + 1",
+		"true || a > 1",
+		"false && a > 1",
+		"1 > 2 || a > 1",
+		"a > 1 || 1 / 0 > 1",
+		"1 > 2 ? a : b",
+		"1 < 2 ? 'x' + 'y' : name",
+		"a > 1 ? 1 + 2 : 3 * 4",
+		"!true || !(a > b)",
+		"not (1 == 1)",
+		"len('abc') + a",
+		"upper('go') + name",
+		"abs(-3) * round(2.5)",
+		"a in [1, 2, 3]",
+		"2 in [1, 2, 3]",
+		"a not in 1..3",
+		"5 between 1 and 3",
+		"'abc' =~ '^a'",
+		"name =~ '^g'",
+		"map([1, 2, 3], x -> x * a)",
+		"count(filter(items, x -> x > 1 + 0))",
+		"1 / 0",
+		"a / (1 - 1)",
+		"1 % 0 + a",
+		"9223372036854775807 + 1",
+		"9223372036854775807 + a",
+		"'a' - 1",
+		"true * 2",
+		"a * 1",
+		"1 * a + 0",
+		"f * 1 - 0",
+		"f + 0",
+		"negativeZero + 0",
+		"negativeZero - 0",
+		"name + 0",
+		"0 + name",
+		"name * 1",
+		"missing * 1",
+		"d * 1",
+		"big * 1",
+		"a ** b ** 1",
+		"f ** 1",
+		"a << 0 | 0",
+		"(a + b) * (a + b)",
+		"abs(a - b) + abs(a - b) * 2",
+		"a > 1 ? len(name) + 1 : len(name) + 1 > 2",
+		"a > 5 && 1 / (a - 2) > 0 || 1 / (a - 2) > 0",
+		"map(items, x -> x * a) == map(items, x -> x * a)",
+		"a * 2 + count(filter(items, a -> a * 2 > 2)) + a * 2",
+	}
+	for _, exp := range expressions {
+		expected, expectedErr := unoptimizedVM.Eval(exp, env)
+		actual, err := virtualMachine.Eval(exp, env)
+		if expectedErr != nil {
+			if assert.NotNil(t, err, exp) {
+				assert.Equal(t, expectedErr.Error(), err.Error(), exp)
+			}
+			continue
+		}
+		if assert.Nil(t, err, exp) {
+			assert.Equal(t, expected.RawValue(), actual.RawValue(), exp)
+		}
+	}
+}
+
+// TestEvalFoldedResultNotShared 数组等可变的结果不会被折叠为常量，否则修改结果会影响下一次计算
+func TestEvalFoldedResultNotShared(t *testing.T) {
+	for _, backend := range []config.Backend{config.TreeWalkingBackend, config.BytecodeBackend} {
+		machine := vm.NewVM(config.WithBackend(backend))
+		for _, exp := range []string{"split('a,b', ',')", "true ? split('a,b', ',') : nil", "split(lower('A,B'), ',')"} {
+			for i := 0; i < 2; i++ {
+				eval, err := machine.Eval(exp, nil)
+				if !assert.Nil(t, err, exp) {
+					break
+				}
+				parts, ok := eval.RawValue().([]interface{})
+				if assert.True(t, ok, exp) && assert.Len(t, parts, 2, exp) {
+					assert.Contains(t, []interface{}{"a", "b"}, parts[0], backend.String()+": "+exp)
+					parts[0] = "MUTATED"
+				}
+			}
+		}
+	}
+
+	// 不可变的结果仍然会被折叠
+	expression, err := ast.Parse("upper('a') + len('ab')")
+	assert.Nil(t, err)
+	builtins := make(map[string]function.Function)
+	for _, f := range function.Builtins() {
+		builtins[f.Name()] = f
+	}
+	optimized, err := ast.Optimize(expression, builtins)
+	assert.Nil(t, err)
+	_, ok := optimized.(*ast.ConstantNode)
+	assert.True(t, ok)
+}
+
+func TestEvalCommonSubexpression(t *testing.T) {
+	calls := map[string]int{}
+	cseVM := vm.NewVM()
+	assert.Nil(t, cseVM.RegisterFunc("score", true, func(a int64) int64 {
+		calls["score"]++
+		return a * 10
+	}))
+	assert.Nil(t, cseVM.RegisterFunc("random", false, func(a int64) int64 {
+		calls["random"]++
+		return a
+	}))
+
+	// 纯函数在一次计算中只调用一次
+	callsByExp := map[string]struct {
+		result interface{}
+		calls  map[string]int
+	}{
+		"score(a) > 1 && score(a) < 100":             {true, map[string]int{"score": 1}},
+		"(score(a) + 1) * (score(a) + 1)":            {int64(441), map[string]int{"score": 1}},
+		"random(a) + random(a)":                      {int64(4), map[string]int{"random": 2}},
+		"a > 5 ? score(a) : score(a + 1) + score(a)": {int64(50), map[string]int{"score": 2}},
+		// 没有被选中的分支不会被提前计算
+		"a > 5 ? score(a) + 1 : 0":                                 {int64(0), map[string]int{}},
+		"a < 5 || score(a) > 1 && score(a) > 2":                    {true, map[string]int{}},
+		"map([1, 2], x -> score(x)) == map([1, 2], x -> score(x))": {true, map[string]int{"score": 2}},
+	}
+	env := map[string]interface{}{"a": 2}
+	for exp, expected := range callsByExp {
+		for i := 0; i < 2; i++ {
+			calls = map[string]int{}
+			eval, err := cseVM.Eval(exp, env)
+			if assert.Nil(t, err, exp) {
+				assert.Equal(t, expected.result, eval.RawValue(), exp)
+			}
+			assert.Equal(t, expected.calls, calls, exp)
+		}
+	}
+
+	// 不同的 env 中分别计算
+	calls = map[string]int{}
+	for a, expected := range map[int]bool{1: true, 20: false} {
+		eval, err := cseVM.Eval("score(a) > 1 && score(a) < 100", map[string]interface{}{"a": a})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, eval.RawValue())
+	}
+	assert.Equal(t, 2, calls["score"])
+}
+
+// 字节码和遍历 ast 的计算结果以及错误信息必须相同
+func TestEvalBytecodeBackend(t *testing.T) {
+	treeVM := newTestVM()
+	bytecodeVM := newTestVM(config.WithBackend(config.BytecodeBackend))
+	for _, machine := range []*vm.VM{treeVM, bytecodeVM} {
+		assert.Nil(t, machine.RegisterFunc("random", false, func(a int64) int64 { return a }))
+		machine.AllowMethods("FullName", "OlderThan", "Len", "PriceAbove")
+	}
+
+	env := map[string]interface{}{
+		"a": 2, "b": 3, "c": 4, "name": "go", "price": 100, "discount": float32(0.25), "factor": 3,
+		"items": []orderLine{
+			{Name: "apple", Price: 12.5, Tags: []string{"fruit"}},
+			{Name: "pear", Price: 6},
+		},
+		"country": "US", "countries": []string{"US", "CA"}, "m": map[string]int{"a": 1, "key": 2},
+		"scores": map[int]string{2: "b"}, "age": 30, "created": time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		"code": "US123", "email": "jane@example.com", "pattern": "^[a-z]+@", "flags": uint8(6),
+		"arr": []int{10, 20, 30}, "f": 1.5, "big": new(big.Int).Lsh(big.NewInt(1), 70),
+		"user": user{First: "Ada", Last: "Lovelace", Age: 36}, "ptr": &user{First: "Alan", Age: 41},
+		"lines": items{{Price: 12.5}, {Price: 5}},
+	}
+
+	expressions := []string{
+		"", "nil", "a * 1", "1 * a + 0", "f ** 1", "name + 0", "(a + b) * (a + b)",
+		"random(a) + random(a)", "a > 1 ? random(a) : 1 / 0", "a > 5 && 1 / 0 > 1 || a < 5",
+		"1 / 0", "a / (b - 3)", "9223372036854775807 + a", "big * 1", "-missing", "!a",
+		"a ? 1 : 2", "a && true", "true && a", "a || b", "unknown(1)", "len(1, 2)", "len(unknown(1))",
+		"arr[missing:]", "arr[1:missing]", "arr[missing:unknown(1)]", "arr[1:]", "arr[:2][0]", "arr['a']",
+		"user.FullName()", "ptr.OlderThan(30)", "lines.PriceAbove(1, 10)", "lines.Len() + 1",
+		"user.Missing()", "missing.FullName()", "user.Discount(1)", "user.OlderThan('a')",
+		"items[0].Name", "items[0].Missing", "missing.name", "user.Last + ' ' + user.First",
+		"map(items, x -> x.Price * factor)", "reduce(items, (acc, x) -> acc + x.Price, 0)",
+		"filter(items, x -> x.Price)", "map(items, x -> map(x.Tags, t -> t + x.Name))",
+		"2 ** 3 ** 2", "a ** b ** 1", "2 ** -1 ** 2", "1 << 2 | a & 7 ^ 1", "'a' =~ pattern", "email =~ pattern",
+		"age between 18 and 60", "country in countries", "a in 1..b", "[1, a, [b]]",
+	}
+	for _, table := range []map[string]interface{}{resultByValidExp, resultByFloatExp, resultByLogicalExp,
+		resultByLiteralExp, resultByStringExp, resultByStringFuncExp, resultByMathFuncExp, resultByLambdaExp,
+		resultByMembershipExp, resultByRegexExp, resultByBitwiseExp, resultByConditionalExp, resultByArrayExp} {
+		for exp := range table {
+			expressions = append(expressions, exp)
+		}
+	}
+
+	for _, exp := range expressions {
+		expected, expectedErr := treeVM.Eval(exp, env)
+		actual, err := bytecodeVM.Eval(exp, env)
+		if expectedErr != nil {
+			if assert.NotNil(t, err, exp) {
+				assert.Equal(t, expectedErr.Error(), err.Error(), exp)
+			}
+			continue
+		}
+		if assert.Nil(t, err, exp) {
+			assert.Equal(t, expected.RawValue(), actual.RawValue(), exp)
+		}
+	}
+
+	// 缓存的字节码在删除、注册函数之后重新编译
+	eval, err := bytecodeVM.Eval("random(a) + 1", env)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), eval.RawValue())
+	bytecodeVM.RemoveFunc("random")
+	_, err = bytecodeVM.Eval("random(a) + 1", env)
+	assert.Equal(t, "invalid udf named 'random'", err.Error())
+	assert.Nil(t, bytecodeVM.RegisterFunc("random", false, func(a int64) int64 { return a * 10 }))
+	eval, err = bytecodeVM.Eval("random(a) + 1", env)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(21), eval.RawValue())
+}
+
+func TestCompileProgram(t *testing.T) {
+	for _, backend := range []config.Backend{config.TreeWalkingBackend, config.BytecodeBackend} {
+		machine := newTestVM(config.WithBackend(backend))
+		assert.Nil(t, machine.RegisterFunc("random", false, func(a int64) int64 { return a }))
+
+		src := "max(random(a), b) + sum(map(items, x -> x.Price * factor)) + len(name)"
+		program, err := machine.Compile(src)
+		assert.Nil(t, err, backend.String())
+		assert.Equal(t, src, program.Source())
+		assert.Equal(t, []string{"a", "b", "items", "factor", "name"}, program.Variables())
+		assert.Equal(t, []string{"max", "random", "sum", "map", "len"}, program.Functions())
+
+		// 返回的是副本
+		program.Variables()[0] = "changed"
+		program.Functions()[0] = "changed"
+		assert.Equal(t, "a", program.Variables()[0])
+		assert.Equal(t, "max", program.Functions()[0])
+
+		// 编译之后删除、注册函数不影响已经编译的 Program
+		machine.RemoveFunc("random")
+		assert.Nil(t, machine.RegisterFunc("random", false, func(a int64) int64 { return a * 10 }))
+
+		// 多个 goroutine 同时执行同一个 Program
+		var wg sync.WaitGroup
+		for i := 0; i < 32; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					env := map[string]interface{}{
+						"a": i, "b": 1, "factor": 2, "name": "go",
+						"items": []orderLine{{Name: "apple", Price: float64(j)}},
+					}
+					value, err := program.Run(env)
+					if assert.Nil(t, err) {
+						expected := math.Max(float64(i), 1) + float64(j*2) + 2
+						assert.Equal(t, expected, value.RawValue(), fmt.Sprintf("%s a=%d price=%d", backend, i, j))
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		_, err = machine.Compile("1 +")
+		assert.NotNil(t, err)
+
+		// 执行阶段的错误在 Run 时返回
+		program, err = machine.Compile("a > 1 ? unknown(a) : 1 / a")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a"}, program.Variables())
+		assert.Equal(t, []string{"unknown"}, program.Functions())
+		_, err = program.Run(map[string]interface{}{"a": 2})
+		assert.Equal(t, "invalid udf named 'unknown'", err.Error())
+		_, err = program.Run(map[string]interface{}{"a": 0})
+		assert.True(t, errors.Is(err, function.ErrDivisionByZero), err)
+		_, err = program.Run(1)
+		assert.NotNil(t, err)
+	}
+
+	program, err := vm.Compile("upper(name)")
+	assert.Nil(t, err)
+	value, err := program.Run(map[string]interface{}{"name": "go"})
+	assert.Nil(t, err)
+	assert.Equal(t, "GO", value.RawValue())
+
+	// 每次 Run 的结果都是新创建的，修改结果不会影响其他的 Run，需要使用 go test -race 检查数据竞争
+	for _, backend := range []config.Backend{config.TreeWalkingBackend, config.BytecodeBackend} {
+		program, err := vm.NewVM(config.WithBackend(backend)).Compile("split('x,y', ',')")
+		assert.Nil(t, err)
+
+		value, err := program.Run(nil)
+		if assert.Nil(t, err) {
+			value.RawValue().([]interface{})[0] = "MUTATED"
+		}
+		value, err = program.Run(nil)
+		if assert.Nil(t, err) {
+			assert.Equal(t, []interface{}{"x", "y"}, value.RawValue(), backend.String())
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 32; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					value, err := program.Run(nil)
+					if !assert.Nil(t, err) {
+						return
+					}
+					parts := value.RawValue().([]interface{})
+					assert.Equal(t, []interface{}{"x", "y"}, parts, backend.String())
+					parts[0] = fmt.Sprintf("goroutine %d", i)
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+}
+
+func TestEvalCacheLRU(t *testing.T) {
+	machine := vm.NewVM(config.WithCacheSize(2))
+	env := map[string]interface{}{"a": 1}
+	for _, exp := range []string{"a + 1", "a + 2", "a + 1", "a + 3", "a + 2", "a + 1"} {
+		_, err := machine.Eval(exp, env)
+		assert.Nil(t, err, exp)
+	}
+	// a + 2 在 a + 3 加入时被淘汰，a + 1 在 a + 2 再次加入时被淘汰
+	assert.Equal(t, vm.CacheStats{Hits: 1, Misses: 5, Evictions: 3, Size: 2, Capacity: 2}, machine.CacheStats())
+
+	// 注册函数时清空缓存，不算作淘汰
+	assert.Nil(t, machine.RegisterFunc("double", true, func(a int64) int64 { return a * 2 }))
+	assert.Equal(t, vm.CacheStats{Hits: 1, Misses: 5, Evictions: 3, Size: 0, Capacity: 2}, machine.CacheStats())
+
+	disabled := vm.NewVM(config.WithCacheSize(0))
+	for i := 0; i < 3; i++ {
+		value, err := disabled.Eval("double(a)", env)
+		assert.NotNil(t, err)
+		assert.Nil(t, value)
+	}
+	assert.Equal(t, vm.CacheStats{Misses: 3}, disabled.CacheStats())
+	assert.Equal(t, config.DefaultCacheSize, vm.NewVM().CacheStats().Capacity)
+}
+
+// TestEvalConcurrent 需要使用 go test -race 检查数据竞争
+func TestEvalConcurrent(t *testing.T) {
+	for _, backend := range []config.Backend{config.TreeWalkingBackend, config.BytecodeBackend} {
+		machine := vm.NewVM(config.WithBackend(backend), config.WithCacheSize(8))
+		assert.Nil(t, machine.RegisterFunc("offset", true, func(a int64) int64 { return a + 100 }))
+		machine.AllowMethods("FullName")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 64; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				env := map[string]interface{}{"a": i, "user": user{First: "Ada", Last: "Lovelace"}}
+				for j := 0; j < 100; j++ {
+					// note 表达式的个数超过缓存的容量，所以会不断的淘汰和重新编译
+					exp := fmt.Sprintf("offset(a) + %d", j%16)
+					value, err := machine.Eval(exp, env)
+					if assert.Nil(t, err, exp) {
+						assert.Equal(t, int64(i+100+j%16), value.RawValue(), exp)
+					}
+
+					value, err = machine.Eval("user.FullName() + '-' + upper(user.Last)", env)
+					if assert.Nil(t, err) {
+						assert.Equal(t, "Ada Lovelace-LOVELACE", value.RawValue())
+					}
+
+					value, err = vm.Eval("a * 2 + len('go')", env)
+					if assert.Nil(t, err) {
+						assert.Equal(t, int64(i*2+2), value.RawValue())
+					}
+				}
+			}(i)
+		}
+
+		// 执行表达式的同时注册、删除函数以及修改允许调用的方法
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				name := fmt.Sprintf("temp%d", i)
+				for j := 0; j < 20; j++ {
+					assert.Nil(t, machine.RegisterFunc(name, false, func(a int64) int64 { return a }))
+					machine.RemoveFunc(name)
+					machine.AllowMethods(name)
+					_ = machine.CacheStats()
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		stats := machine.CacheStats()
+		assert.Equal(t, uint64(64*100*2), stats.Hits+stats.Misses, backend.String())
+		assert.True(t, stats.Size <= 8, backend.String())
+	}
+}
+
+// TestEvalReentrant 常量折叠时调用的函数中注册函数和执行表达式不会死锁
+func TestEvalReentrant(t *testing.T) {
+	for _, backend := range []config.Backend{config.TreeWalkingBackend, config.BytecodeBackend} {
+		machine := vm.NewVM(config.WithBackend(backend))
+		registered := 0
+		assert.Nil(t, machine.RegisterFunc("reg", true, func(x int64) (int64, error) {
+			registered++
+			if err := machine.RegisterFunc(fmt.Sprintf("inner%d", registered), false, func() int64 { return 1 }); err != nil {
+				return 0, err
+			}
+			value, err := machine.Eval("inner1() + 1", nil)
+			if err != nil {
+				return 0, err
+			}
+			return x + value.RawValue().(int64), nil
+		}))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			value, err := machine.Eval("reg(1) + 1", nil)
+			if assert.Nil(t, err, backend.String()) {
+				assert.Equal(t, int64(4), value.RawValue(), backend.String())
+			}
+			// 编译的过程中注册了函数，使用旧函数编译的表达式不会被缓存，所以再次执行时重新折叠
+			value, err = machine.Eval("reg(1) + 1", nil)
+			if assert.Nil(t, err, backend.String()) {
+				assert.Equal(t, int64(4), value.RawValue(), backend.String())
+			}
+			assert.Equal(t, 2, registered, backend.String())
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: eval deadlocked when a folded function re-entered the vm", backend)
+		}
+	}
+}
+
+var benchmarkExps = map[string]string{
+	"arithmetic":  "(a + b) * c - a / 2 + b % 2",
+	"logical":     "a > 1 && b < 10 || c == 4 && name != 'go'",
+	"conditional": "a > b ? price * 0.9 : price * discount",
+	"function":    "max(a, b) + len(name) + abs(c - 10)",
+	"member":      "items[0].Price * factor + items[1].Price",
+	"lambda":      "sum(map(items, x -> x.Price * factor))",
+}
+
+func benchmarkBackend(b *testing.B, backend config.Backend) {
+	machine := vm.NewVM(config.WithBackend(backend))
+	env := map[string]interface{}{
+		"a": 2, "b": 3, "c": 4, "name": "goscript", "price": 100, "discount": 0.25, "factor": 3,
+		"items": []orderLine{{Name: "apple", Price: 12.5}, {Name: "pear", Price: 6}},
+	}
+	for name, exp := range benchmarkExps {
+		b.Run(name, func(b *testing.B) {
+			if _, err := machine.Eval(exp, env); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = machine.Eval(exp, env)
+			}
+		})
+	}
+}
+
+func BenchmarkEvalTreeWalking(b *testing.B) {
+	benchmarkBackend(b, config.TreeWalkingBackend)
+}
+
+func BenchmarkEvalBytecode(b *testing.B) {
+	benchmarkBackend(b, config.BytecodeBackend)
+}
+
+func BenchmarkProgramRun(b *testing.B) {
+	env := map[string]interface{}{
+		"a": 2, "b": 3, "c": 4, "name": "goscript", "price": 100, "discount": 0.25, "factor": 3,
+		"items": []orderLine{{Name: "apple", Price: 12.5}, {Name: "pear", Price: 6}},
+	}
+	for name, exp := range benchmarkExps {
+		program, err := vm.NewVM(config.WithBackend(config.BytecodeBackend)).Compile(exp)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = program.Run(env)
+			}
+		})
+	}
+}
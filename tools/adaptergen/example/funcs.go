@@ -0,0 +1,72 @@
+// Package example 演示 adaptergen 的用法，goscript_funcs.go 由 go generate 生成
+package example
+
+import (
+	"errors"
+	"goscript/vm"
+	"strings"
+)
+
+//go:generate go run goscript/tools/adaptergen -output goscript_funcs.go
+
+// Repeat 重复字符串
+//
+//goscript:func repeat fold
+func Repeat(n int64, s string) string {
+	return strings.Repeat(s, int(n))
+}
+
+// IsAdult 是否成年
+//
+//goscript:func isAdult fold
+func IsAdult(age int) bool {
+	return age >= 18
+}
+
+// Sum 求和
+//
+//goscript:func sum fold
+func Sum(base float64, values ...float64) float64 {
+	for _, v := range values {
+		base += v
+	}
+	return base
+}
+
+// SafeDiv 除数为 0 时返回错误
+//
+//goscript:func safeDiv fold
+func SafeDiv(a, b int64) (int64, error) {
+	if b == 0 {
+		return 0, errors.New("divide by zero")
+	}
+	return a / b, nil
+}
+
+// Describe 参数类型为 vm.Value 时不做转换
+//
+//goscript:func
+func Describe(v vm.Value) string {
+	s, _ := v.AsString()
+	return s
+}
+
+// Small 参数会检查是否溢出
+//
+//goscript:func small
+func Small(v int8, u uint8, f float32) float32 {
+	return float32(v) + float32(u) + f
+}
+
+// Check 只返回 error
+//
+//goscript:func check
+func Check(ok bool, _ interface{}) error {
+	if !ok {
+		return errors.New("check failed")
+	}
+	return nil
+}
+
+// notRegistered 没有标注的函数不会被注册
+func notRegistered() {}
@@ -0,0 +1,79 @@
+package example
+
+import (
+	"github.com/stretchr/testify/assert"
+	"goscript/vm"
+	"testing"
+)
+
+func TestRegisterFuncs(t *testing.T) {
+	v := vm.NewVM()
+	assert.Nil(t, RegisterFuncs(v))
+
+	resultByExp := map[string]interface{}{
+		"repeat(3, 'ab')":     "ababab",
+		"isAdult(age)":        true,
+		"sum(1)":              1.0,
+		"sum(1, 2, 3.5)":      6.5,
+		"safeDiv(7, 2)":       int64(3),
+		"small(1, 2, 0.5)":    float32(3.5),
+		"check(age > 1, nil)": nil,
+		"Describe('x')":       "x",
+	}
+	env := map[string]interface{}{"age": 20}
+	for exp, expected := range resultByExp {
+		eval, err := v.Eval(exp, env)
+		if err != nil {
+			t.Fatalf("ext: '%s', err: %v", exp, err)
+		}
+		assert.Equal(t, expected, eval.RawValue(), "exp: "+exp)
+	}
+
+	errorByExp := map[string]string{
+		"repeat('3', 'ab')": "argument 1 of 'repeat': can not use 3 (string) as int64",
+		"isAdult(1.5)":      "argument 1 of 'isAdult': can not use 1.5 (float64) as int",
+		"sum()":             "'sum' require at least 1 arguments instead of 0",
+		"sum(1, 'a')":       "argument 2 of 'sum'",
+		"safeDiv(1, 0)":     "divide by zero",
+		"small(128, 1, 1)":  "argument 1 of 'small': 128 overflows int8",
+		"small(1, -1, 1)":   "argument 2 of 'small': -1 overflows uint8",
+		"check(false, 1)":   "check failed",
+		"isAdult(1, 2)":     "require 1 argument instead of 2",
+	}
+	for exp, expected := range errorByExp {
+		_, err := v.Eval(exp, env)
+		if assert.NotNil(t, err, "exp: "+exp) {
+			assert.Contains(t, err.Error(), expected, "exp: "+exp)
+		}
+	}
+}
+
+func BenchmarkGeneratedAdapter(b *testing.B) {
+	v := vm.NewVM()
+	if err := RegisterFuncs(v); err != nil {
+		b.Fatal(err)
+	}
+	benchmarkEval(b, v)
+}
+
+func BenchmarkReflectAdapter(b *testing.B) {
+	v := vm.NewVM()
+	if err := v.RegisterFunc("sum", true, Sum); err != nil {
+		b.Fatal(err)
+	}
+	if err := v.RegisterFunc("repeat", true, Repeat); err != nil {
+		b.Fatal(err)
+	}
+	benchmarkEval(b, v)
+}
+
+func benchmarkEval(b *testing.B, v *vm.VM) {
+	env := map[string]interface{}{"a": 1, "b": 2.5}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := v.Eval("sum(a, b, 3) + sum(a)", env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
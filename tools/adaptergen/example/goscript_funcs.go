@@ -0,0 +1,106 @@
+// Code generated by adaptergen. DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+	"goscript/vm"
+)
+
+// RegisterFuncs 将 //goscript:func 标注的函数注册到 vm 中，调用的时候不需要反射
+func RegisterFuncs(v *vm.VM) error {
+	if err := v.RegisterFuncAdapter("repeat", true, 2, func(args []interface{}) (interface{}, error) {
+		arg0, err := vm.ArgInteger(args[0], 64, "int64")
+		if err != nil {
+			return nil, vm.ArgumentError("repeat", 1, err)
+		}
+		arg1, err := vm.ArgString(args[1])
+		if err != nil {
+			return nil, vm.ArgumentError("repeat", 2, err)
+		}
+		return Repeat(arg0, arg1), nil
+	}); err != nil {
+		return err
+	}
+
+	if err := v.RegisterFuncAdapter("isAdult", true, 1, func(args []interface{}) (interface{}, error) {
+		arg0, err := vm.ArgInteger(args[0], 0, "int")
+		if err != nil {
+			return nil, vm.ArgumentError("isAdult", 1, err)
+		}
+		return IsAdult(int(arg0)), nil
+	}); err != nil {
+		return err
+	}
+
+	if err := v.RegisterFuncAdapter("sum", true, -1, func(args []interface{}) (interface{}, error) {
+		if len(args) < 1 {
+			return nil, fmt.Errorf("'%s' require at least %d arguments instead of %d", "sum", 1, len(args))
+		}
+		arg0, err := vm.ArgFloat(args[0], "float64")
+		if err != nil {
+			return nil, vm.ArgumentError("sum", 1, err)
+		}
+		rest := make([]float64, 0, len(args)-1)
+		for i := 1; i < len(args); i++ {
+			element, err := vm.ArgFloat(args[i], "float64")
+			if err != nil {
+				return nil, vm.ArgumentError("sum", i+1, err)
+			}
+			rest = append(rest, element)
+		}
+		return Sum(arg0, rest...), nil
+	}); err != nil {
+		return err
+	}
+
+	if err := v.RegisterFuncAdapter("safeDiv", true, 2, func(args []interface{}) (interface{}, error) {
+		arg0, err := vm.ArgInteger(args[0], 64, "int64")
+		if err != nil {
+			return nil, vm.ArgumentError("safeDiv", 1, err)
+		}
+		arg1, err := vm.ArgInteger(args[1], 64, "int64")
+		if err != nil {
+			return nil, vm.ArgumentError("safeDiv", 2, err)
+		}
+		return SafeDiv(arg0, arg1)
+	}); err != nil {
+		return err
+	}
+
+	if err := v.RegisterFuncAdapter("Describe", false, 1, func(args []interface{}) (interface{}, error) {
+		return Describe(vm.ValueOf(args[0])), nil
+	}); err != nil {
+		return err
+	}
+
+	if err := v.RegisterFuncAdapter("small", false, 3, func(args []interface{}) (interface{}, error) {
+		arg0, err := vm.ArgInteger(args[0], 8, "int8")
+		if err != nil {
+			return nil, vm.ArgumentError("small", 1, err)
+		}
+		arg1, err := vm.ArgUnsigned(args[1], 8, "uint8")
+		if err != nil {
+			return nil, vm.ArgumentError("small", 2, err)
+		}
+		arg2, err := vm.ArgFloat(args[2], "float32")
+		if err != nil {
+			return nil, vm.ArgumentError("small", 3, err)
+		}
+		return Small(int8(arg0), uint8(arg1), float32(arg2)), nil
+	}); err != nil {
+		return err
+	}
+
+	if err := v.RegisterFuncAdapter("check", false, 2, func(args []interface{}) (interface{}, error) {
+		arg0, err := vm.ArgBool(args[0])
+		if err != nil {
+			return nil, vm.ArgumentError("check", 1, err)
+		}
+		return nil, Check(arg0, args[1])
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
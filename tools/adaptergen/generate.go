@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+)
+
+// generate 生成注册函数的源码
+func generate(pkg *goPackage, register string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "// Code generated by adaptergen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg.name)
+	fmt.Fprintf(&buf, "import (\n")
+	for _, fn := range pkg.funcs {
+		// 可变参数的函数需要检查参数数量
+		if fn.variadic {
+			fmt.Fprintf(&buf, "%q\n", "fmt")
+			break
+		}
+	}
+	fmt.Fprintf(&buf, "%q\n)\n\n", vmImportPath)
+
+	fmt.Fprintf(&buf, "// %s 将 //goscript:func 标注的函数注册到 vm 中，调用的时候不需要反射\n", register)
+	fmt.Fprintf(&buf, "func %s(v *vm.VM) error {\n", register)
+	for _, fn := range pkg.funcs {
+		generateFunc(&buf, fn)
+	}
+	fmt.Fprintf(&buf, "return nil\n}\n")
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %v\n%s", err, buf.String())
+	}
+	return source, nil
+}
+
+func generateFunc(buf *bytes.Buffer, fn funcDecl) {
+	argumentsNum := len(fn.params)
+	if fn.variadic {
+		argumentsNum = -1
+	}
+
+	fmt.Fprintf(buf, "if err := v.RegisterFuncAdapter(%q, %t, %d, func(args []interface{}) (interface{}, error) {\n",
+		fn.name, fn.allowFold, argumentsNum)
+
+	fixed := len(fn.params)
+	if fn.variadic {
+		fixed--
+		fmt.Fprintf(buf, "if len(args) < %d {\n", fixed)
+		fmt.Fprintf(buf, "return nil, fmt.Errorf(\"'%%s' require at least %%d arguments instead of %%d\", %q, %d, len(args))\n",
+			fn.name, fixed)
+		fmt.Fprintf(buf, "}\n")
+	}
+
+	callArgs := make([]string, 0, len(fn.params))
+	for i := 0; i < fixed; i++ {
+		callArgs = append(callArgs, generateArg(buf, fn.name, fn.params[i], "arg"+strconv.Itoa(i), "args["+strconv.Itoa(i)+"]",
+			strconv.Itoa(i+1)))
+	}
+
+	if fn.variadic {
+		kind := fn.params[fixed]
+		fmt.Fprintf(buf, "rest := make([]%s, 0, len(args)-%d)\n", kind.typeName, fixed)
+		fmt.Fprintf(buf, "for i := %d; i < len(args); i++ {\n", fixed)
+		element := generateArg(buf, fn.name, kind, "element", "args[i]", "i+1")
+		fmt.Fprintf(buf, "rest = append(rest, %s)\n", element)
+		fmt.Fprintf(buf, "}\n")
+		callArgs = append(callArgs, "rest...")
+	}
+
+	call := fn.goName + "("
+	for i, arg := range callArgs {
+		if i > 0 {
+			call += ", "
+		}
+		call += arg
+	}
+	call += ")"
+
+	switch fn.result {
+	case noResult:
+		fmt.Fprintf(buf, "%s\nreturn nil, nil\n", call)
+	case valueResult:
+		fmt.Fprintf(buf, "return %s, nil\n", call)
+	case errorResult:
+		fmt.Fprintf(buf, "return nil, %s\n", call)
+	case valueAndErrorResult:
+		fmt.Fprintf(buf, "return %s\n", call)
+	}
+
+	fmt.Fprintf(buf, "}); err != nil {\nreturn err\n}\n\n")
+}
+
+// generateArg 生成参数转换的代码，返回调用函数时使用的表达式
+func generateArg(buf *bytes.Buffer, funcName string, kind paramKind, variable, source, index string) string {
+	switch kind.convert {
+	case "":
+		return source
+	case "vm.ValueOf":
+		return "vm.ValueOf(" + source + ")"
+	case "vm.ArgInteger", "vm.ArgUnsigned":
+		fmt.Fprintf(buf, "%s, err := %s(%s, %d, %q)\n", variable, kind.convert, source, kind.bits, kind.typeName)
+	case "vm.ArgFloat":
+		fmt.Fprintf(buf, "%s, err := %s(%s, %q)\n", variable, kind.convert, source, kind.typeName)
+	default:
+		fmt.Fprintf(buf, "%s, err := %s(%s)\n", variable, kind.convert, source)
+	}
+
+	fmt.Fprintf(buf, "if err != nil {\nreturn nil, vm.ArgumentError(%q, %s, err)\n}\n", funcName, index)
+	if kind.typeName == "int64" || kind.typeName == "uint64" || kind.typeName == "float64" ||
+		kind.typeName == "string" || kind.typeName == "bool" {
+		return variable
+	}
+	return kind.typeName + "(" + variable + ")"
+}
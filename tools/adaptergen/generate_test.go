@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGenerateExample 生成的代码和 example/goscript_funcs.go 一致，修改生成逻辑后需要重新执行 go generate
+func TestGenerateExample(t *testing.T) {
+	pkg, err := parsePackage("example", "goscript_funcs.go")
+	assert.Nil(t, err)
+
+	source, err := generate(pkg, "RegisterFuncs")
+	assert.Nil(t, err)
+
+	expected, err := os.ReadFile(filepath.Join("example", "goscript_funcs.go"))
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(source))
+}
+
+func TestGenerateBadCase(t *testing.T) {
+	sourceByCase := map[string]string{
+		"unsupported param": "package bad\n//goscript:func\nfunc F(m map[string]int) int { return 0 }\n",
+		"method":            "package bad\ntype T struct{}\n//goscript:func\nfunc (T) F() int { return 0 }\n",
+		"bad error":         "package bad\n//goscript:func\nfunc F() (int, int) { return 0, 0 }\n",
+		"too many results":  "package bad\n//goscript:func\nfunc F() (int, int, error) { return 0, 0, nil }\n",
+	}
+
+	for name, source := range sourceByCase {
+		dir := t.TempDir()
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "bad.go"), []byte(source), 0644))
+
+		_, err := parsePackage(dir, "goscript_funcs.go")
+		t.Logf("%s: %v", name, err)
+		assert.NotNil(t, err, name)
+	}
+}
@@ -0,0 +1,53 @@
+// Command adaptergen 为 go 函数生成 vm 可以直接调用的适配器，调用的时候不需要反射
+//
+// 在函数的注释中添加 //goscript:func [name] [fold] 标注需要注册的函数，
+// name 为表达式中使用的函数名，默认为 go 函数名；fold 表示常量参数时允许折叠
+//
+//	//goscript:func repeat fold
+//	func Repeat(n int64, s string) string { ... }
+//
+// 然后在该包中添加
+//
+//	//go:generate go run goscript/tools/adaptergen -output goscript_funcs.go
+//
+// 生成的文件包含 RegisterFuncs(v *vm.VM) error，将所有标注的函数通过 VM.RegisterFuncAdapter 注册到 vm 中。
+// 支持的参数类型: 整数、浮点数、string、bool、interface{}、vm.Value 以及它们的可变参数，
+// 支持的返回值: 无、T、error、(T, error)
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	output := flag.String("output", "goscript_funcs.go", "生成的文件名")
+	register := flag.String("register", "RegisterFuncs", "生成的注册函数名")
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	if err := run(dir, *output, *register); err != nil {
+		fmt.Fprintf(os.Stderr, "adaptergen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir, output, register string) error {
+	pkg, err := parsePackage(dir, output)
+	if err != nil {
+		return err
+	}
+
+	source, err := generate(pkg, register)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, output), source, 0644)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	annotation   = "//goscript:func"
+	vmImportPath = "goscript/vm"
+)
+
+// paramKind 参数类型以及转换方式
+type paramKind struct {
+	// typeName go 中的类型，比如 int32
+	typeName string
+	// convert 转换函数，比如 vm.ArgInteger
+	convert string
+	// bits 整数的位数，0 表示 int/uint
+	bits int
+}
+
+var paramKindByIdent = map[string]paramKind{
+	"int":     {typeName: "int", convert: "vm.ArgInteger", bits: 0},
+	"int8":    {typeName: "int8", convert: "vm.ArgInteger", bits: 8},
+	"int16":   {typeName: "int16", convert: "vm.ArgInteger", bits: 16},
+	"int32":   {typeName: "int32", convert: "vm.ArgInteger", bits: 32},
+	"int64":   {typeName: "int64", convert: "vm.ArgInteger", bits: 64},
+	"uint":    {typeName: "uint", convert: "vm.ArgUnsigned", bits: 0},
+	"uint8":   {typeName: "uint8", convert: "vm.ArgUnsigned", bits: 8},
+	"uint16":  {typeName: "uint16", convert: "vm.ArgUnsigned", bits: 16},
+	"uint32":  {typeName: "uint32", convert: "vm.ArgUnsigned", bits: 32},
+	"uint64":  {typeName: "uint64", convert: "vm.ArgUnsigned", bits: 64},
+	"float32": {typeName: "float32", convert: "vm.ArgFloat"},
+	"float64": {typeName: "float64", convert: "vm.ArgFloat"},
+	"string":  {typeName: "string", convert: "vm.ArgString"},
+	"bool":    {typeName: "bool", convert: "vm.ArgBool"},
+	"any":     {typeName: "interface{}"},
+}
+
+var valueParam = paramKind{typeName: "vm.Value", convert: "vm.ValueOf"}
+
+// resultKind 返回值的形式
+type resultKind int
+
+const (
+	noResult resultKind = iota
+	valueResult
+	errorResult
+	valueAndErrorResult
+)
+
+// funcDecl 被标注的函数
+type funcDecl struct {
+	goName    string
+	name      string
+	allowFold bool
+	params    []paramKind
+	variadic  bool
+	result    resultKind
+}
+
+type goPackage struct {
+	name  string
+	funcs []funcDecl
+}
+
+// parsePackage 解析 dir 中所有非测试文件，output 是生成的文件、不参与解析
+func parsePackage(dir, output string) (*goPackage, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	pkg := &goPackage{}
+	fileSet := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") || filepath.Base(file) == output {
+			continue
+		}
+
+		f, err := parser.ParseFile(fileSet, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		pkg.name = f.Name.Name
+
+		vmAlias := importAlias(f, vmImportPath)
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Doc == nil {
+				continue
+			}
+
+			name, allowFold, annotated := parseAnnotation(fn.Doc)
+			if !annotated {
+				continue
+			}
+
+			parsed, err := parseFuncDecl(fn, name, allowFold, vmAlias)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", fileSet.Position(fn.Pos()), err)
+			}
+			pkg.funcs = append(pkg.funcs, *parsed)
+		}
+	}
+
+	if pkg.name == "" {
+		return nil, fmt.Errorf("no go files in %s", dir)
+	}
+	return pkg, nil
+}
+
+// parseAnnotation //goscript:func [name] [fold]
+func parseAnnotation(doc *ast.CommentGroup) (name string, allowFold bool, annotated bool) {
+	for _, comment := range doc.List {
+		if !strings.HasPrefix(comment.Text, annotation) {
+			continue
+		}
+
+		for _, field := range strings.Fields(strings.TrimPrefix(comment.Text, annotation)) {
+			if field == "fold" {
+				allowFold = true
+			} else {
+				name = field
+			}
+		}
+		return name, allowFold, true
+	}
+	return "", false, false
+}
+
+func parseFuncDecl(fn *ast.FuncDecl, name string, allowFold bool, vmAlias string) (*funcDecl, error) {
+	if fn.Recv != nil {
+		return nil, fmt.Errorf("%s: method is not supported", fn.Name.Name)
+	}
+	if fn.Type.TypeParams != nil {
+		return nil, fmt.Errorf("%s: generic function is not supported", fn.Name.Name)
+	}
+
+	decl := &funcDecl{goName: fn.Name.Name, name: name, allowFold: allowFold}
+	if decl.name == "" {
+		decl.name = fn.Name.Name
+	}
+
+	for _, field := range fn.Type.Params.List {
+		typeExpr := field.Type
+		if ellipsis, ok := typeExpr.(*ast.Ellipsis); ok {
+			decl.variadic = true
+			typeExpr = ellipsis.Elt
+		}
+
+		kind, err := parseParamKind(typeExpr, vmAlias)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fn.Name.Name, err)
+		}
+
+		// func(a, b int) 中一个 field 对应多个参数
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			decl.params = append(decl.params, kind)
+		}
+	}
+
+	result, err := parseResultKind(fn.Type.Results)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn.Name.Name, err)
+	}
+	decl.result = result
+
+	return decl, nil
+}
+
+func parseParamKind(expr ast.Expr, vmAlias string) (paramKind, error) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if kind, ok := paramKindByIdent[e.Name]; ok {
+			return kind, nil
+		}
+	case *ast.InterfaceType:
+		if e.Methods == nil || len(e.Methods.List) == 0 {
+			return paramKindByIdent["any"], nil
+		}
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok && vmAlias != "" && x.Name == vmAlias && e.Sel.Name == "Value" {
+			return valueParam, nil
+		}
+	}
+
+	return paramKind{}, fmt.Errorf("unsupported parameter type %s", exprString(expr))
+}
+
+func parseResultKind(results *ast.FieldList) (resultKind, error) {
+	types := make([]ast.Expr, 0)
+	if results != nil {
+		for _, field := range results.List {
+			count := len(field.Names)
+			if count == 0 {
+				count = 1
+			}
+			for i := 0; i < count; i++ {
+				types = append(types, field.Type)
+			}
+		}
+	}
+
+	switch len(types) {
+	case 0:
+		return noResult, nil
+	case 1:
+		if isErrorType(types[0]) {
+			return errorResult, nil
+		}
+		return valueResult, nil
+	case 2:
+		if !isErrorType(types[1]) {
+			return 0, fmt.Errorf("the second return value should be error")
+		}
+		return valueAndErrorResult, nil
+	default:
+		return 0, fmt.Errorf("too many return values, expected T or (T, error)")
+	}
+}
+
+func isErrorType(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "error"
+}
+
+// importAlias 文件中导入 path 时使用的名称，没有导入时返回空字符串
+func importAlias(f *ast.File, path string) string {
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || importPath != path {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return filepath.Base(path)
+	}
+	return ""
+}
+
+func exprString(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return exprString(e.X) + "." + e.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprString(e.X)
+	case *ast.ArrayType:
+		return "[]" + exprString(e.Elt)
+	case *ast.MapType:
+		return "map[" + exprString(e.Key) + "]" + exprString(e.Value)
+	default:
+		return fmt.Sprintf("%T", expr)
+	}
+}
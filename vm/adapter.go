@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"goscript/function"
+	"math"
+)
+
+// RegisterFuncAdapter 注册已经适配为 function.Invoker 的函数，调用的时候不需要反射
+//
+//	主要给 tools/adaptergen 生成的代码使用，argumentsNum 为 -1 表示可变参数
+func (vm *VM) RegisterFuncAdapter(name string, allowFold bool, argumentsNum int, f function.Invoker) error {
+	if f == nil {
+		return errors.New("function should not be nil")
+	}
+
+	return vm.registerFuncN(name, allowFold, argumentsNum, f, f)
+}
+
+// ValueOf 将原始值包装为 Value
+func ValueOf(rawValue interface{}) Value {
+	return Value{rawValue: rawValue}
+}
+
+// ArgumentError 第 index(从1开始) 个参数转换失败
+func ArgumentError(funcName string, index int, err error) error {
+	return function.ArgumentError(funcName, index, err)
+}
+
+// ArgInteger 将参数转换为 bits 位的有符号整数，bits 为 0 表示 int
+//
+//	数字之间可以转换，但是不接受非整数的小数，也会检查是否溢出
+func ArgInteger(arg interface{}, bits int, typeName string) (int64, error) {
+	if arg == nil || !function.IsNumber(arg) {
+		return 0, fmt.Errorf("can not use %v (%T) as %s", arg, arg, typeName)
+	}
+
+	i, f, isFloat, err := function.Number(arg)
+	if err != nil {
+		return 0, err
+	}
+	if isFloat {
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, fmt.Errorf("can not use %v (%T) as %s", arg, arg, typeName)
+		}
+		i = int64(f)
+	}
+
+	if bits == 0 {
+		bits = strconvIntSize
+	}
+	if bits < 64 && (i < -1<<(bits-1) || i > 1<<(bits-1)-1) {
+		return 0, fmt.Errorf("%d overflows %s", i, typeName)
+	}
+	return i, nil
+}
+
+// ArgUnsigned 将参数转换为 bits 位的无符号整数，bits 为 0 表示 uint
+func ArgUnsigned(arg interface{}, bits int, typeName string) (uint64, error) {
+	i, err := ArgInteger(arg, 64, typeName)
+	if err != nil {
+		return 0, err
+	}
+
+	if bits == 0 {
+		bits = strconvIntSize
+	}
+	if i < 0 || (bits < 64 && uint64(i) > 1<<bits-1) {
+		return 0, fmt.Errorf("%d overflows %s", i, typeName)
+	}
+	return uint64(i), nil
+}
+
+// ArgFloat 将数字参数转换为 float64
+func ArgFloat(arg interface{}, typeName string) (float64, error) {
+	if arg == nil || !function.IsNumber(arg) {
+		return 0, fmt.Errorf("can not use %v (%T) as %s", arg, arg, typeName)
+	}
+	return function.Float64(arg)
+}
+
+// ArgString 参数必须是 string
+func ArgString(arg interface{}) (string, error) {
+	if str, ok := arg.(string); ok {
+		return str, nil
+	}
+	return "", fmt.Errorf("can not use %v (%T) as string", arg, arg)
+}
+
+// ArgBool 参数必须是 bool
+func ArgBool(arg interface{}) (bool, error) {
+	if b, ok := arg.(bool); ok {
+		return b, nil
+	}
+	return false, fmt.Errorf("can not use %v (%T) as bool", arg, arg)
+}
+
+// strconvIntSize int 的位数
+const strconvIntSize = 32 << (^uint(0) >> 63)
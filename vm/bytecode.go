@@ -0,0 +1,189 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"goscript/function"
+	"reflect"
+)
+
+// execute 基于栈执行字节码，计算结果和错误信息都和 cal 相同
+func (vm *VM) execute(code *bytecode, env Env) (interface{}, error) {
+	stack := newStackWithCapacity(code.maxStack)
+	var common *commonEnv
+
+	instructions := code.instructions
+	for pc := 0; pc < len(instructions); pc++ {
+		ins := instructions[pc]
+		switch ins.code {
+		case opConstant:
+			stack.push(code.constants[ins.arg])
+
+		case opVariable:
+			value, err := vm.calVariable(code.names[ins.arg], env)
+			if err != nil {
+				return nil, err
+			}
+			stack.push(value)
+
+		case opBinary:
+			operator := code.operators[ins.arg]
+			arg2 := stack.pop()
+			arg1 := stack.pop()
+			result, err := operator.f(arg1, arg2)
+			if err != nil {
+				return nil, newEvalError(operator.op, err, arg1, arg2)
+			}
+			stack.push(result)
+
+		case opUnary:
+			result, err := vm.unary(code.names[ins.arg], stack.pop())
+			if err != nil {
+				return nil, err
+			}
+			stack.push(result)
+
+		case opSwap:
+			top := stack.pop()
+			second := stack.pop()
+			stack.push(top)
+			stack.push(second)
+
+		case opJump:
+			pc = ins.jump - 1
+
+		case opJumpIfFalse:
+			condition, err := conditionValue(stack.pop())
+			if err != nil {
+				return nil, err
+			}
+			if !condition {
+				pc = ins.jump - 1
+			}
+
+		case opShortCircuit:
+			b, short, err := shortCircuit(code.names[ins.arg], stack.peek())
+			if err != nil {
+				return nil, err
+			}
+			if short {
+				stack.pop()
+				stack.push(b)
+				pc = ins.jump - 1
+			}
+
+		case opCall:
+			call := code.funcs[ins.arg]
+			args := stack.popN(call.argumentsNum)
+			result, err := call.f.Call(args)
+			if err != nil {
+				return nil, newEvalError(call.f.Name(), err, args...)
+			}
+			stack.push(result)
+
+		case opArray:
+			stack.push(stack.popN(ins.arg))
+
+		case opIndex:
+			index := stack.pop()
+			result, err := function.Index(stack.pop(), index)
+			if err != nil {
+				return nil, err
+			}
+			stack.push(result)
+
+		case opSliceIndex:
+			if stack.peek() == nil {
+				return nil, errNilSliceIndex
+			}
+
+		case opSlice:
+			var low, high interface{}
+			if ins.arg&sliceHigh != 0 {
+				high = stack.pop()
+			}
+			if ins.arg&sliceLow != 0 {
+				low = stack.pop()
+			}
+			result, err := function.Slice(stack.pop(), low, high)
+			if err != nil {
+				return nil, err
+			}
+			stack.push(result)
+
+		case opMember:
+			access := code.members[ins.arg]
+			result, err := member(stack.pop(), access.name, access.path)
+			if err != nil {
+				return nil, err
+			}
+			stack.push(result)
+
+		case opMethod:
+			access := code.members[ins.arg]
+			method, err := vm.lookupMethod(stack.pop(), access.name, access.path)
+			if err != nil {
+				return nil, err
+			}
+			stack.push(method)
+
+		case opMethodCall:
+			access := code.members[ins.arg]
+			args := stack.popN(access.argumentsNum)
+			method, ok := stack.pop().(reflect.Value)
+			if !ok {
+				return nil, errors.New("invalid bytecode: method expected")
+			}
+			result, err := callReflect(access.name, method, args)
+			if err != nil {
+				return nil, err
+			}
+			stack.push(result)
+
+		case opLambda:
+			stack.push(vm.executeLambda(code.lambdas[ins.arg], env))
+
+		case opIdentity:
+			if code.identities[ins.arg].IsIdentity(stack.peek()) {
+				pc = ins.jump - 1
+			}
+
+		case opCommonScope:
+			common = newCommonEnv(env, ins.arg)
+
+		case opCommonLoad:
+			if common == nil {
+				continue
+			}
+			if value, ok := common.load(ins.arg); ok {
+				stack.push(value)
+				pc = ins.jump - 1
+			}
+
+		case opCommonStore:
+			if common != nil {
+				common.store(ins.arg, stack.peek())
+			}
+
+		case opFail:
+			return nil, code.errors[ins.arg]
+
+		default:
+			return nil, errors.New(fmt.Sprintf("invalid opcode %d", ins.code))
+		}
+	}
+
+	return stack.pop(), nil
+}
+
+// executeLambda 和 calLambda 相同，调用的时候参数所在的作用域覆盖在 env 之上
+func (vm *VM) executeLambda(lambda lambdaCode, env Env) *function.Lambda {
+	params := lambda.params
+	return function.NewLambda(params, func(args []interface{}) (interface{}, error) {
+		scope := make(MapEnv, len(params))
+		for i, param := range params {
+			scope[param] = args[i]
+		}
+		return vm.execute(lambda.body, NewChainEnv(scope, env))
+	})
+}
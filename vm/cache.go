@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CacheStats 表达式缓存的统计信息，Hits、Misses 和 Evictions 是 vm 创建之后的累计值
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Size 当前缓存的表达式个数
+	Size int
+	// Capacity 最多缓存的表达式个数，见 config.WithCacheSize
+	Capacity int
+}
+
+// lruCache 容量固定的表达式缓存，超过容量时淘汰最久没有使用的表达式
+//
+//	note 查询也会修改使用顺序，所以使用 Mutex 而不是 RWMutex
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	// order 中的元素是 *cacheEntry，最近使用的在最前边
+	order   *list.List
+	entries map[string]*list.Element
+	stats   CacheStats
+}
+
+type cacheEntry struct {
+	exp      string
+	compiled *compiledExpression
+}
+
+func newLRUCache(capacity int) *lruCache {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &lruCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (cache *lruCache) get(exp string) *compiledExpression {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[exp]
+	if !ok {
+		cache.stats.Misses++
+		return nil
+	}
+	cache.stats.Hits++
+	cache.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).compiled
+}
+
+func (cache *lruCache) add(exp string, compiled *compiledExpression) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.capacity == 0 {
+		return
+	}
+	// note 多个 goroutine 同时编译同一个表达式时后边的覆盖前边的
+	if element, ok := cache.entries[exp]; ok {
+		element.Value.(*cacheEntry).compiled = compiled
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.entries[exp] = cache.order.PushFront(&cacheEntry{exp: exp, compiled: compiled})
+	for cache.order.Len() > cache.capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).exp)
+		cache.stats.Evictions++
+	}
+}
+
+// clear 清空缓存的表达式，统计信息不变，清空的表达式不算作淘汰
+func (cache *lruCache) clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.order.Init()
+	cache.entries = make(map[string]*list.Element)
+}
+
+func (cache *lruCache) snapshot() CacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	stats := cache.stats
+	stats.Size = cache.order.Len()
+	stats.Capacity = cache.capacity
+	return stats
+}
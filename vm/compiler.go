@@ -0,0 +1,345 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"goscript/ast"
+	"goscript/function"
+	"math/bits"
+)
+
+type opcode uint8
+
+const (
+	// opConstant 常量入栈，arg 是 constants 的下标
+	opConstant opcode = iota
+	// opVariable 变量入栈，arg 是 names 的下标
+	opVariable
+	// opBinary 弹出两个参数，运算结果入栈，arg 是 operators 的下标
+	opBinary
+	// opUnary 弹出一个参数，运算结果入栈，arg 是 names 的下标
+	opUnary
+	// opSwap 交换栈顶的两个元素
+	opSwap
+	// opJump 跳转到 jump
+	opJump
+	// opJumpIfFalse 弹出三元表达式的条件，条件为 false 时跳转到 jump
+	opJumpIfFalse
+	// opShortCircuit 逻辑运算左边的值可以确定结果时，使用结果替换栈顶并且跳转到 jump，arg 是 names 的下标
+	opShortCircuit
+	// opCall 弹出参数并调用函数，arg 是 funcs 的下标
+	opCall
+	// opArray 弹出 arg 个元素组成数组
+	opArray
+	// opIndex 弹出对象和下标
+	opIndex
+	// opSliceIndex 检查栈顶的切片下标不是 nil
+	opSliceIndex
+	// opSlice 弹出对象和下标，arg 中的 sliceLow 和 sliceHigh 表示是否有对应的下标
+	opSlice
+	// opMember 成员访问，arg 是 members 的下标
+	opMember
+	// opMethod 使用栈顶对象的方法替换栈顶，arg 是 members 的下标
+	opMethod
+	// opMethodCall 弹出参数和方法并调用方法，arg 是 members 的下标
+	opMethodCall
+	// opLambda lambda 入栈，arg 是 lambdas 的下标
+	opLambda
+	// opIdentity 栈顶的值满足恒等运算时跳转到 jump，arg 是 identities 的下标
+	opIdentity
+	// opCommonScope 开始新的公共子表达式的作用域，arg 是公共子表达式的个数
+	opCommonScope
+	// opCommonLoad 公共子表达式已经计算过时结果入栈并跳转到 jump，arg 是公共子表达式的 id
+	opCommonLoad
+	// opCommonStore 保存栈顶的公共子表达式的结果，arg 是公共子表达式的 id
+	opCommonStore
+	// opFail 返回错误，arg 是 errors 的下标
+	opFail
+)
+
+const (
+	sliceLow = 1 << iota
+	sliceHigh
+)
+
+// instruction 字节码指令，note 跳转的目标是指令的下标
+type instruction struct {
+	code opcode
+	arg  int
+	jump int
+}
+
+// binaryOperator 编译时确定的二元运算符对应的函数
+type binaryOperator struct {
+	op string
+	f  func(arg1, arg2 interface{}) (interface{}, error)
+}
+
+// memberAccess 成员访问和方法调用，path 是对象在表达式中的路径，用于错误信息
+type memberAccess struct {
+	name string
+	path string
+	// argumentsNum 方法调用的参数个数
+	argumentsNum int
+}
+
+// funcCall 编译时查找的函数，argumentsNum 是调用时参数的个数，note 可变参数的函数每次调用的参数个数可能不同
+type funcCall struct {
+	f            function.Function
+	argumentsNum int
+}
+
+type lambdaCode struct {
+	params []string
+	body   *bytecode
+}
+
+// bytecode ast 编译之后的字节码，指令中引用的常量、变量名以及函数等保存在对应的表中
+//
+//	note 编译之后是只读的，所以可以被多个 goroutine 同时执行
+type bytecode struct {
+	instructions []instruction
+	constants    []interface{}
+	names        []string
+	operators    []binaryOperator
+	funcs        []funcCall
+	members      []memberAccess
+	lambdas      []lambdaCode
+	identities   []*ast.IdentityExpression
+	errors       []error
+	// maxStack 执行过程中栈的最大深度
+	maxStack int
+}
+
+// compiler 将优化之后的 ast 编译为字节码，函数和运算符在编译时查找
+//
+//	note 在执行阶段才能发现的错误(比如函数不存在)编译为 opFail，这样没有被计算的分支中的错误不会被返回
+type compiler struct {
+	vm   *VM
+	code *bytecode
+	// funcByName 编译时复制的函数，见 VM.functions
+	funcByName map[string]function.Function
+	// depth 执行到当前指令时栈的深度
+	depth int
+}
+
+func (vm *VM) compile(exp ast.Expression, funcByName map[string]function.Function) *bytecode {
+	c := &compiler{vm: vm, code: &bytecode{}, funcByName: funcByName}
+	c.compile(exp)
+	return c.code
+}
+
+func (c *compiler) emit(code opcode, arg int) int {
+	c.code.instructions = append(c.code.instructions, instruction{code: code, arg: arg})
+	c.depth += c.stackEffect(code, arg)
+	if c.depth > c.code.maxStack {
+		c.code.maxStack = c.depth
+	}
+	return len(c.code.instructions) - 1
+}
+
+// stackEffect 指令执行之后栈的深度的变化，跳转的指令按照不跳转计算
+func (c *compiler) stackEffect(code opcode, arg int) int {
+	switch code {
+	case opConstant, opVariable, opLambda:
+		return 1
+	case opBinary, opJumpIfFalse, opIndex:
+		return -1
+	case opCall:
+		return 1 - c.code.funcs[arg].argumentsNum
+	case opArray:
+		return 1 - arg
+	case opSlice:
+		return -bits.OnesCount(uint(arg))
+	case opMethodCall:
+		return -c.code.members[arg].argumentsNum
+	default:
+		return 0
+	}
+}
+
+// patch 跳转到下一条指令
+func (c *compiler) patch(index int) {
+	c.code.instructions[index].jump = len(c.code.instructions)
+}
+
+func (c *compiler) constant(value interface{}) {
+	c.code.constants = append(c.code.constants, value)
+	c.emit(opConstant, len(c.code.constants)-1)
+}
+
+func (c *compiler) name(name string) int {
+	c.code.names = append(c.code.names, name)
+	return len(c.code.names) - 1
+}
+
+func (c *compiler) member(name string, object ast.Expression, argumentsNum int) int {
+	c.code.members = append(c.code.members, memberAccess{name: name, path: memberPath(object), argumentsNum: argumentsNum})
+	return len(c.code.members) - 1
+}
+
+func (c *compiler) fail(err error) {
+	c.code.errors = append(c.code.errors, err)
+	c.emit(opFail, len(c.code.errors)-1)
+}
+
+func (c *compiler) compileAll(exps []ast.Expression) {
+	for _, exp := range exps {
+		c.compile(exp)
+	}
+}
+
+func (c *compiler) compile(exp ast.Expression) {
+	switch e := exp.(type) {
+	case *ast.EmptyExpression, *ast.NullNode:
+		c.constant(nil)
+	case *ast.NumberNode:
+		c.constant(e.GetValue())
+	case *ast.DurationNode:
+		c.constant(e.GetValue())
+	case *ast.ConstantNode:
+		c.constant(e.GetValue())
+	case *ast.StringNode:
+		c.constant(e.GetStringValue())
+	case *ast.BoolNode:
+		c.constant(e.GetValue())
+	case *ast.VariableNode:
+		c.emit(opVariable, c.name(e.GetName()))
+	case *ast.SubNode:
+		c.compile(e.SubNode())
+	case *ast.ConditionalExpression:
+		c.compileConditional(e)
+	case *ast.LambdaExpression:
+		c.code.lambdas = append(c.code.lambdas, lambdaCode{params: e.GetParams(), body: c.vm.compile(e.Body(), c.funcByName)})
+		c.emit(opLambda, len(c.code.lambdas)-1)
+	case *ast.BinaryExpression:
+		c.compileBinary(e)
+	case *ast.UnaryExpression:
+		op := e.Op()
+		c.compile(e.Exp())
+		c.emit(opUnary, c.name((&op).GetOperator()))
+	case *ast.FuncExpression:
+		f, err := findFunc(c.funcByName, e.GetFuncName(), len(e.GetArguments()))
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		c.compileAll(e.GetArguments())
+		c.code.funcs = append(c.code.funcs, funcCall{f: f, argumentsNum: len(e.GetArguments())})
+		c.emit(opCall, len(c.code.funcs)-1)
+	case *ast.ArrayExpression:
+		elements := e.GetElements()
+		c.compileAll(elements)
+		c.emit(opArray, len(elements))
+	case *ast.IndexExpression:
+		c.compile(e.Object())
+		c.compile(e.Index())
+		c.emit(opIndex, 0)
+	case *ast.SliceExpression:
+		c.compileSlice(e)
+	case *ast.MemberExpression:
+		c.compile(e.Object())
+		c.emit(opMember, c.member(e.GetName(), e.Object(), 0))
+	case *ast.MethodCallExpression:
+		arguments := e.GetArguments()
+		c.compile(e.Object())
+		method := c.member(e.GetName(), e.Object(), len(arguments))
+		c.emit(opMethod, method)
+		c.compileAll(arguments)
+		c.emit(opMethodCall, method)
+	case *ast.IdentityExpression:
+		c.compileIdentity(e)
+	case *ast.CommonScope:
+		c.emit(opCommonScope, e.Count())
+		c.compile(e.Exp())
+	case *ast.CommonExpression:
+		load := c.emit(opCommonLoad, e.GetId())
+		c.compile(e.Exp())
+		c.emit(opCommonStore, e.GetId())
+		c.patch(load)
+	default:
+		c.fail(errors.New(fmt.Sprintf("invalid expression type %T", exp)))
+	}
+}
+
+// compileBinary 左结合的运算依次计算，右结合的运算先计算所有的参数，再从右边开始计算，和 calBinary 相同
+func (c *compiler) compileBinary(exp *ast.BinaryExpression) {
+	arguments := exp.GetArguments()
+	c.compile(exp.Left())
+
+	if exp.Associativity() == ast.RightAssociativity {
+		for _, argument := range arguments {
+			c.compile(argument.GetArg())
+		}
+		for i := len(arguments) - 1; i >= 0; i-- {
+			c.binary(arguments[i].GetOperator())
+		}
+		return
+	}
+
+	// note 同一个二元表达式中只有 && 或者只有 ||，短路时整个二元表达式的结果已经确定
+	var shortCircuits []int
+	for _, argument := range arguments {
+		op := argument.GetOperator()
+		if function.IsLogicalOperator((&op).GetOperator()) {
+			shortCircuits = append(shortCircuits, c.emit(opShortCircuit, c.name((&op).GetOperator())))
+		}
+		c.compile(argument.GetArg())
+		c.binary(op)
+	}
+	for _, index := range shortCircuits {
+		c.patch(index)
+	}
+}
+
+func (c *compiler) binary(op ast.OperatorNode) {
+	f, ok := c.vm.arithmetic.BinaryOperator(op.GetOperator())
+	if !ok {
+		c.fail(errors.New("invalid operator:" + op.GetOperator()))
+		return
+	}
+	c.code.operators = append(c.code.operators, binaryOperator{op: op.GetOperator(), f: f})
+	c.emit(opBinary, len(c.code.operators)-1)
+}
+
+func (c *compiler) compileConditional(exp *ast.ConditionalExpression) {
+	c.compile(exp.Condition())
+	otherwise := c.emit(opJumpIfFalse, 0)
+	depth := c.depth
+	c.compile(exp.Then())
+	end := c.emit(opJump, 0)
+	c.patch(otherwise)
+	// note 只会执行其中一个分支
+	c.depth = depth
+	c.compile(exp.Else())
+	c.patch(end)
+}
+
+func (c *compiler) compileSlice(exp *ast.SliceExpression) {
+	c.compile(exp.Object())
+	flags := 0
+	if exp.Low() != nil {
+		c.compile(exp.Low())
+		c.emit(opSliceIndex, 0)
+		flags |= sliceLow
+	}
+	if exp.High() != nil {
+		c.compile(exp.High())
+		c.emit(opSliceIndex, 0)
+		flags |= sliceHigh
+	}
+	c.emit(opSlice, flags)
+}
+
+// compileIdentity x 的值不满足恒等运算时按照原来的运算计算，见 calIdentity
+func (c *compiler) compileIdentity(exp *ast.IdentityExpression) {
+	c.compile(exp.Exp())
+	c.code.identities = append(c.code.identities, exp)
+	identity := c.emit(opIdentity, len(c.code.identities)-1)
+	c.compile(exp.Operand())
+	if exp.OperandFirst() {
+		c.emit(opSwap, 0)
+	}
+	c.binary(exp.Op())
+	c.patch(identity)
+}
@@ -0,0 +1,126 @@
+package vm
+
+import (
+	"fmt"
+	"goscript/function"
+	"reflect"
+)
+
+// Env 表达式计算时获取变量的环境
+//
+//	Lookup 返回的 bool 表示变量是否存在，note 不存在的变量在表达式中的值为 nil
+type Env interface {
+	Lookup(name string) (interface{}, bool)
+}
+
+// MapEnv 使用 map 作为变量环境
+type MapEnv map[string]interface{}
+
+func (env MapEnv) Lookup(name string) (interface{}, bool) {
+	value, ok := env[name]
+	return value, ok
+}
+
+// EnvFunc 使用函数作为变量环境，可以用于延迟加载的数据源
+type EnvFunc func(name string) (interface{}, bool)
+
+func (f EnvFunc) Lookup(name string) (interface{}, bool) {
+	return f(name)
+}
+
+// StructEnv 使用结构体(或者 key 为字符串的任意 map)作为变量环境，字段的访问规则和成员访问 a.b 相同
+type StructEnv struct {
+	object interface{}
+}
+
+// NewStructEnv object 可以是结构体、key 为字符串的 map 以及指向它们的指针
+func NewStructEnv(object interface{}) (*StructEnv, error) {
+	value := reflect.ValueOf(object)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil, fmt.Errorf("env should not be nil %T", object)
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() == reflect.Struct ||
+		(value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String) {
+		return &StructEnv{object: object}, nil
+	}
+
+	return nil, fmt.Errorf("invalid env type %T, expected struct or map with string key", object)
+}
+
+func (env *StructEnv) Lookup(name string) (interface{}, bool) {
+	value, err := function.Member(env.object, name)
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// ChainEnv 多层作用域，按照顺序查找变量，前边的作用域优先
+type ChainEnv []Env
+
+// NewChainEnv 比如 NewChainEnv(requestEnv, globalEnv)，requestEnv 中的变量会覆盖 globalEnv 中的同名变量
+func NewChainEnv(envs ...Env) ChainEnv {
+	return envs
+}
+
+func (chain ChainEnv) Lookup(name string) (interface{}, bool) {
+	for _, env := range chain {
+		if env == nil {
+			continue
+		}
+		if value, ok := env.Lookup(name); ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// emptyEnv 没有任何变量的环境
+var emptyEnv = MapEnv{}
+
+// toEnv 将用户传入的 env 转换为 Env
+//
+//	支持 Env、map[string]interface{}、结构体以及 key 为字符串的 map，nil 表示没有变量
+func toEnv(env interface{}) (Env, error) {
+	switch e := env.(type) {
+	case nil:
+		return emptyEnv, nil
+	case Env:
+		return e, nil
+	case map[string]interface{}:
+		return MapEnv(e), nil
+	default:
+		return NewStructEnv(env)
+	}
+}
+
+// commonEnv 保存一次计算中公共子表达式的结果，见 ast.CommonScope
+//
+//	note lambda 的 body 中没有公共子表达式，所以 lambda 使用的 ChainEnv 不需要查找 commonEnv
+type commonEnv struct {
+	Env
+	values    []interface{}
+	evaluated []bool
+}
+
+func newCommonEnv(env Env, count int) *commonEnv {
+	return &commonEnv{Env: env, values: make([]interface{}, count), evaluated: make([]bool, count)}
+}
+
+func (env *commonEnv) load(id int) (interface{}, bool) {
+	if id < 0 || id >= len(env.values) || !env.evaluated[id] {
+		return nil, false
+	}
+	return env.values[id], true
+}
+
+func (env *commonEnv) store(id int, value interface{}) {
+	if id < 0 || id >= len(env.values) {
+		return
+	}
+	env.values[id], env.evaluated[id] = value, true
+}
@@ -0,0 +1,37 @@
+package vm
+
+import (
+	"errors"
+	"goscript/function"
+)
+
+// EvalError 表达式执行阶段的运算错误，比如除零、整数溢出，包括函数中的运算错误，比如 pow(0, -1)
+//
+//	可以通过 errors.Is(err, function.ErrDivisionByZero) 判断具体的错误
+type EvalError struct {
+	// Operator 出错的运算符，比如 "/"、取负时是 "-"，函数中的错误是函数名
+	Operator string
+	// Operands 运算符或者函数的参数，一元运算符只有一个参数
+	Operands []interface{}
+	Err      error
+}
+
+func (e *EvalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// newEvalError 只包装除零和溢出错误，其他错误原样返回，err 为 nil 时返回 nil
+func newEvalError(operator string, err error, operands ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, function.ErrDivisionByZero) || errors.Is(err, function.ErrIntegerOverflow) {
+		return &EvalError{Operator: operator, Operands: operands, Err: err}
+	}
+	return err
+}
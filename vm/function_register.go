@@ -2,51 +2,117 @@ package vm
 
 import (
 	"errors"
+	"fmt"
 	"goscript/function"
+	"reflect"
 )
 
 func (vm *VM) RemoveFunc(name string) {
-	if vm == nil || len(vm.expressionCache) == 0 {
+	if vm == nil {
+		return
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if len(vm.funcByName) == 0 {
 		return
 	}
 
-	delete(vm.expressionCache, name)
+	delete(vm.funcByName, name)
+	delete(vm.builtinFuncs, name)
+	vm.clearExpressionCache()
 }
 
 func (vm *VM) RegisterFunc0(name string, allowFold bool, f func() (interface{}, error)) error {
-	return vm.registerFuncN(name, allowFold, 0, f)
+	if f == nil {
+		return errors.New("function should not be nil")
+	}
+
+	return vm.registerFuncN(name, allowFold, 0, f, func(args []interface{}) (interface{}, error) {
+		return f()
+	})
 }
 
 func (vm *VM) RegisterFunc1(name string, allowFold bool, f func(arg Value) (interface{}, error)) error {
-	return vm.registerFuncN(name, allowFold, 1, f)
+	if f == nil {
+		return errors.New("function should not be nil")
+	}
+
+	return vm.registerFuncN(name, allowFold, 1, f, func(args []interface{}) (interface{}, error) {
+		return f(Value{args[0]})
+	})
 }
 
 func (vm *VM) RegisterFunc2(name string, allowFold bool, f func(arg1, arg2 Value) (interface{}, error)) error {
-	return vm.registerFuncN(name, allowFold, 2, f)
+	if f == nil {
+		return errors.New("function should not be nil")
+	}
+
+	return vm.registerFuncN(name, allowFold, 2, f, func(args []interface{}) (interface{}, error) {
+		return f(Value{args[0]}, Value{args[1]})
+	})
 }
 
 func (vm *VM) RegisterFunc3(name string, allowFold bool, f func(arg1, arg2, arg3 Value) (interface{}, error)) error {
-	return vm.registerFuncN(name, allowFold, 3, f)
+	if f == nil {
+		return errors.New("function should not be nil")
+	}
+
+	return vm.registerFuncN(name, allowFold, 3, f, func(args []interface{}) (interface{}, error) {
+		return f(Value{args[0]}, Value{args[1]}, Value{args[2]})
+	})
 }
 
-func (vm *VM) registerFuncN(name string, allowFold bool, i int, f interface{}) error {
+// RegisterFunc 通过反射注册任意签名的 go 函数，比如 func(int64, string) bool、func(...float64) (float64, error)
+//
+//	参数会从表达式中的值转换为对应的类型，转换失败时返回错误；参数类型为 Value 时不做转换
+//	返回值可以是 无、T、error 或者 (T, error)
+func (vm *VM) RegisterFunc(name string, allowFold bool, f interface{}) error {
 	if f == nil {
 		return errors.New("function should not be nil")
 	}
 
+	fn := reflect.ValueOf(f)
+	if fn.Kind() != reflect.Func {
+		return fmt.Errorf("%s should be a function instead of %T", name, f)
+	}
+	if fn.IsNil() {
+		return errors.New("function should not be nil")
+	}
+
+	if err := checkReturnTypes(fn.Type()); err != nil {
+		return err
+	}
+
+	argumentsNum := fn.Type().NumIn()
+	if fn.Type().IsVariadic() {
+		argumentsNum = -1
+	}
+
+	return vm.registerFuncN(name, allowFold, argumentsNum, f, func(args []interface{}) (interface{}, error) {
+		return callReflect(name, fn, args)
+	})
+}
+
+func (vm *VM) registerFuncN(name string, allowFold bool, i int, f interface{}, invoker function.Invoker) error {
 	if vm == nil {
 		return errors.New("vm is nil ptr")
 	}
 
-	if vm.funcByName == nil{
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.funcByName == nil {
 		vm.funcByName = make(map[string]function.Function)
 	}
 
-	if _, ok := vm.funcByName[name]; ok {
+	// note 内置函数可以被覆盖，这样新增内置函数不会导致用户已有的注册失败
+	if _, ok := vm.funcByName[name]; ok && !vm.builtinFuncs[name] {
 		return errors.New("already register function named " + name)
 	}
 
-	vm.funcByName[name] = function.NewFunction(name, f, i, allowFold)
+	vm.funcByName[name] = function.NewFunction(name, f, i, allowFold, invoker)
+	delete(vm.builtinFuncs, name)
+	vm.clearExpressionCache()
 
 	return nil
 }
@@ -0,0 +1,109 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"goscript/ast"
+	"reflect"
+)
+
+// AllowMethods 限制表达式中可以调用的方法
+//
+//	名称可以是 "FullName" (任意类型的 FullName 方法) 或者带包路径的 "example.com/model.User.FullName"
+//	(example.com/model 包中 User 或 *User 的 FullName 方法)，note 类型名必须带包路径，不同包中的同名类型不会混淆
+//
+//	note 默认不限制：没有调用过 AllowMethods 时，表达式可以调用 env 中对象的所有导出方法(包括有副作用的方法)，
+//		 表达式来自不可信的输入时应该先调用 AllowMethods，调用之后只能调用列表中的方法
+func (vm *VM) AllowMethods(names ...string) {
+	if vm == nil {
+		return
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.allowedMethods == nil {
+		vm.allowedMethods = make(map[string]bool)
+	}
+	for _, name := range names {
+		vm.allowedMethods[name] = true
+	}
+}
+
+func (vm *VM) isMethodAllowed(receiverType reflect.Type, name string) bool {
+	vm.mu.RLock()
+	defer vm.mu.RUnlock()
+	if vm.allowedMethods == nil {
+		return true
+	}
+
+	for receiverType.Kind() == reflect.Ptr {
+		receiverType = receiverType.Elem()
+	}
+	if vm.allowedMethods[name] {
+		return true
+	}
+	// note 匿名类型(比如 map[string]int)没有类型名，只能使用不带类型的方法名
+	if receiverType.Name() == "" {
+		return false
+	}
+	return vm.allowedMethods[receiverType.PkgPath()+"."+receiverType.Name()+"."+name]
+}
+
+// calMethodCall 通过反射调用 env 中对象的导出方法
+func (vm *VM) calMethodCall(exp ast.MethodCallExpression, env Env) (interface{}, error) {
+	object, err := vm.cal(exp.Object(), env)
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := vm.lookupMethod(object, exp.GetName(), memberPath(exp.Object()))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, 0)
+	for _, argNode := range exp.GetArguments() {
+		rawValue, err := vm.cal(argNode, env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, rawValue)
+	}
+
+	return callReflect(exp.GetName(), method, args)
+}
+
+// lookupMethod 查找允许调用的方法，path 是 object 在表达式中的路径，用于错误信息
+func (vm *VM) lookupMethod(object interface{}, name string, path string) (reflect.Value, error) {
+	if object == nil {
+		return reflect.Value{}, fmt.Errorf("can not call method '%s' of nil '%s'", name, path)
+	}
+
+	method, err := methodByName(object, name)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("can not call '%s' of '%s': %v", name, path, err)
+	}
+
+	if !vm.isMethodAllowed(reflect.TypeOf(object), name) {
+		return reflect.Value{}, fmt.Errorf("method '%s' of %T is not allowed", name, object)
+	}
+	return method, nil
+}
+
+// methodByName 查找导出方法，note 值类型的对象也可以调用指针接收者的方法
+func methodByName(object interface{}, name string) (reflect.Value, error) {
+	value := reflect.ValueOf(object)
+	if method := value.MethodByName(name); method.IsValid() {
+		return method, nil
+	}
+
+	if value.Kind() != reflect.Ptr && value.Kind() != reflect.Interface {
+		ptr := reflect.New(value.Type())
+		ptr.Elem().Set(value)
+		if method := ptr.MethodByName(name); method.IsValid() {
+			return method, nil
+		}
+	}
+
+	return reflect.Value{}, errors.New(fmt.Sprintf("%T has no exported method named '%s'", object, name))
+}
@@ -0,0 +1,104 @@
+package vm
+
+import (
+	"goscript/ast"
+	"goscript/function"
+)
+
+// Program 编译之后的表达式，可以重复执行，执行时不需要查找表达式的缓存
+//
+//	note Program 创建之后是只读的，所以可以被多个 goroutine 同时执行，
+//		 编译时会复制 vm 的函数和允许调用的方法，之后 vm 的 RegisterFunc、RemoveFunc 以及 AllowMethods 不会影响已经编译的 Program，
+//		 常量折叠只保留不可变的值，所以每次 Run 返回的数组等结果都是新创建的，修改结果不会影响其他的 Run，
+//		 但是 env 中的对象会直接返回，比如表达式只有一个变量时
+type Program struct {
+	source   string
+	vm       *VM
+	compiled *compiledExpression
+	// variables 和 functions 是优化之前的表达式中使用的变量和函数，见 ast.GetVariable 和 ast.GetFuncNames
+	variables []string
+	functions []string
+}
+
+// Compile 使用默认的 vm 编译表达式
+func Compile(src string) (*Program, error) {
+	return defaultVM.Compile(src)
+}
+
+// Compile 解析和优化表达式，编译的方式和运算规则与 Eval 相同，函数不存在等执行阶段的错误在 Run 时返回
+func (vm *VM) Compile(src string) (*Program, error) {
+	expression, err := ast.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// note machine 只有当前的 Program 使用，所以可以直接使用 machine 的函数编译
+	machine := vm.snapshot()
+	compiled, err := machine.compileExpression(expression, machine.funcByName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Program{
+		source:    src,
+		vm:        machine,
+		compiled:  compiled,
+		variables: ast.GetVariable(expression),
+		functions: ast.GetFuncNames(expression),
+	}, nil
+}
+
+// snapshot 复制 vm 的函数和允许调用的方法，不包括表达式的缓存
+func (vm *VM) snapshot() *VM {
+	vm.mu.RLock()
+	defer vm.mu.RUnlock()
+
+	copied := &VM{
+		funcByName:     make(map[string]function.Function, len(vm.funcByName)),
+		builtinFuncs:   make(map[string]bool, len(vm.builtinFuncs)),
+		arithmetic:     vm.arithmetic,
+		optimizeConfig: vm.optimizeConfig,
+		backend:        vm.backend,
+	}
+	for name, f := range vm.funcByName {
+		copied.funcByName[name] = f
+	}
+	for name := range vm.builtinFuncs {
+		copied.builtinFuncs[name] = true
+	}
+	if vm.allowedMethods != nil {
+		copied.allowedMethods = make(map[string]bool, len(vm.allowedMethods))
+		for name := range vm.allowedMethods {
+			copied.allowedMethods[name] = true
+		}
+	}
+	return copied
+}
+
+// Run env 和 VM.Eval 相同
+func (program *Program) Run(env interface{}) (*Value, error) {
+	runEnv, err := toEnv(env)
+	if err != nil {
+		return nil, err
+	}
+	return program.vm.run(program.compiled, runEnv)
+}
+
+// Source 编译的表达式
+func (program *Program) Source() string {
+	return program.source
+}
+
+// Variables 表达式中使用的变量，按照第一次出现的顺序，不包括 lambda 的参数
+func (program *Program) Variables() []string {
+	variables := make([]string, len(program.variables))
+	copy(variables, program.variables)
+	return variables
+}
+
+// Functions 表达式中调用的函数，按照第一次出现的顺序，不包括方法调用
+func (program *Program) Functions() []string {
+	functions := make([]string, len(program.functions))
+	copy(functions, program.functions)
+	return functions
+}
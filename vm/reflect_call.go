@@ -0,0 +1,161 @@
+package vm
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	valueType = reflect.TypeOf(Value{})
+)
+
+// checkReturnTypes 支持的返回值: 无返回值、T、error、(T, error)
+func checkReturnTypes(funcType reflect.Type) error {
+	switch funcType.NumOut() {
+	case 0, 1:
+		return nil
+	case 2:
+		if funcType.Out(1) != errorType {
+			return fmt.Errorf("the second return value of %s should be error", funcType)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s has too many return values, expected T or (T, error)", funcType)
+	}
+}
+
+// callReflect 通过反射调用函数或者方法，args 会被转换为对应的参数类型
+func callReflect(name string, fn reflect.Value, args []interface{}) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("call '%s' panic: %v", name, r)
+		}
+	}()
+
+	funcType := fn.Type()
+	if err := checkReturnTypes(funcType); err != nil {
+		return nil, err
+	}
+
+	in, err := convertArguments(name, funcType, args)
+	if err != nil {
+		return nil, err
+	}
+
+	out := fn.Call(in)
+	switch len(out) {
+	case 0:
+		return nil, nil
+	case 1:
+		if funcType.Out(0) == errorType {
+			if out[0].IsNil() {
+				return nil, nil
+			}
+			return nil, out[0].Interface().(error)
+		}
+		return out[0].Interface(), nil
+	default:
+		if !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+		return out[0].Interface(), nil
+	}
+}
+
+func convertArguments(name string, funcType reflect.Type, args []interface{}) ([]reflect.Value, error) {
+	numIn := funcType.NumIn()
+	if funcType.IsVariadic() {
+		if len(args) < numIn-1 {
+			return nil, fmt.Errorf("'%s' require at least %d arguments instead of %d", name, numIn-1, len(args))
+		}
+	} else if len(args) != numIn {
+		return nil, fmt.Errorf("'%s' require %d arguments instead of %d", name, numIn, len(args))
+	}
+
+	in := make([]reflect.Value, 0, len(args))
+	for i, arg := range args {
+		var argType reflect.Type
+		if funcType.IsVariadic() && i >= numIn-1 {
+			argType = funcType.In(numIn - 1).Elem()
+		} else {
+			argType = funcType.In(i)
+		}
+
+		value, err := convertArgument(arg, argType)
+		if err != nil {
+			return nil, ArgumentError(name, i+1, err)
+		}
+		in = append(in, value)
+	}
+
+	return in, nil
+}
+
+// convertArgument 将表达式中的值转换为 go 函数参数的类型
+//
+//	数字之间可以转换，但是整数参数不接受非整数的小数，也会检查是否溢出
+//	[]interface{}(数组字面量) 可以转换为任意类型的 slice
+func convertArgument(arg interface{}, argType reflect.Type) (reflect.Value, error) {
+	if argType == valueType {
+		return reflect.ValueOf(Value{rawValue: arg}), nil
+	}
+
+	if arg == nil {
+		switch argType.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+			return reflect.Zero(argType), nil
+		default:
+			return reflect.Value{}, fmt.Errorf("can not use nil as %s", argType)
+		}
+	}
+
+	value := reflect.ValueOf(arg)
+	if value.Type().AssignableTo(argType) {
+		return value, nil
+	}
+
+	switch argType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := ArgInteger(arg, argType.Bits(), argType.String())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(i).Convert(argType), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := ArgUnsigned(arg, argType.Bits(), argType.String())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(i).Convert(argType), nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := ArgFloat(arg, argType.String())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(f).Convert(argType), nil
+
+	case reflect.Slice:
+		if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+			break
+		}
+		slice := reflect.MakeSlice(argType, value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			element, err := convertArgument(value.Index(i).Interface(), argType.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %v", i, err)
+			}
+			slice.Index(i).Set(element)
+		}
+		return slice, nil
+	}
+
+	// 底层类型相同的自定义类型，比如 type Level string
+	if value.Kind() == argType.Kind() && value.Type().ConvertibleTo(argType) {
+		return value.Convert(argType), nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("can not use %v (%T) as %s", arg, arg, argType)
+}
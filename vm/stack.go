@@ -8,6 +8,11 @@ func NewStack() *Stack {
 	return &Stack{value: make([]interface{}, 0)}
 }
 
+// newStackWithCapacity 预先分配空间，避免字节码执行的过程中扩容
+func newStackWithCapacity(capacity int) *Stack {
+	return &Stack{value: make([]interface{}, 0, capacity)}
+}
+
 func (stack *Stack) push(ele interface{}) {
 	stack.value = append(stack.value, ele)
 }
@@ -21,3 +26,28 @@ func (stack *Stack) pop() interface{} {
 	stack.value = stack.value[:len(stack.value)-1] // 弹出栈顶元素
 	return top
 }
+
+// peek 获取栈顶元素但是不弹出
+func (stack *Stack) peek() interface{} {
+	if stack == nil || len(stack.value) == 0 {
+		return nil
+	}
+	return stack.value[len(stack.value)-1]
+}
+
+// popN 弹出栈顶的 n 个元素，按照入栈的顺序返回
+//
+//	note 返回的是拷贝，因为函数的参数可能被函数保存下来
+func (stack *Stack) popN(n int) []interface{} {
+	if stack == nil || n <= 0 {
+		return []interface{}{}
+	}
+	if n > len(stack.value) {
+		n = len(stack.value)
+	}
+
+	elements := make([]interface{}, n)
+	copy(elements, stack.value[len(stack.value)-n:])
+	stack.value = stack.value[:len(stack.value)-n]
+	return elements
+}
@@ -2,7 +2,8 @@ package vm
 
 import (
 	"fmt"
-	"strconv"
+	"goscript/function"
+	"time"
 )
 
 type Value struct {
@@ -14,41 +15,98 @@ func (value Value) RawValue() (result interface{}) {
 	return value.rawValue
 }
 
+// AsInt 和 AsInt64 相同，超出 int 的范围时返回错误
 func (value Value) AsInt() (result int, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("%+v", r)
-		}
-	}()
-
-	int64Value, err := strconv.ParseInt(fmt.Sprintf("%d", value.rawValue), 10, 64)
+	int64Value, err := value.AsInt64()
 	if err != nil {
 		return 0, err
 	}
+	if int64(int(int64Value)) != int64Value {
+		return 0, fmt.Errorf("%d 超出了 int 的范围", int64Value)
+	}
 	return int(int64Value), nil
 }
 
+// AsInt64 各种类型的整数都可以转换，包括 decimal 模式下没有小数部分的 function.Decimal，比如 3 和 2.00
+//
+//	小数、nil 以及其他类型返回错误，见 function.Int64
 func (value Value) AsInt64() (result int64, err error) {
-	defer func() {
-		if r := recover();r!=nil{
-			err = fmt.Errorf("%+v", r)
-		}
-	}()
+	if value.rawValue == nil {
+		return 0, fmt.Errorf("can not convert nil to int64")
+	}
+
+	return function.Int64(value.rawValue)
+}
+
+// AsFloat64 整数会被转换为 float64
+func (value Value) AsFloat64() (float64, error) {
+	return function.Float64(value.rawValue)
+}
+
+// AsBool 只有 bool 类型的值可以转换为 bool，其他类型返回错误
+func (value Value) AsBool() (bool, error) {
+	if b, ok := value.rawValue.(bool); ok {
+		return b, nil
+	}
 
-	return strconv.ParseInt(fmt.Sprintf("%d", value.rawValue), 10, 64)
+	return false, fmt.Errorf("can not convert %T to bool", value.rawValue)
 }
 
+// AsTime 只有 time.Time 类型的值可以转换为 time.Time
+func (value Value) AsTime() (time.Time, error) {
+	if t, ok := value.rawValue.(time.Time); ok {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("can not convert %T to time.Time", value.rawValue)
+}
+
+// AsDuration 只有 time.Duration 类型的值可以转换为 time.Duration
+func (value Value) AsDuration() (time.Duration, error) {
+	if d, ok := value.rawValue.(time.Duration); ok {
+		return d, nil
+	}
+
+	return 0, fmt.Errorf("can not convert %T to time.Duration", value.rawValue)
+}
+
+// IsNil 结果是否为 nil，包括值为 nil 的指针、map、slice 等
+func (value Value) IsNil() bool {
+	return function.IsNil(value.rawValue)
+}
+
+// AsDecimalString 数字按照十进制小数格式化，不使用科学计数法，比如 decimal 模式下 0.1 + 0.2 的结果是 "0.3"
+//
+//	*big.Int、*big.Rat 和数字字符串也可以转换，*big.Rat 保留 function.DefaultDecimalScale 位小数
+func (value Value) AsDecimalString() (string, error) {
+	if value.rawValue == nil {
+		return "", fmt.Errorf("can not convert nil to decimal")
+	}
+
+	d, err := function.ToDecimal(value.rawValue)
+	if err != nil {
+		return "", err
+	}
+	return d.String(), nil
+}
+
+// AsString 字符串直接返回，数字按照字符串拼接的规则转换，其他类型使用 %v 格式化
 func (value Value) AsString() (result string, err error) {
 	defer func() {
-		r := recover()
-		err = fmt.Errorf("%+v", r)
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%+v", r)
+		}
 	}()
 
 	if str, ok := value.rawValue.(string); ok {
 		return str, nil
 	}
 
-	return fmt.Sprintf("%s", value.rawValue), nil
+	if function.IsNumber(value.rawValue) {
+		return function.FormatNumber(value.rawValue)
+	}
+
+	return fmt.Sprintf("%v", value.rawValue), nil
 }
 
 
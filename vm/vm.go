@@ -4,35 +4,88 @@ import (
 	"errors"
 	"fmt"
 	"goscript/ast"
+	"goscript/config"
 	"goscript/function"
+	"sync"
 )
 
-var defaultVM = &VM{
-	expressionCache: make(map[string]ast.Expression),
-	funcByName:      make(map[string]function.Function),
-}
+var defaultVM = NewVM()
+
+var errNilSliceIndex = errors.New("invalid slice index: nil is not an integer")
 
-// Eval 用户大多数情况使用的还是默认的 vm
-func Eval(exp string, env map[string]interface{}) (*Value, error) {
+// Eval 用户大多数情况使用的还是默认的 vm，可以被多个 goroutine 同时调用
+func Eval(exp string, env interface{}) (*Value, error) {
 	return defaultVM.Eval(exp, env)
 }
 
-func NewVM() *VM {
-	return &VM{
-		expressionCache: make(map[string]ast.Expression),
-		funcByName:      make(map[string]function.Function),
+// NewVM 默认会注册内置函数，可以通过 config.WithoutBuiltins 关闭
+func NewVM(opts ...config.Option) *VM {
+	vm := &VM{
+		funcByName:   make(map[string]function.Function),
+		builtinFuncs: make(map[string]bool),
+	}
+
+	vmConfig := config.NewConfig(opts...)
+	vm.expressionCache = newLRUCache(vmConfig.CacheSize())
+	vm.arithmetic = vmConfig.Arithmetic()
+	vm.optimizeConfig = vmConfig.OptimizeConfig()
+	vm.optimizeConfig.Arithmetic = vm.arithmetic
+	vm.backend = vmConfig.Backend()
+	if !vmConfig.WithoutBuiltins() {
+		builtins := vm.arithmetic.Builtins()
+		if clock := vmConfig.Clock(); clock != nil {
+			builtins = append(builtins, function.NowFunction(clock))
+		}
+
+		// note 同名函数后边的覆盖前边的，比如使用 config.WithClock 时的 now
+		for _, f := range builtins {
+			vm.funcByName[f.Name()] = f
+			vm.builtinFuncs[f.Name()] = true
+		}
 	}
+
+	return vm
 }
 
+// VM 可以被多个 goroutine 同时使用，包括同时执行表达式和注册函数
+//
+//	note mu 保护 funcByName、builtinFuncs、allowedMethods 和 generation，执行用户的函数时不持有锁，
+//		 只在复制、查找函数和方法时短暂的加读锁，这样用户的函数中也可以注册函数或者执行表达式
 type VM struct {
-	expressionCache map[string]ast.Expression
+	mu sync.RWMutex
+	// generation 注册或者删除函数的次数，用于判断编译之后函数是否发生了变化
+	generation uint64
+	// expressionCache 有自己的锁，见 lruCache
+	expressionCache *lruCache
 	funcByName      map[string]function.Function
+	// builtinFuncs funcByName 中的内置函数，用户注册同名函数时会覆盖内置函数
+	builtinFuncs map[string]bool
+	// allowedMethods 允许调用的方法，nil 表示不限制，见 AllowMethods
+	allowedMethods map[string]bool
+	// arithmetic 运算符和常量折叠使用的运算规则，见 config.WithOverflowMode 和 config.WithDecimal
+	arithmetic *function.Arithmetic
+	// optimizeConfig 见 config.WithOptimizeConfig
+	optimizeConfig ast.OptimizeConfig
+	// backend 见 config.WithBackend
+	backend config.Backend
+}
+
+// compiledExpression 缓存的表达式，bytecode 只有在使用 config.BytecodeBackend 时才会生成
+type compiledExpression struct {
+	expression ast.Expression
+	bytecode   *bytecode
 }
 
-func (vm *VM) Eval(exp string, env map[string]interface{}) (*Value, error) {
+// Eval env 可以是 Env、map[string]interface{}、结构体以及 key 为字符串的 map，见 toEnv
+func (vm *VM) Eval(exp string, env interface{}) (*Value, error) {
+	evalEnv, err := toEnv(env)
+	if err != nil {
+		return nil, err
+	}
+
 	cachedExp := vm.getExpressionFromCache(exp)
 	if cachedExp != nil {
-		return vm.calInternal(cachedExp, env)
+		return vm.run(cachedExp, evalEnv)
 	}
 
 	expression, err := ast.Parse(exp)
@@ -40,16 +93,58 @@ func (vm *VM) Eval(exp string, env map[string]interface{}) (*Value, error) {
 		return nil, err
 	}
 
-	expression, err = ast.Optimize(expression, vm.funcByName)
+	// note 常量折叠会调用用户的函数，所以使用函数的副本编译，编译时不持有锁
+	funcByName, generation := vm.functions()
+	compiled, err := vm.compileExpression(expression, funcByName)
+	if err != nil {
+		return nil, err
+	}
+	vm.setExpressionCache(exp, compiled, generation)
+
+	return vm.run(compiled, evalEnv)
+}
+
+// functions 复制 funcByName，generation 是复制时的版本，见 setExpressionCache
+func (vm *VM) functions() (map[string]function.Function, uint64) {
+	vm.mu.RLock()
+	defer vm.mu.RUnlock()
+
+	funcByName := make(map[string]function.Function, len(vm.funcByName))
+	for name, f := range vm.funcByName {
+		funcByName[name] = f
+	}
+	return funcByName, vm.generation
+}
+
+// compileExpression 使用 funcByName 优化解析之后的 ast，使用 config.BytecodeBackend 时再编译为字节码
+func (vm *VM) compileExpression(expression ast.Expression,
+	funcByName map[string]function.Function) (*compiledExpression, error) {
+	expression, err := ast.OptimizeWithConfig(expression, funcByName, vm.optimizeConfig)
 	if err != nil {
 		return nil, fmt.Errorf("occur error when optimize expression:%v", err)
 	}
-	vm.setExpressionCache(exp, expression)
 
-	return vm.calInternal(expression, env)
+	compiled := &compiledExpression{expression: expression}
+	if vm.backend == config.BytecodeBackend {
+		compiled.bytecode = vm.compile(expression, funcByName)
+	}
+	return compiled, nil
 }
 
-func (vm *VM) calInternal(exp ast.Expression, env map[string]interface{}) (*Value, error) {
+// run 使用编译时选择的方式执行表达式
+func (vm *VM) run(compiled *compiledExpression, env Env) (*Value, error) {
+	if compiled.bytecode == nil {
+		return vm.calInternal(compiled.expression, env)
+	}
+
+	rawValue, err := vm.execute(compiled.bytecode, env)
+	if err != nil {
+		return nil, err
+	}
+	return &Value{rawValue: rawValue}, nil
+}
+
+func (vm *VM) calInternal(exp ast.Expression, env Env) (*Value, error) {
 	rawValue, err := vm.cal(exp, env)
 	if err != nil {
 		return nil, err
@@ -58,86 +153,112 @@ func (vm *VM) calInternal(exp ast.Expression, env map[string]interface{}) (*Valu
 	return &Value{rawValue: rawValue}, nil
 }
 
-func (vm *VM) cal(exp ast.Expression, env map[string]interface{}) (interface{}, error) {
+func (vm *VM) cal(exp ast.Expression, env Env) (interface{}, error) {
 	switch expression := exp.(type) {
 	case *ast.EmptyExpression:
 		return nil, nil
 	case *ast.NumberNode:
-		return expression.Value, nil
+		return expression.GetValue(), nil
+	case *ast.DurationNode:
+		return expression.GetValue(), nil
+	case *ast.ConstantNode:
+		return expression.GetValue(), nil
 	case *ast.StringNode:
 		return expression.GetStringValue(), nil
+	case *ast.BoolNode:
+		return expression.GetValue(), nil
+	case *ast.NullNode:
+		return nil, nil
 	case *ast.VariableNode:
 		return vm.calVariable(expression.GetName(), env)
+	case *ast.ConditionalExpression:
+		return vm.calConditional(*expression, env)
+	case *ast.LambdaExpression:
+		return vm.calLambda(*expression, env), nil
 	case *ast.BinaryExpression:
 		return vm.calBinary(*expression, env)
 	case *ast.UnaryExpression:
 		return vm.calUnary(*expression, env)
 	case *ast.FuncExpression:
 		return vm.calFuncExpression(*expression, env)
+	case *ast.ArrayExpression:
+		return vm.calArray(*expression, env)
+	case *ast.IndexExpression:
+		return vm.calIndex(*expression, env)
+	case *ast.SliceExpression:
+		return vm.calSlice(*expression, env)
+	case *ast.MemberExpression:
+		return vm.calMember(*expression, env)
+	case *ast.MethodCallExpression:
+		return vm.calMethodCall(*expression, env)
 	case *ast.SubNode:
 		return vm.cal(expression.SubNode(), env)
+	case *ast.IdentityExpression:
+		return vm.calIdentity(*expression, env)
+	case *ast.CommonScope:
+		return vm.cal(expression.Exp(), newCommonEnv(env, expression.Count()))
+	case *ast.CommonExpression:
+		return vm.calCommon(*expression, env)
 	default:
 		return nil, errors.New(fmt.Sprintf("invalid expression type %T", expression))
 	}
 }
 
-func (vm *VM) calFuncExpression(expression ast.FuncExpression, env map[string]interface{}) (interface{}, error) {
-	// note 编译的时候就应该判断一下有没有udf
-
-	var f function.Function
-	if val, ok := vm.funcByName[expression.GetFuncName()]; !ok {
-		return nil, errors.New(fmt.Sprintf("invalid udf named '%s'", expression.GetFuncName()))
-	} else {
-		f = val
-	}
-
-	// note 应该编译的时候就发现这个问题，至少应该打印个warn日志（万一用户只想编译做分析、不想执行？）
-	// note 或者编译的时候可以让用户可选的忽略udf的是否存在的检查？
-	if f.ArgumentsNum() != -1 && f.ArgumentsNum() != len(expression.GetArguments()) {
-		return nil, errors.New(fmt.Sprintf("the func of '%s' require %d argument instead of %d",
-			f.Name(), f.ArgumentsNum(), len(expression.GetArguments())))
+func (vm *VM) calFuncExpression(expression ast.FuncExpression, env Env) (interface{}, error) {
+	f, err := vm.lookupFunc(expression.GetFuncName(), len(expression.GetArguments()))
+	if err != nil {
+		return nil, err
 	}
 
-	args := make([]Value, 0)
+	args := make([]interface{}, 0)
 	for _, argNode := range expression.GetArguments() {
 		rawValue, err := vm.cal(argNode, env)
 		if err != nil {
 			return nil, err
 		}
-		args = append(args, Value{rawValue})
+		args = append(args, rawValue)
 	}
 
-	return calUdf(f, args)
+	// note 函数中的 panic 在 Call 中被转换为 error
+	result, err := f.Call(args)
+	if err != nil {
+		return nil, newEvalError(f.Name(), err, args...)
+	}
+	return result, nil
 }
 
-func calUdf(f function.Function, args []Value) (interface{}, error) {
-	// todo 有可能panic，在哪捕获panic比较好？
-	switch f.ArgumentsNum() {
-	case 0:
-		if ff, ok := f.F().(func() (interface{}, error)); ok {
-			return ff()
-		}
-	case 1:
-		if ff, ok := f.F().(func(arg Value) (interface{}, error)); ok {
-			return ff(args[0])
-		}
-	case 2:
-		if ff, ok := f.F().(func(arg1, arg2 Value) (interface{}, error)); ok {
-			return (ff)(args[0], args[1])
-		}
+// lookupFunc 查找函数并检查参数的个数
+func (vm *VM) lookupFunc(name string, argumentsNum int) (function.Function, error) {
+	vm.mu.RLock()
+	defer vm.mu.RUnlock()
+	return findFunc(vm.funcByName, name, argumentsNum)
+}
 
-	case 3:
-		if ff, ok := f.F().(func(arg1, arg2, arg3 Value) (interface{}, error)); ok {
-			return (ff)(args[0], args[1], args[2])
-		}
-	default:
-		return nil, errors.New("todo: 使用反射或者生成代码")
+// findFunc 和 lookupFunc 相同，用于编译阶段，funcByName 是编译时复制的函数
+func findFunc(funcByName map[string]function.Function, name string, argumentsNum int) (function.Function, error) {
+	// note 编译的时候就应该判断一下有没有udf
+
+	var f function.Function
+	if val, ok := funcByName[name]; !ok {
+		return f, errors.New(fmt.Sprintf("invalid udf named '%s'", name))
+	} else {
+		f = val
 	}
 
-	return nil, errors.New("should not invoke here")
+	// note 应该编译的时候就发现这个问题，至少应该打印个warn日志（万一用户只想编译做分析、不想执行？）
+	// note 或者编译的时候可以让用户可选的忽略udf的是否存在的检查？
+	if f.ArgumentsNum() != -1 && f.ArgumentsNum() != argumentsNum {
+		return f, errors.New(fmt.Sprintf("the func of '%s' require %d argument instead of %d",
+			f.Name(), f.ArgumentsNum(), argumentsNum))
+	}
+	return f, nil
 }
 
-func (vm *VM) calBinary(exp ast.BinaryExpression, env map[string]interface{}) (interface{}, error) {
+func (vm *VM) calBinary(exp ast.BinaryExpression, env Env) (interface{}, error) {
+	if exp.Associativity() == ast.RightAssociativity {
+		return vm.calRightAssociativeBinary(exp, env)
+	}
+
 	firstVal, err := vm.cal(exp.Left(), env)
 	if err != nil {
 		return nil, err
@@ -145,6 +266,19 @@ func (vm *VM) calBinary(exp ast.BinaryExpression, env map[string]interface{}) (i
 
 	tmpResult := firstVal
 	for _, argument := range exp.GetArguments() {
+		// note 同一个二元表达式中运算符的优先级相同，所以 && 和 || 不会同时出现，
+		//		左边的值已经可以确定结果时直接返回，右边的表达式不会被计算
+		operator := argument.GetOperator()
+		if function.IsLogicalOperator(operator.GetOperator()) {
+			b, short, bErr := shortCircuit(operator.GetOperator(), tmpResult)
+			if bErr != nil {
+				return nil, bErr
+			}
+			if short {
+				return b, nil
+			}
+		}
+
 		argumentVal, aErr := vm.cal(argument.GetArg(), env)
 		if aErr != nil {
 			return nil, aErr
@@ -161,121 +295,294 @@ func (vm *VM) calBinary(exp ast.BinaryExpression, env map[string]interface{}) (i
 	return tmpResult, nil
 }
 
-func (vm *VM) calUnary(unaryExpression ast.UnaryExpression, env map[string]interface{}) (interface{}, error) {
-	expValue, err := vm.cal(unaryExpression.Exp(), env)
+// shortCircuit 逻辑运算左边的值是否已经可以确定结果，short 为 true 时 b 就是运算结果
+func shortCircuit(operator string, left interface{}) (b bool, short bool, err error) {
+	b, err = function.Bool(left)
 	if err != nil {
-		return nil, err
-	}
-	numberValue, err := Value{rawValue: expValue}.AsInt64()
-	if err != nil {
-		return nil, err
+		return false, false, fmt.Errorf("invalid left operand of '%s': %v", operator, err)
 	}
+	return b, function.IsShortCircuit(operator, b), nil
+}
 
-	op := unaryExpression.Op()
-	operator := (&op).GetOperator()
+// calRightAssociativeBinary 右结合的运算，比如 2 ** 3 ** 2 = 2 ** 9，note 右结合的运算符都不需要短路
+func (vm *VM) calRightAssociativeBinary(exp ast.BinaryExpression, env Env) (interface{}, error) {
+	arguments := exp.GetArguments()
+	operands := make([]interface{}, 0, len(arguments)+1)
 
-	if operator == "-" {
-		return -numberValue, nil
-	} else if operator == "+" {
-		return numberValue, nil
-	} else {
-		return nil, errors.New("invalid unary operator '" + operator + "'")
+	leftVal, err := vm.cal(exp.Left(), env)
+	if err != nil {
+		return nil, err
 	}
-}
+	operands = append(operands, leftVal)
 
-// todo
-//  1. 返回结果的包装类，可能包括结果类型、值以及获取转换后类型值的方法等
-//  2. 变量替换成参数
-func (vm *VM) opeCal(op ast.OperatorNode, arg1, arg2 interface{}) (interface{}, error) {
-	switch op.GetOperator() {
-	// todo 操作符和具体函数的绑定关系
-	case "+":
-		i, err := function.Int64(arg1)
+	for _, argument := range arguments {
+		argumentVal, err := vm.cal(argument.GetArg(), env)
 		if err != nil {
 			return nil, err
 		}
+		operands = append(operands, argumentVal)
+	}
 
-		i2, err := function.Int64(arg2)
-		if err != nil {
+	tmpResult := operands[len(operands)-1]
+	for i := len(arguments) - 1; i >= 0; i-- {
+		if tmpResult, err = vm.opeCal(arguments[i].GetOperator(), operands[i], tmpResult); err != nil {
 			return nil, err
 		}
+	}
+	return tmpResult, nil
+}
 
-		return interface{}(function.Add(i, i2)), nil
-	case "-":
-		i, err := function.Int64(arg1)
-		if err != nil {
-			return nil, err
-		}
+// calConditional 只计算 condition 选中的分支
+func (vm *VM) calConditional(exp ast.ConditionalExpression, env Env) (interface{}, error) {
+	conditionVal, err := vm.cal(exp.Condition(), env)
+	if err != nil {
+		return nil, err
+	}
 
-		i2, err := function.Int64(arg2)
-		if err != nil {
-			return nil, err
-		}
+	condition, err := conditionValue(conditionVal)
+	if err != nil {
+		return nil, err
+	}
 
-		return interface{}(i - i2), nil
-	case "*":
-		i, err := function.Int64(arg1)
-		if err != nil {
-			return nil, err
-		}
+	if condition {
+		return vm.cal(exp.Then(), env)
+	}
+	return vm.cal(exp.Else(), env)
+}
 
-		i2, err := function.Int64(arg2)
-		if err != nil {
-			return nil, err
-		}
+// calIdentity x 的值满足恒等运算时直接返回，否则按照原来的运算计算，比如 x 是字符串时 x + 0 是字符串拼接
+func (vm *VM) calIdentity(exp ast.IdentityExpression, env Env) (interface{}, error) {
+	value, err := vm.cal(exp.Exp(), env)
+	if err != nil {
+		return nil, err
+	}
+	if exp.IsIdentity(value) {
+		return value, nil
+	}
+
+	operand, err := vm.cal(exp.Operand(), env)
+	if err != nil {
+		return nil, err
+	}
+	if exp.OperandFirst() {
+		return vm.opeCal(exp.Op(), operand, value)
+	}
+	return vm.opeCal(exp.Op(), value, operand)
+}
 
-		return interface{}(i * i2), nil
+// calCommon 公共子表达式在一次计算中只计算一次，结果保存在 CommonScope 对应的 commonEnv 中
+func (vm *VM) calCommon(exp ast.CommonExpression, env Env) (interface{}, error) {
+	common, ok := env.(*commonEnv)
+	if !ok {
+		return vm.cal(exp.Exp(), env)
+	}
 
-	case "/":
-		i, err := function.Int64(arg1)
-		if err != nil {
-			return nil, err
+	if value, ok := common.load(exp.GetId()); ok {
+		return value, nil
+	}
+	value, err := vm.cal(exp.Exp(), env)
+	if err != nil {
+		return nil, err
+	}
+	common.store(exp.GetId(), value)
+	return value, nil
+}
+
+func conditionValue(val interface{}) (bool, error) {
+	condition, err := function.Bool(val)
+	if err != nil {
+		return false, fmt.Errorf("invalid condition of conditional expression: %v", err)
+	}
+	return condition, nil
+}
+
+// calLambda lambda 的计算结果是 *function.Lambda，调用的时候参数所在的作用域覆盖在 env 之上
+func (vm *VM) calLambda(exp ast.LambdaExpression, env Env) *function.Lambda {
+	params := exp.GetParams()
+	return function.NewLambda(params, func(args []interface{}) (interface{}, error) {
+		scope := make(MapEnv, len(params))
+		for i, param := range params {
+			scope[param] = args[i]
 		}
+		return vm.cal(exp.Body(), NewChainEnv(scope, env))
+	})
+}
 
-		i2, err := function.Int64(arg2)
+// calArray 数组的计算结果是 []interface{}
+func (vm *VM) calArray(exp ast.ArrayExpression, env Env) (interface{}, error) {
+	elements := exp.GetElements()
+	result := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		value, err := vm.cal(element, env)
 		if err != nil {
 			return nil, err
 		}
+		result = append(result, value)
+	}
 
-		return interface{}(i / i2), nil
+	return result, nil
+}
 
-	case "%":
-		i, err := function.Int64(arg1)
-		if err != nil {
+func (vm *VM) calIndex(exp ast.IndexExpression, env Env) (interface{}, error) {
+	object, err := vm.cal(exp.Object(), env)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := vm.cal(exp.Index(), env)
+	if err != nil {
+		return nil, err
+	}
+
+	return function.Index(object, index)
+}
+
+func (vm *VM) calSlice(exp ast.SliceExpression, env Env) (interface{}, error) {
+	object, err := vm.cal(exp.Object(), env)
+	if err != nil {
+		return nil, err
+	}
+
+	// note 省略的下标为 nil，表达式计算结果为 nil 时则是非法的下标
+	var low, high interface{}
+	if exp.Low() != nil {
+		if low, err = vm.cal(exp.Low(), env); err != nil {
 			return nil, err
 		}
-
-		i2, err := function.Int64(arg2)
-		if err != nil {
+		if low == nil {
+			return nil, errNilSliceIndex
+		}
+	}
+	if exp.High() != nil {
+		if high, err = vm.cal(exp.High(), env); err != nil {
 			return nil, err
 		}
+		if high == nil {
+			return nil, errNilSliceIndex
+		}
+	}
+
+	return function.Slice(object, low, high)
+}
 
-		return interface{}(i % i2), nil
+// calMember 成员访问，object 可以是 map、结构体以及指向它们的指针和接口
+func (vm *VM) calMember(exp ast.MemberExpression, env Env) (interface{}, error) {
+	object, err := vm.cal(exp.Object(), env)
+	if err != nil {
+		return nil, err
+	}
+
+	return member(object, exp.GetName(), memberPath(exp.Object()))
+}
+
+// member path 是 object 在表达式中的路径，用于错误信息
+func member(object interface{}, name string, path string) (interface{}, error) {
+	value, err := function.Member(object, name)
+	if err != nil {
+		return nil, fmt.Errorf("can not resolve '%s' of '%s': %w", name, path, err)
+	}
+	return value, nil
+}
+
+// memberPath 成员访问的路径，用于错误信息，比如 order.customer
+func memberPath(exp ast.Expression) string {
+	switch e := exp.(type) {
+	case *ast.VariableNode:
+		return e.GetName()
+	case *ast.MemberExpression:
+		return memberPath(e.Object()) + "." + e.GetName()
+	case *ast.IndexExpression:
+		return memberPath(e.Object()) + "[...]"
 	default:
+		return fmt.Sprintf("<%T>", exp)
+	}
+}
+
+func (vm *VM) calUnary(unaryExpression ast.UnaryExpression, env Env) (interface{}, error) {
+	expValue, err := vm.cal(unaryExpression.Exp(), env)
+	if err != nil {
+		return nil, err
+	}
+
+	op := unaryExpression.Op()
+	return vm.unary((&op).GetOperator(), expValue)
+}
+
+func (vm *VM) unary(operator string, expValue interface{}) (interface{}, error) {
+	if operator == "-" {
+		result, err := vm.arithmetic.Negative(expValue)
+		return result, newEvalError(operator, err, expValue)
+	} else if operator == "+" {
+		return function.Positive(expValue)
+	} else if operator == "!" || operator == "not" {
+		return function.Not(expValue)
+	} else {
+		return nil, errors.New("invalid unary operator '" + operator + "'")
+	}
+}
+
+// todo
+//  1. 返回结果的包装类，可能包括结果类型、值以及获取转换后类型值的方法等
+//  2. 变量替换成参数
+func (vm *VM) opeCal(op ast.OperatorNode, arg1, arg2 interface{}) (interface{}, error) {
+	// note 整数之间的运算结果是 int64，有小数参与的运算结果是 float64，溢出时的处理方式见 function.OverflowMode
+	f, ok := vm.arithmetic.BinaryOperator(op.GetOperator())
+	if !ok {
 		return nil, errors.New("invalid operator:" + op.GetOperator())
 	}
+
+	result, err := f(arg1, arg2)
+	return result, newEvalError(op.GetOperator(), err, arg1, arg2)
 }
 
-func (vm *VM) calVariable(variableName string, env map[string]interface{}) (interface{}, error) {
+func (vm *VM) calVariable(variableName string, env Env) (interface{}, error) {
 	// note 如果表达式只有一个变量 a，则直接返回a对应的对象，int/int32等也不会返回对应的转换后的值
-	// todo
-	//		1. 将各种类型的 int 统一为 int64
-	//		2. env 不仅可以是map，而且可以是对象、从对象中反射取值。internal.Fetch(env(interface{}),key)
-	return env[variableName], nil
+	// todo 将各种类型的 int 统一为 int64
+	value, _ := env.Lookup(variableName)
+	return value, nil
 }
 
-func (vm *VM) getExpressionFromCache(exp string) ast.Expression {
-	if vm == nil {
+func (vm *VM) getExpressionFromCache(exp string) *compiledExpression {
+	if vm == nil || vm.expressionCache == nil {
 		return nil
 	}
 
-	return vm.expressionCache[exp]
+	return vm.expressionCache.get(exp)
 }
 
-func (vm *VM) setExpressionCache(exp string, expression ast.Expression) {
+// clearExpressionCache 注册或者删除函数之后需要清空缓存，因为缓存的表达式中可能有折叠之后的函数调用
+//
+//	note 调用时需要持有 vm.mu 的写锁
+func (vm *VM) clearExpressionCache() {
 	if vm == nil {
 		return
 	}
 
-	vm.expressionCache[exp] = expression
+	vm.generation++
+	if vm.expressionCache != nil {
+		vm.expressionCache.clear()
+	}
+}
+
+// setExpressionCache generation 是编译时函数的版本，编译的过程中注册或者删除了函数时不缓存，
+// 这样清空缓存之后不会再写入使用旧函数编译的表达式
+func (vm *VM) setExpressionCache(exp string, expression *compiledExpression, generation uint64) {
+	if vm == nil || vm.expressionCache == nil {
+		return
+	}
+
+	vm.mu.RLock()
+	defer vm.mu.RUnlock()
+	if generation == vm.generation {
+		vm.expressionCache.add(exp, expression)
+	}
+}
+
+// CacheStats 表达式缓存的命中、未命中和淘汰的次数，见 config.WithCacheSize
+func (vm *VM) CacheStats() CacheStats {
+	if vm == nil || vm.expressionCache == nil {
+		return CacheStats{}
+	}
+
+	return vm.expressionCache.snapshot()
 }